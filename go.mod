@@ -1,10 +1,14 @@
 module github.com/kurtosis-tech/ethereum-kurtosis-module
 
-go 1.15
+go 1.16
 
 require (
+	github.com/ethereum/go-ethereum v1.10.8
 	github.com/kurtosis-tech/kurtosis-module-api-lib/golang v0.0.0-20220928125950-1b59f15b6f40
 	github.com/kurtosis-tech/kurtosis-sdk/api/golang v0.0.0-20220927205154-f24b7016b373
 	github.com/kurtosis-tech/stacktrace v0.0.0-20211028211901-1c67a77b5409
 	github.com/sirupsen/logrus v1.8.1
+	github.com/stretchr/testify v1.7.0
+	google.golang.org/grpc v1.39.0
+	google.golang.org/protobuf v1.27.1
 )
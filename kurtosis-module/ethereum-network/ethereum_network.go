@@ -0,0 +1,277 @@
+package ethereum_network
+
+import (
+	"context"
+	"fmt"
+	"github.com/kurtosis-tech/ethereum-kurtosis-module/kurtosis-module/impl"
+	"github.com/kurtosis-tech/kurtosis-sdk/api/golang/core/lib/enclaves"
+	"github.com/kurtosis-tech/kurtosis-sdk/api/golang/core/lib/services"
+	"github.com/kurtosis-tech/stacktrace"
+	"github.com/sirupsen/logrus"
+	"time"
+)
+
+const (
+	timeBetweenBlockChecks = 1 * time.Second
+)
+
+// An Ethereum network in an enclave, started by the same code as the module but through Go calls, for testsuites that hold
+// the enclave context themselves; like the module, it isn't safe for concurrent use
+type Network struct {
+	enclaveCtx *enclaves.EnclaveContext
+	module     *impl.EthereumKurtosisModule
+	// Holds a value while a call is using the module, which is only ever used by one call at a time
+	moduleSlot chan struct{}
+
+	isStarted bool
+
+	// Result of the latest action executed on the network
+	result *impl.ModuleAPIExecuteResult
+	nodes  map[services.ServiceID]*Node
+}
+
+// A node of the network, along with the info that the module reports about it
+type Node struct {
+	serviceId services.ServiceID
+	info      *impl.ModuleAPIEthereumNodeInfo
+	rpcUrl    string
+}
+
+func NewNetwork(enclaveCtx *enclaves.EnclaveContext) *Network {
+	return &Network{
+		enclaveCtx: enclaveCtx,
+		module:     impl.NewEthereumKurtosisModule(),
+		moduleSlot: make(chan struct{}, 1),
+		nodes:      map[services.ServiceID]*Node{},
+	}
+}
+
+// Starts the network of the given spec, or the default network for a nil spec
+// Like an execution of the module, a failed start removes the services it created unless the spec keeps them on failure,
+// and so does a start whose context is done before it is
+func (network *Network) Start(ctx context.Context, spec *Spec) error {
+	if network.isStarted {
+		return stacktrace.NewError("The network has already been started")
+	}
+	if spec == nil {
+		spec = &Spec{}
+	}
+	params := spec.toExecuteParams()
+
+	var result *impl.ModuleAPIExecuteResult
+	startNetwork := func() error {
+		var err error
+		result, err = network.module.ExecuteParams(network.enclaveCtx, params)
+		return err
+	}
+	removeNetwork := func() {
+		if _, err := network.module.RemoveAddedServices(network.enclaveCtx); err != nil {
+			logrus.Warnf("An error occurred removing the network whose start was abandoned:\n%v", err)
+		}
+	}
+	if err := network.callModule(ctx, startNetwork, removeNetwork); err != nil {
+		return stacktrace.Propagate(err, "An error occurred starting the Ethereum network")
+	}
+	network.isStarted = true
+	if err := network.setResult(result); err != nil {
+		return stacktrace.Propagate(err, "An error occurred getting the nodes of the started network")
+	}
+	return nil
+}
+
+// Adds a child node with the default spec, which gets peered with the rest of the network, returning it
+func (network *Network) AddNode(ctx context.Context) (*Node, error) {
+	if !network.isStarted {
+		return nil, stacktrace.NewError("The network must be started before nodes can be added to it")
+	}
+	previousNodes := network.nodes
+
+	var result *impl.ModuleAPIExecuteResult
+	addNode := func() error {
+		var err error
+		result, err = network.module.ExecuteParams(network.enclaveCtx, &impl.ModuleAPIExecuteArgs{
+			Action:    impl.AddNodesAction,
+			NodeCount: 1,
+		})
+		return err
+	}
+	removeAddedNode := func() {
+		for serviceId := range result.NodeInfo {
+			if _, found := previousNodes[serviceId]; found {
+				continue
+			}
+			_, err := network.module.ExecuteParams(network.enclaveCtx, &impl.ModuleAPIExecuteArgs{
+				Action:        impl.RemoveNodeAction,
+				NodeServiceID: serviceId,
+			})
+			if err != nil {
+				logrus.Warnf("An error occurred removing node '%v', whose addition was abandoned:\n%v", serviceId, err)
+			}
+		}
+	}
+	if err := network.callModule(ctx, addNode, removeAddedNode); err != nil {
+		return nil, stacktrace.Propagate(err, "An error occurred adding a node to the Ethereum network")
+	}
+	if err := network.setResult(result); err != nil {
+		return nil, stacktrace.Propagate(err, "An error occurred getting the nodes of the network after adding a node")
+	}
+	for serviceId, node := range network.nodes {
+		if _, found := previousNodes[serviceId]; !found {
+			return node, nil
+		}
+	}
+	return nil, stacktrace.NewError("No new node was found in the network after adding a node; this is a bug with this module")
+}
+
+// Returns the node with the given service ID, or nil if the network has no such node
+func (network *Network) Node(serviceId services.ServiceID) *Node {
+	return network.nodes[serviceId]
+}
+
+// Returns the result of the latest action executed on the network, with the accounts and the info of every node
+func (network *Network) GetResult() *impl.ModuleAPIExecuteResult {
+	return network.result
+}
+
+// Waits until every node that isn't stopped has reached the given block, or the context is done
+// A node that can't be queried is waited on, as it may only be starting
+func (network *Network) WaitForBlock(ctx context.Context, blockNumber uint64) error {
+	if !network.isStarted {
+		return stacktrace.NewError("The network must be started before its blocks can be waited for")
+	}
+	for {
+		var status *impl.ModuleAPIStatusResult
+		getStatus := func() error {
+			var err error
+			status, err = network.module.GetNetworkStatus(network.enclaveCtx)
+			return err
+		}
+		if err := network.callModule(ctx, getStatus, nil); err != nil {
+			return stacktrace.Propagate(err, "An error occurred getting the status of the Ethereum network while waiting for block '%v'", blockNumber)
+		}
+		laggingNodeBlocks := map[services.ServiceID]uint64{}
+		for serviceId, nodeStatus := range status.NodeStatus {
+			if nodeStatus.IsStopped {
+				continue
+			}
+			if nodeStatus.Error != "" || nodeStatus.HeadBlockNumber < blockNumber {
+				laggingNodeBlocks[serviceId] = nodeStatus.HeadBlockNumber
+			}
+		}
+		if len(laggingNodeBlocks) == 0 {
+			return nil
+		}
+		logrus.Debugf("Waiting for block '%v' on nodes whose head blocks are %v", blockNumber, laggingNodeBlocks)
+
+		select {
+		case <-ctx.Done():
+			return stacktrace.Propagate(
+				ctx.Err(),
+				"The context was done before every node reached block '%v'; the nodes that hadn't were at head blocks %v",
+				blockNumber,
+				laggingNodeBlocks,
+			)
+		case <-time.After(timeBetweenBlockChecks):
+		}
+	}
+}
+
+// Removes every service that the network started from the enclave, leaving the others in place, after which the network
+// can be started again
+// A stop whose context is done before it is leaves the network started, so that stopping it again removes what's left
+func (network *Network) Stop(ctx context.Context) error {
+	if !network.isStarted {
+		return stacktrace.NewError("The network isn't started")
+	}
+
+	removeNetwork := func() error {
+		removedServiceIds, err := network.module.RemoveAddedServices(network.enclaveCtx)
+		if err != nil {
+			return stacktrace.Propagate(err, "An error occurred after removing services %v", removedServiceIds)
+		}
+		return nil
+	}
+	if err := network.callModule(ctx, removeNetwork, nil); err != nil {
+		return stacktrace.Propagate(err, "An error occurred stopping the Ethereum network")
+	}
+	network.isStarted = false
+	network.result = nil
+	network.nodes = map[services.ServiceID]*Node{}
+	return nil
+}
+
+// Returns the service ID of the node, which is its key in the node info of the result
+func (node *Node) GetServiceID() services.ServiceID {
+	return node.serviceId
+}
+
+func (node *Node) GetInfo() *impl.ModuleAPIEthereumNodeInfo {
+	return node.info
+}
+
+// Returns the URL of the RPC API of the node, which is reachable from inside the enclave; in the "hardened" RPC profile it's
+// the JWT-authenticated endpoint, whose requests need a token signed with the secret of the result
+func (node *Node) RPC() string {
+	return node.rpcUrl
+}
+
+// Runs the given call on the module, returning the error of the context if it's done first, in which case the call keeps the
+// module until it's done and then gets undone if it succeeded
+func (network *Network) callModule(ctx context.Context, call func() error, undo func()) error {
+	select {
+	case network.moduleSlot <- struct{}{}:
+	case <-ctx.Done():
+		return stacktrace.Propagate(ctx.Err(), "The context was done while an abandoned call was still using the module")
+	}
+
+	// The channel is unbuffered, so the error is either received by the caller, or the call gets abandoned, but not both
+	callErrChan := make(chan error)
+	go func() {
+		defer func() {
+			<-network.moduleSlot
+		}()
+		callErr := call()
+		select {
+		case callErrChan <- callErr:
+		case <-ctx.Done():
+			if callErr == nil && undo != nil {
+				undo()
+			}
+		}
+	}()
+
+	select {
+	case callErr := <-callErrChan:
+		return callErr
+	case <-ctx.Done():
+		return stacktrace.Propagate(ctx.Err(), "The context was done before the call on the module was")
+	}
+}
+
+// Records the given result, along with the nodes in its node info
+func (network *Network) setResult(result *impl.ModuleAPIExecuteResult) error {
+	nodes := map[services.ServiceID]*Node{}
+	for serviceId, nodeInfo := range result.NodeInfo {
+		serviceCtx, err := network.enclaveCtx.GetServiceContext(serviceId)
+		if err != nil {
+			return stacktrace.Propagate(err, "An error occurred getting the service context of node '%v'", serviceId)
+		}
+		// The nodes of the "hardened" RPC profile only serve the JWT-authenticated endpoint
+		rpcPortId := nodeInfo.RpcPortId
+		if rpcPortId == "" {
+			rpcPortId = nodeInfo.AuthRpcPortId
+		}
+		rpcPort, found := serviceCtx.GetPrivatePorts()[rpcPortId]
+		if !found {
+			return stacktrace.NewError("Node '%v' has no RPC port '%v'", serviceId, rpcPortId)
+		}
+		nodes[serviceId] = &Node{
+			serviceId: serviceId,
+			info:      nodeInfo,
+			rpcUrl:    fmt.Sprintf("http://%v:%v", nodeInfo.IPAddrInsideNetwork, rpcPort.GetNumber()),
+		}
+	}
+	network.result = result
+	network.nodes = nodes
+	return nil
+}
@@ -0,0 +1,113 @@
+package ethereum_network
+
+import (
+	"context"
+	"testing"
+
+	"github.com/kurtosis-tech/ethereum-kurtosis-module/kurtosis-module/impl"
+	"github.com/kurtosis-tech/stacktrace"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSpecToExecuteParams_SharesNothingWithSpec(t *testing.T) {
+	spec := &Spec{
+		Nodes:           []*NodeSpec{{ServiceID: "ethereum-node-1", SyncMode: "full"}},
+		PeeringStrategy: "static_nodes",
+		ImportedAccounts: &ImportedAccounts{
+			PrivateKeys:       []string{"4c0883a69102937d6231471b5dbb6204fe512961708279f9d7d1b3e1c4d9f1a1"},
+			KeystorePasswords: map[string]string{"UTC--account-1": "password"},
+		},
+		ResourceDefaults: &ResourceDefaults{
+			Bootnode: &ResourceLimits{CpuMillicpus: 500},
+		},
+	}
+	params := spec.toExecuteParams()
+	require.Equal(t, &impl.ModuleAPIExecuteArgs{
+		Action:          impl.CreateAction,
+		Nodes:           []*impl.ModuleAPINodeSpec{{ServiceID: "ethereum-node-1", SyncMode: "full"}},
+		PeeringStrategy: "static_nodes",
+		ImportedAccounts: &impl.ModuleAPIImportedAccountsArgs{
+			PrivateKeys:       []string{"4c0883a69102937d6231471b5dbb6204fe512961708279f9d7d1b3e1c4d9f1a1"},
+			KeystorePasswords: map[string]string{"UTC--account-1": "password"},
+		},
+		ResourceDefaults: &impl.ModuleAPIResourceDefaults{
+			Bootnode: &impl.ModuleAPIResourceLimits{CpuMillicpus: 500},
+		},
+	}, params)
+
+	// What the defaults change in the params mustn't show up in the spec of the caller
+	params.Nodes[0].SyncMode = "snap"
+	params.ImportedAccounts.PrivateKeys[0] = ""
+	params.ImportedAccounts.KeystorePasswords["UTC--account-1"] = ""
+	params.ResourceDefaults.Bootnode.CpuMillicpus = 0
+	require.Equal(t, "full", spec.Nodes[0].SyncMode)
+	require.Equal(t, "4c0883a69102937d6231471b5dbb6204fe512961708279f9d7d1b3e1c4d9f1a1", spec.ImportedAccounts.PrivateKeys[0])
+	require.Equal(t, "password", spec.ImportedAccounts.KeystorePasswords["UTC--account-1"])
+	require.Equal(t, uint64(500), spec.ResourceDefaults.Bootnode.CpuMillicpus)
+}
+
+func TestCallModule(t *testing.T) {
+	tests := []struct {
+		name           string
+		callErr        error
+		isAbandoned    bool
+		isUndoExpected bool
+	}{
+		{
+			name: "call done",
+		},
+		{
+			name:    "call failed",
+			callErr: stacktrace.NewError("Test call failed"),
+		},
+		{
+			name:           "call abandoned",
+			isAbandoned:    true,
+			isUndoExpected: true,
+		},
+		{
+			name:        "failed call abandoned",
+			callErr:     stacktrace.NewError("Test call failed"),
+			isAbandoned: true,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			network := &Network{moduleSlot: make(chan struct{}, 1)}
+			ctx, cancelFunc := context.WithCancel(context.Background())
+			defer cancelFunc()
+
+			// An abandoned call is still running when its context gets done
+			releaseCall := make(chan struct{})
+			call := func() error {
+				if test.isAbandoned {
+					cancelFunc()
+					<-releaseCall
+				}
+				return test.callErr
+			}
+			undoneChan := make(chan bool, 1)
+			undo := func() {
+				undoneChan <- true
+			}
+			err := network.callModule(ctx, call, undo)
+			if test.isAbandoned {
+				require.Equal(t, context.Canceled, stacktrace.RootCause(err))
+				close(releaseCall)
+			} else if test.callErr != nil {
+				require.Error(t, err)
+			} else {
+				require.NoError(t, err)
+			}
+
+			// The next call only gets the module once the abandoned one is done with it
+			require.NoError(t, network.callModule(context.Background(), func() error { return nil }, nil))
+			select {
+			case <-undoneChan:
+				require.True(t, test.isUndoExpected, "The call was undone, although it wasn't expected to be")
+			default:
+				require.False(t, test.isUndoExpected, "The call wasn't undone, although it was expected to be")
+			}
+		})
+	}
+}
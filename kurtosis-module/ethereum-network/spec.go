@@ -0,0 +1,177 @@
+package ethereum_network
+
+import (
+	"github.com/kurtosis-tech/ethereum-kurtosis-module/kurtosis-module/impl"
+	"github.com/kurtosis-tech/kurtosis-sdk/api/golang/core/lib/services"
+)
+
+// What a network gets started with, which maps to the execute params of the "create" action; the zero value of a field
+// takes the default of its param, and the other actions are methods of the network
+type Spec struct {
+	// The child nodes of the network, which default to two child nodes with the default spec
+	Nodes []*NodeSpec
+
+	// One of "network" (the default), for a bootnode with child nodes, or "dev", for a single Geth node in dev mode
+	Mode string
+	// Seconds between the blocks sealed by the dev node, where 0 seals a block as soon as a transaction arrives
+	DevPeriodSeconds uint32
+
+	// One of "clique" (the default) or "engine_api_driver"
+	BlockProduction string
+	EngineApiDriver *EngineApiDriverSpec
+
+	// One of "admin_add_peer" (the default), "static_nodes", "trusted_nodes" or "discovery"
+	PeeringStrategy string
+	// One of "signer" (the default) or "discovery_only"
+	BootnodeMode  string
+	SignerCount   uint32
+	BootnodeCount uint32
+	// One of "bootnodes" (the default) or "dns"
+	NodeDiscovery string
+
+	ResourceDefaults *ResourceDefaults
+
+	// One of "open" (the default) or "hardened"
+	RpcProfile  string
+	HardenedRpc *HardenedRpcSpec
+	// One of "keystore" or "clef", which defaults according to the RPC profile
+	SignerBackend string
+
+	ImportedAccounts        *ImportedAccounts
+	StaticFilesArtifactUUID services.FilesArtifactUUID
+
+	// Secret mixed into the keys that the module derives, without which anyone can derive them
+	KeySeed string
+
+	// One of "inline" (the default) or "files_artifact"
+	SecretsOutput      string
+	IncludePrivateKeys bool
+
+	MaxConcurrency uint32
+	KeepOnFailure  bool
+}
+
+type NodeSpec struct {
+	// Must start with "ethereum-node-"
+	ServiceID services.ServiceID
+	// One of "archive" (the default) or "full"
+	GcMode string
+	// One of "full" (the default) or "snap"
+	SyncMode string
+
+	CpuMillicpus    uint64
+	MemoryMegabytes uint64
+}
+
+type EngineApiDriverSpec struct {
+	FeeRecipient    string
+	SlotTimeSeconds uint32
+}
+
+type ResourceDefaults struct {
+	Bootnode    *ResourceLimits
+	Signer      *ResourceLimits
+	ArchiveNode *ResourceLimits
+	FullNode    *ResourceLimits
+}
+
+// Where 0 leaves the resource unlimited
+type ResourceLimits struct {
+	CpuMillicpus    uint64
+	MemoryMegabytes uint64
+}
+
+type HardenedRpcSpec struct {
+	VirtualHosts []string
+}
+
+type ImportedAccounts struct {
+	// Hex-encoded private keys, whose accounts come first
+	PrivateKeys                []string
+	KeystoresFilesArtifactUUID services.FilesArtifactUUID
+	// Password of each keystore by its file name, where the keystores that aren't listed use the keystore password
+	KeystorePasswords map[string]string
+	KeystorePassword  string
+	// Decimal string, which defaults to 1000 ether
+	GenesisBalanceWei string
+}
+
+// Returns the execute params of the "create" action for the spec, which share nothing with the spec, so that applying the
+// defaults to them leaves the spec of the caller as it is
+func (spec *Spec) toExecuteParams() *impl.ModuleAPIExecuteArgs {
+	params := &impl.ModuleAPIExecuteArgs{
+		Action:                  impl.CreateAction,
+		Mode:                    spec.Mode,
+		DevPeriodSeconds:        spec.DevPeriodSeconds,
+		BlockProduction:         spec.BlockProduction,
+		PeeringStrategy:         spec.PeeringStrategy,
+		BootnodeMode:            spec.BootnodeMode,
+		SignerCount:             spec.SignerCount,
+		BootnodeCount:           spec.BootnodeCount,
+		NodeDiscovery:           spec.NodeDiscovery,
+		RpcProfile:              spec.RpcProfile,
+		SignerBackend:           spec.SignerBackend,
+		StaticFilesArtifactUUID: spec.StaticFilesArtifactUUID,
+		KeySeed:                 spec.KeySeed,
+		SecretsOutput:           spec.SecretsOutput,
+		IncludePrivateKeys:      spec.IncludePrivateKeys,
+		MaxConcurrency:          spec.MaxConcurrency,
+		KeepOnFailure:           spec.KeepOnFailure,
+	}
+	for _, nodeSpec := range spec.Nodes {
+		params.Nodes = append(params.Nodes, &impl.ModuleAPINodeSpec{
+			ServiceID:       nodeSpec.ServiceID,
+			GcMode:          nodeSpec.GcMode,
+			SyncMode:        nodeSpec.SyncMode,
+			CpuMillicpus:    nodeSpec.CpuMillicpus,
+			MemoryMegabytes: nodeSpec.MemoryMegabytes,
+		})
+	}
+	if spec.EngineApiDriver != nil {
+		params.EngineApiDriver = &impl.ModuleAPIEngineApiDriverArgs{
+			FeeRecipient:    spec.EngineApiDriver.FeeRecipient,
+			SlotTimeSeconds: spec.EngineApiDriver.SlotTimeSeconds,
+		}
+	}
+	if spec.ResourceDefaults != nil {
+		params.ResourceDefaults = &impl.ModuleAPIResourceDefaults{
+			Bootnode:    spec.ResourceDefaults.Bootnode.toExecuteParams(),
+			Signer:      spec.ResourceDefaults.Signer.toExecuteParams(),
+			ArchiveNode: spec.ResourceDefaults.ArchiveNode.toExecuteParams(),
+			FullNode:    spec.ResourceDefaults.FullNode.toExecuteParams(),
+		}
+	}
+	if spec.HardenedRpc != nil {
+		params.HardenedRpc = &impl.ModuleAPIHardenedRpcArgs{}
+		if spec.HardenedRpc.VirtualHosts != nil {
+			params.HardenedRpc.VirtualHosts = append([]string{}, spec.HardenedRpc.VirtualHosts...)
+		}
+	}
+	if spec.ImportedAccounts != nil {
+		params.ImportedAccounts = &impl.ModuleAPIImportedAccountsArgs{
+			KeystoresFilesArtifactUUID: spec.ImportedAccounts.KeystoresFilesArtifactUUID,
+			KeystorePassword:           spec.ImportedAccounts.KeystorePassword,
+			GenesisBalanceWei:          spec.ImportedAccounts.GenesisBalanceWei,
+		}
+		if spec.ImportedAccounts.PrivateKeys != nil {
+			params.ImportedAccounts.PrivateKeys = append([]string{}, spec.ImportedAccounts.PrivateKeys...)
+		}
+		if spec.ImportedAccounts.KeystorePasswords != nil {
+			params.ImportedAccounts.KeystorePasswords = map[string]string{}
+			for keystoreFilename, password := range spec.ImportedAccounts.KeystorePasswords {
+				params.ImportedAccounts.KeystorePasswords[keystoreFilename] = password
+			}
+		}
+	}
+	return params
+}
+
+func (limits *ResourceLimits) toExecuteParams() *impl.ModuleAPIResourceLimits {
+	if limits == nil {
+		return nil
+	}
+	return &impl.ModuleAPIResourceLimits{
+		CpuMillicpus:    limits.CpuMillicpus,
+		MemoryMegabytes: limits.MemoryMegabytes,
+	}
+}
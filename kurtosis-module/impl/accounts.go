@@ -0,0 +1,64 @@
+package impl
+
+import (
+	"crypto/ecdsa"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/kurtosis-tech/kurtosis-sdk/api/golang/core/lib/services"
+	"github.com/kurtosis-tech/stacktrace"
+)
+
+const (
+	// Name of the file of an account's private key in the secrets files artifact, after the address of the account
+	accountPrivateKeyOutputFilenameSuffix = "-private-key"
+)
+
+// Returns the accounts that the module manages: the imported accounts if any, and otherwise the one whose keystore is in the
+// static files, followed by the derived accounts of the extra signers
+// The private keys are only included if requested; they're registered as secrets either way, so the logs never show them
+func getAccountsInfo(
+	launchConfig *nodeLaunchConfig,
+	signerServiceIds []services.ServiceID,
+	isPrivateKeyIncluded bool,
+) ([]*ModuleAPIAccountInfo, error) {
+	accountKeys := launchConfig.importedAccountKeys
+	if len(accountKeys) == 0 {
+		accountKeys = []*ecdsa.PrivateKey{launchConfig.staticFiles.signerAccountKey}
+		for _, signerServiceId := range signerServiceIds {
+			signerAccountKey, err := getSignerAccountKey(launchConfig, signerServiceId)
+			if err != nil {
+				return nil, stacktrace.Propagate(err, "An error occurred getting the account key of signer '%v'", signerServiceId)
+			}
+			if signerAccountKey != nil {
+				accountKeys = append(accountKeys, signerAccountKey)
+			}
+		}
+	}
+
+	// The dev node has no signer services, but still seals with the account of the static files
+	signerServiceIdsByAddr := map[string]services.ServiceID{}
+	for _, signerServiceId := range signerServiceIds {
+		signerAccountAddr, err := getSignerAccountAddress(launchConfig, signerServiceId)
+		if err != nil {
+			return nil, stacktrace.Propagate(err, "An error occurred getting the account address of signer '%v'", signerServiceId)
+		}
+		signerServiceIdsByAddr[signerAccountAddr] = signerServiceId
+	}
+	result := []*ModuleAPIAccountInfo{}
+	for _, accountKey := range accountKeys {
+		result = append(result, newAccountInfo(accountKey, signerServiceIdsByAddr[getAccountAddress(accountKey)], isPrivateKeyIncluded))
+	}
+	return result, nil
+}
+
+func newAccountInfo(privateKey *ecdsa.PrivateKey, signerServiceId services.ServiceID, isPrivateKeyIncluded bool) *ModuleAPIAccountInfo {
+	accountInfo := &ModuleAPIAccountInfo{
+		Address:         getAccountAddress(privateKey),
+		PublicKey:       hexutil.Encode(crypto.FromECDSAPub(&privateKey.PublicKey)),
+		SignerServiceID: signerServiceId,
+	}
+	if isPrivateKeyIncluded {
+		accountInfo.PrivateKey = hexutil.Encode(crypto.FromECDSA(privateKey))
+	}
+	return accountInfo
+}
@@ -0,0 +1,50 @@
+package impl
+
+import (
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	testAccountPrivateKey = "4c0883a69102937d6231471b5dbb6204fe512961708279f9d7d1b3e1c4d9f1a1"
+)
+
+// The keys of an account are in the same format, which the imported private keys can be given in again
+func TestNewAccountInfo(t *testing.T) {
+	tests := []struct {
+		name                 string
+		isPrivateKeyIncluded bool
+	}{
+		{
+			name: "without the private key",
+		},
+		{
+			name:                 "with the private key",
+			isPrivateKeyIncluded: true,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			accountKey, err := parseImportedPrivateKey(testAccountPrivateKey)
+			require.NoError(t, err)
+			accountInfo := newAccountInfo(accountKey, getSignerNodeServiceId(1), test.isPrivateKeyIncluded)
+			require.Equal(t, getAccountAddress(accountKey), accountInfo.Address)
+			require.Equal(t, getSignerNodeServiceId(1), accountInfo.SignerServiceID)
+
+			publicKeyBytes, err := hexutil.Decode(accountInfo.PublicKey)
+			require.NoError(t, err)
+			require.Equal(t, crypto.FromECDSAPub(&accountKey.PublicKey), publicKeyBytes)
+			if !test.isPrivateKeyIncluded {
+				require.Empty(t, accountInfo.PrivateKey)
+				return
+			}
+			require.Equal(t, "0x"+testAccountPrivateKey, accountInfo.PrivateKey)
+			reimportedKey, err := parseImportedPrivateKey(accountInfo.PrivateKey)
+			require.NoError(t, err)
+			require.Equal(t, accountKey, reimportedKey)
+		})
+	}
+}
@@ -0,0 +1,217 @@
+package impl
+
+import (
+	"fmt"
+	"github.com/kurtosis-tech/kurtosis-sdk/api/golang/core/lib/services"
+	"github.com/kurtosis-tech/stacktrace"
+	"github.com/sirupsen/logrus"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+const (
+	// Image with Geth's standalone tools, including the bootnode binary that only runs discovery
+	discoveryBootnodeDockerImageName = "ethereum/client-go:alltools-v1.10.8"
+
+	// The first bootnode keeps the service ID it had before there could be more than one, and the others get numbered after it
+	firstBootnodeIdx             = 1
+	extraBootnodeServiceIdPrefix = bootnodeServiceID + "-"
+
+	defaultBootnodeCount = 1
+)
+
+func getBootnodeServiceId(bootnodeIdx int) services.ServiceID {
+	if bootnodeIdx == firstBootnodeIdx {
+		return bootnodeServiceID
+	}
+	return services.ServiceID(extraBootnodeServiceIdPrefix + strconv.Itoa(bootnodeIdx))
+}
+
+func getBootnodeServiceIds(launchConfig *nodeLaunchConfig) []services.ServiceID {
+	result := []services.ServiceID{}
+	for i := firstBootnodeIdx; i < firstBootnodeIdx+int(launchConfig.bootnodeCount); i++ {
+		result = append(result, getBootnodeServiceId(i))
+	}
+	return result
+}
+
+func isBootnodeServiceId(serviceId services.ServiceID) bool {
+	return serviceId == bootnodeServiceID || strings.HasPrefix(string(serviceId), extraBootnodeServiceIdPrefix)
+}
+
+// Returns the ENRs of all the bootnodes of the network
+// Stopped bootnodes are included, as the nodes keep retrying them and find them again once they're restarted
+func getBootnodeEnrs(launchConfig *nodeLaunchConfig, nodeServiceCtxs map[services.ServiceID]*services.ServiceContext) ([]string, error) {
+	bootnodeEnrs := []string{}
+	for _, serviceId := range getBootnodeServiceIds(launchConfig) {
+		serviceCtx, found := nodeServiceCtxs[serviceId]
+		if !found {
+			return nil, stacktrace.NewError("No bootnode '%v' was found in the network", serviceId)
+		}
+		bootnodeEnr, err := getNodeEnr(launchConfig.keySeed, serviceCtx)
+		if err != nil {
+			return nil, stacktrace.Propagate(err, "An error occurred getting the ENR of bootnode '%v'", serviceId)
+		}
+		bootnodeEnrs = append(bootnodeEnrs, bootnodeEnr)
+	}
+	return bootnodeEnrs, nil
+}
+
+// Adds all the bootnodes of the network without waiting for them to become available, returning their service contexts
+// along with the node info of a bootnode that's also a Geth node, if any
+func startEthBootnodes(
+	enclaveCtx nodeEnclave,
+	launchConfig *nodeLaunchConfig,
+	maxConcurrency uint32,
+) (map[services.ServiceID]*services.ServiceContext, *ModuleAPIEthereumNodeInfo, error) {
+	resultsMutex := &sync.Mutex{}
+	bootnodeServiceCtxs := map[services.ServiceID]*services.ServiceContext{}
+	var gethBootnodeInfo *ModuleAPIEthereumNodeInfo
+	err := runForEachNode(getBootnodeServiceIds(launchConfig), maxConcurrency, func(serviceId services.ServiceID) error {
+		serviceCtx, nodeInfo, err := startEthBootnode(enclaveCtx, launchConfig, serviceId, []string{})
+		if err != nil {
+			return stacktrace.Propagate(err, "An error occurred starting bootnode '%v'", serviceId)
+		}
+		resultsMutex.Lock()
+		defer resultsMutex.Unlock()
+		bootnodeServiceCtxs[serviceId] = serviceCtx
+		if nodeInfo != nil {
+			gethBootnodeInfo = nodeInfo
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, nil, stacktrace.Propagate(err, "An error occurred starting the bootnodes")
+	}
+	return bootnodeServiceCtxs, gethBootnodeInfo, nil
+}
+
+// Adds a bootnode without waiting for it to become available, as its ENR is known as soon as it has an IP
+// A discovery-only bootnode isn't a Geth node, so no node info is returned for it
+func startEthBootnode(
+	enclaveCtx nodeEnclave,
+	launchConfig *nodeLaunchConfig,
+	serviceId services.ServiceID,
+	peerEnodes []string,
+) (*services.ServiceContext, *ModuleAPIEthereumNodeInfo, error) {
+	isDiscoveryOnly := launchConfig.bootnodeMode == discoveryOnlyBootnodeMode
+	gethConfig := ""
+	if !isDiscoveryOnly {
+		renderedConfig, err := renderGethConfig(serviceId, launchConfig, nil, []string{}, peerEnodes)
+		if err != nil {
+			return nil, nil, stacktrace.Propagate(err, "An error occurred rendering the Geth config of bootnode '%v'", serviceId)
+		}
+		gethConfig = renderedConfig
+	}
+	nodeFilesArtifactUuid, err := uploadNodeFiles(enclaveCtx, launchConfig.keySeed, serviceId, gethConfig)
+	if err != nil {
+		return nil, nil, stacktrace.Propagate(err, "An error occurred uploading the files of bootnode '%v'", serviceId)
+	}
+	accountFilesArtifactUuid, err := uploadAccountFiles(enclaveCtx, launchConfig, serviceId)
+	if err != nil {
+		return nil, nil, stacktrace.Propagate(err, "An error occurred uploading the account files of bootnode '%v'", serviceId)
+	}
+	var containerConfig *services.ContainerConfig
+	if isDiscoveryOnly {
+		containerConfig, err = getDiscoveryBootnodeContainerConfig(launchConfig, nodeFilesArtifactUuid, accountFilesArtifactUuid)
+	} else {
+		containerConfig, err = getBootnodeContainerConfig(launchConfig, nodeFilesArtifactUuid, accountFilesArtifactUuid)
+	}
+	if err != nil {
+		return nil, nil, stacktrace.Propagate(err, "An error occurred getting the container config of bootnode '%v'", serviceId)
+	}
+
+	serviceCtx, err := enclaveCtx.AddService(serviceId, containerConfig)
+	if err != nil {
+		return nil, nil, stacktrace.Propagate(err, "An error occurred adding Ethereum bootnode service '%v'", serviceId)
+	}
+
+	logrus.Infof(
+		"Added Ethereum bootnode service '%v' with public IP: %v and public ports: %+v",
+		serviceId,
+		serviceCtx.GetMaybePublicIPAddress(),
+		serviceCtx.GetPublicPorts(),
+	)
+	if isDiscoveryOnly {
+		return serviceCtx, nil, nil
+	}
+
+	apiNodeInfo, err := getApiNodeObjFromNodeServiceCtx(serviceCtx, launchConfig)
+	if err != nil {
+		return nil, nil, stacktrace.Propagate(err, "An error occurred getting the node info API object from the service context of bootnode '%v'", serviceId)
+	}
+	apiNodeInfo.NodeFilesArtifactUUID = nodeFilesArtifactUuid
+
+	return serviceCtx, apiNodeInfo, nil
+}
+
+func getDiscoveryBootnodeContainerConfig(
+	launchConfig *nodeLaunchConfig,
+	nodeFilesArtifactUuid services.FilesArtifactUUID,
+	accountFilesArtifactUuid services.FilesArtifactUUID,
+) (*services.ContainerConfig, error) {
+	bootnodeCommand, err := newBootnodeCommandBuilder().withFlag(
+		"nodekey", path.Join(nodeFilesMountpointOnNodes, nodeKeyFilename),
+	).withFlag(
+		"addr", fmt.Sprintf(":%v", discoveryPortNum),
+	).withFlag(
+		"nat", "extip:"+privateIPAddressPlaceholder,
+	).build()
+	if err != nil {
+		return nil, stacktrace.Propagate(err, "An error occurred building the command that runs the discovery-only bootnode")
+	}
+	envVars, err := getBootnodeEnvVars(launchConfig)
+	if err != nil {
+		return nil, stacktrace.Propagate(err, "An error occurred getting the environment variables of the discovery-only bootnode")
+	}
+	filesArtifactMountpoints := map[services.FilesArtifactUUID]string{
+		nodeFilesArtifactUuid: nodeFilesMountpointOnNodes,
+	}
+	if accountFilesArtifactUuid != "" {
+		filesArtifactMountpoints[accountFilesArtifactUuid] = accountFilesMountpointOnNodes
+	}
+
+	containerConfig := services.NewContainerConfigBuilder(
+		discoveryBootnodeDockerImageName,
+	).WithUsedPorts(map[string]*services.PortSpec{
+		udpDiscoveryPortId: services.NewPortSpec(discoveryPortNum, services.PortProtocol_UDP),
+	}).WithEntrypointOverride(
+		getShellEntrypointArgs(bootnodeCommand),
+	).WithFiles(
+		filesArtifactMountpoints,
+	).WithEnvironmentVariableOverrides(
+		envVars,
+	).WithCPUAllocationMillicpus(
+		launchConfig.resourceDefaults.Bootnode.CpuMillicpus,
+	).WithMemoryAllocationMegabytes(
+		launchConfig.resourceDefaults.Bootnode.MemoryMegabytes,
+	).WithPrivateIPAddrPlaceholder(
+		privateIPAddressPlaceholder,
+	).Build()
+
+	return containerConfig, nil
+}
+
+// Discovery-only bootnodes are the only nodes of the module without an RPC port
+func isDiscoveryOnlyBootnode(serviceCtx *services.ServiceContext) bool {
+	_, found := serviceCtx.GetPrivatePorts()[rpcPortId]
+	return isBootnodeServiceId(serviceCtx.GetServiceID()) && !found
+}
+
+func getApiBootnodeObjFromServiceCtx(serviceCtx *services.ServiceContext, launchConfig *nodeLaunchConfig) (*ModuleAPIBootnodeInfo, error) {
+	nodeRecord, err := getNodeRecord(launchConfig.keySeed, serviceCtx.GetServiceID(), serviceCtx.GetPrivateIPAddress())
+	if err != nil {
+		return nil, stacktrace.Propagate(err, "An error occurred computing the record of bootnode '%v'", serviceCtx.GetServiceID())
+	}
+	return &ModuleAPIBootnodeInfo{
+		ServiceID:           serviceCtx.GetServiceID(),
+		IPAddrInsideNetwork: serviceCtx.GetPrivateIPAddress(),
+		IPAddrOnHostMachine: serviceCtx.GetMaybePublicIPAddress(),
+		UdpDiscoveryPortId:  udpDiscoveryPortId,
+		IsDiscoveryOnly:     launchConfig.bootnodeMode == discoveryOnlyBootnodeMode,
+		Enode:               nodeRecord.URLv4(),
+		Enr:                 nodeRecord.String(),
+	}, nil
+}
@@ -0,0 +1,333 @@
+package impl
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"github.com/ethereum/go-ethereum/crypto"
+	static_files_consts "github.com/kurtosis-tech/ethereum-kurtosis-module/kurtosis-module/static-files-consts"
+	"github.com/kurtosis-tech/kurtosis-sdk/api/golang/core/lib/services"
+	"github.com/kurtosis-tech/stacktrace"
+	"github.com/sirupsen/logrus"
+	"io/ioutil"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+const (
+	// The tools image has Clef, along with the Geth that imports the keys of the extra signers
+	clefDockerImageName = "ethereum/client-go:alltools-v1.10.8"
+
+	// Every signer gets its own Clef, whose service ID is the signer's with this prefix
+	clefServiceIdPrefix = "clef-"
+
+	clefHttpPortNum uint16 = 8550
+	clefHttpPortId         = "clefHttp"
+
+	clefConfigDirpath   = "/clef"
+	clefKeystoreDirpath = clefConfigDirpath + "/keystore"
+
+	clefFilesTempDirPattern    = "clef-files-"
+	clefFilesMountpointOnNodes = "/clef-files"
+	clefRulesFilename          = "rules.js"
+	// Clef only reads its passwords from a terminal or stdin, so each command that asks for some gets them from one of these
+	clefInitInputFilename   = "init-input"
+	clefSetpwInputFilename  = "setpw-input"
+	clefAttestInputFilename = "attest-input"
+	clefRunInputFilename    = "run-input"
+
+	// Clef refuses master passwords shorter than 10 characters
+	clefMasterPasswordNumBytes = 16
+
+	// Only used to check that Clef answers, which it does without asking the rules
+	clefVersionRpcCall = `{"jsonrpc":"2.0","method":"account_version","params":[],"id":1}`
+
+	// Approves listing the accounts, which Geth does to find the etherbase, and signing Clique headers, which is how the
+	// signer seals blocks; everything else is rejected, as nobody is there to approve it
+	clefRules = `function ApproveListing() {
+    return "Approve"
+}
+
+function ApproveSignData(req) {
+    if (req.content_type != "application/x-clique-header") {
+        return "Reject"
+    }
+    for (var i = 0; i < req.messages.length; i++) {
+        var message = req.messages[i]
+        if (message.name == "Clique header" && message.type == "clique") {
+            return "Approve"
+        }
+    }
+    return "Reject"
+}
+`
+)
+
+func applyDefaultsAndValidateSignerBackend(params *ModuleAPIExecuteArgs) error {
+	switch params.SignerBackend {
+	case "":
+		params.SignerBackend = keystoreSignerBackend
+		if params.RpcProfile == hardenedRpcProfile {
+			params.SignerBackend = clefSignerBackend
+		}
+	case keystoreSignerBackend:
+		if params.RpcProfile == hardenedRpcProfile {
+			return stacktrace.NewError("Signer backend '%v' unlocks the account of each signer, which RPC profile '%v' doesn't allow; use '%v'", keystoreSignerBackend, hardenedRpcProfile, clefSignerBackend)
+		}
+	case clefSignerBackend:
+		// Once blocks come from the Engine API, nothing is left for Clef to sign
+		if params.BlockProduction != cliqueBlockProduction {
+			return stacktrace.NewError("Signer backend '%v' is only supported with '%v' block production", clefSignerBackend, cliqueBlockProduction)
+		}
+	default:
+		return stacktrace.NewError(
+			"Unrecognized signer backend '%v'; valid values are '%v' and '%v'",
+			params.SignerBackend,
+			keystoreSignerBackend,
+			clefSignerBackend,
+		)
+	}
+	return nil
+}
+
+func getClefServiceId(signerServiceId services.ServiceID) services.ServiceID {
+	return services.ServiceID(clefServiceIdPrefix + string(signerServiceId))
+}
+
+func isClefServiceId(serviceId services.ServiceID) bool {
+	return strings.HasPrefix(string(serviceId), clefServiceIdPrefix)
+}
+
+// Returns the service ID of the signer that the given Clef signs for
+func getClefSignerServiceId(clefServiceId services.ServiceID) services.ServiceID {
+	return services.ServiceID(strings.TrimPrefix(string(clefServiceId), clefServiceIdPrefix))
+}
+
+// Signers that unlock their account get it from the keystore in their datadir, while the others only reach it through Clef
+func isAccountUnlockedOnNode(launchConfig *nodeLaunchConfig, serviceId services.ServiceID) bool {
+	isSigner := (serviceId == bootnodeServiceID && launchConfig.bootnodeMode != discoveryOnlyBootnodeMode) || isSignerNodeServiceId(serviceId)
+	return isSigner && launchConfig.signerBackend != clefSignerBackend
+}
+
+// Returns the URL that the given signer reaches its Clef on, which Geth calls its external signer
+func getClefUrl(launchConfig *nodeLaunchConfig, signerServiceId services.ServiceID) (string, error) {
+	clefIpAddr, found := launchConfig.clefIpAddrs[signerServiceId]
+	if !found {
+		return "", stacktrace.NewError("No Clef was found for signer '%v'", signerServiceId)
+	}
+	return fmt.Sprintf("http://%v:%v", clefIpAddr, clefHttpPortNum), nil
+}
+
+// Starts a Clef for every signer, waiting for all of them to answer, as Geth fails to start if its external signer doesn't
+// Returns the IPs of the Clefs by the service ID of their signer
+func startClefSigners(
+	enclaveCtx nodeEnclave,
+	launchConfig *nodeLaunchConfig,
+	maxConcurrency uint32,
+) (map[services.ServiceID]string, error) {
+	resultsMutex := &sync.Mutex{}
+	clefIpAddrs := map[services.ServiceID]string{}
+	err := runForEachNode(getSignerServiceIds(launchConfig), maxConcurrency, func(signerServiceId services.ServiceID) error {
+		clefServiceId := getClefServiceId(signerServiceId)
+		clefFilesArtifactUuid, err := uploadClefFiles(enclaveCtx, launchConfig, signerServiceId)
+		if err != nil {
+			return stacktrace.Propagate(err, "An error occurred uploading the files of Clef '%v'", clefServiceId)
+		}
+		containerConfig, err := getClefContainerConfig(launchConfig, signerServiceId, clefFilesArtifactUuid)
+		if err != nil {
+			return stacktrace.Propagate(err, "An error occurred getting the container config of Clef '%v'", clefServiceId)
+		}
+		serviceCtx, err := enclaveCtx.AddService(clefServiceId, containerConfig)
+		if err != nil {
+			return stacktrace.Propagate(err, "An error occurred adding Clef service '%v'", clefServiceId)
+		}
+		if err := enclaveCtx.WaitForHttpPostEndpointAvailability(clefServiceId, uint32(clefHttpPortNum), "", clefVersionRpcCall, waitEndpointInitialDelayMilliseconds, waitEndpointRetries, waitEndpointRetriesDelayMilliseconds, ""); err != nil {
+			return stacktrace.Propagate(err, "An error occurred waiting for Clef '%v' to start", clefServiceId)
+		}
+		logrus.Infof("Added Clef service '%v' for signer '%v' with IP %v", clefServiceId, signerServiceId, serviceCtx.GetPrivateIPAddress())
+
+		resultsMutex.Lock()
+		defer resultsMutex.Unlock()
+		clefIpAddrs[signerServiceId] = serviceCtx.GetPrivateIPAddress()
+		return nil
+	})
+	if err != nil {
+		return nil, stacktrace.Propagate(err, "An error occurred starting the Clef signers")
+	}
+	return clefIpAddrs, nil
+}
+
+// Writes the rules that Clef signs with, the stdin of each Clef command that asks for passwords and the account key of a
+// signer that doesn't use the account of the static files, then uploads them
+func uploadClefFiles(enclaveCtx filesUploader, launchConfig *nodeLaunchConfig, signerServiceId services.ServiceID) (services.FilesArtifactUUID, error) {
+	masterPasswordBytes := make([]byte, clefMasterPasswordNumBytes)
+	if _, err := rand.Read(masterPasswordBytes); err != nil {
+		return "", stacktrace.Propagate(err, "An error occurred generating the master password of the Clef of signer '%v'", signerServiceId)
+	}
+	masterPassword := hex.EncodeToString(masterPasswordBytes)
+	registerSecret(masterPassword)
+	// Geth reads only the first line of a --password file, so that's the password the keystores were written with
+	accountPassword := getPasswordFromFile(launchConfig.staticFiles.signerAccountPassword)
+	registerSecret(accountPassword)
+
+	tempDirpath, err := ioutil.TempDir("", clefFilesTempDirPattern)
+	if err != nil {
+		return "", stacktrace.Propagate(err, "An error occurred creating a temporary directory for the files of the Clef of signer '%v'", signerServiceId)
+	}
+	defer os.RemoveAll(tempDirpath)
+
+	clefFileContents := map[string]string{
+		clefRulesFilename:       clefRules,
+		clefInitInputFilename:   getClefInput(masterPassword, masterPassword),
+		clefSetpwInputFilename:  getClefInput(accountPassword, accountPassword, masterPassword),
+		clefAttestInputFilename: getClefInput(masterPassword),
+		clefRunInputFilename:    getClefInput(masterPassword),
+	}
+	signerAccountKey, err := getSignerAccountKey(launchConfig, signerServiceId)
+	if err != nil {
+		return "", stacktrace.Propagate(err, "An error occurred getting the account key of signer '%v'", signerServiceId)
+	}
+	if signerAccountKey != nil {
+		clefFileContents[signerAccountKeyFilename] = hex.EncodeToString(crypto.FromECDSA(signerAccountKey))
+	}
+	for filename, contents := range clefFileContents {
+		filepath := path.Join(tempDirpath, filename)
+		if err := ioutil.WriteFile(filepath, []byte(contents), nodeFilePerms); err != nil {
+			return "", stacktrace.Propagate(err, "An error occurred writing the Clef file of signer '%v' to '%v'", signerServiceId, filepath)
+		}
+	}
+
+	clefFilesArtifactUuid, err := enclaveCtx.UploadFiles(tempDirpath)
+	if err != nil {
+		return "", stacktrace.Propagate(err, "An error occurred uploading the Clef files of signer '%v'", signerServiceId)
+	}
+	return clefFilesArtifactUuid, nil
+}
+
+func getClefInput(lines ...string) string {
+	return strings.Join(lines, "\n") + "\n"
+}
+
+// Puts the account of the signer in Clef's keystore, stores its password in Clef's vault so the rules can sign with it,
+// attests the rules, and finally runs Clef
+func getClefContainerConfig(
+	launchConfig *nodeLaunchConfig,
+	signerServiceId services.ServiceID,
+	clefFilesArtifactUuid services.FilesArtifactUUID,
+) (*services.ContainerConfig, error) {
+	accountAddr, err := getSignerAccountAddress(launchConfig, signerServiceId)
+	if err != nil {
+		return nil, stacktrace.Propagate(err, "An error occurred getting the account address of signer '%v'", signerServiceId)
+	}
+	// Only the signer whose account is in the static files copies it from there, and the others import their key
+	keystoreCommand := fmt.Sprintf(
+		"mkdir -p %v && cp %v %v",
+		quoteShellValue(clefKeystoreDirpath),
+		quoteShellValue(getMountedPathOnNodeContainer(launchConfig.staticFiles.signerKeystoreFilename)),
+		quoteShellValue(clefKeystoreDirpath),
+	)
+	if !usesStaticSignerAccount(launchConfig, signerServiceId) {
+		keystoreCommand, err = newGethCommandBuilder(
+			clefDockerImageName,
+			"account",
+			"import",
+		).withFlag(
+			"keystore", clefKeystoreDirpath,
+		).withFlag(
+			"password", getMountedPathOnNodeContainer(static_files_consts.SignerAccountPasswordStaticFileName),
+		).withArg(
+			path.Join(clefFilesMountpointOnNodes, signerAccountKeyFilename),
+		).build()
+		if err != nil {
+			return nil, stacktrace.Propagate(err, "An error occurred building the command that imports the account of signer '%v'", signerServiceId)
+		}
+	}
+
+	initCommand, err := getClefCommandBuilder().withArg("init").build()
+	if err != nil {
+		return nil, stacktrace.Propagate(err, "An error occurred building the command that initializes Clef")
+	}
+	setpwCommand, err := getClefCommandBuilder().withArg("setpw").withArg(accountAddr).build()
+	if err != nil {
+		return nil, stacktrace.Propagate(err, "An error occurred building the command that stores the password of signer '%v' in Clef", signerServiceId)
+	}
+	rulesHash := sha256.Sum256([]byte(clefRules))
+	attestCommand, err := getClefCommandBuilder().withArg("attest").withArg(hex.EncodeToString(rulesHash[:])).build()
+	if err != nil {
+		return nil, stacktrace.Propagate(err, "An error occurred building the command that attests the Clef rules")
+	}
+	runCommand, err := getClefCommandBuilder().withFlag(
+		"keystore", clefKeystoreDirpath,
+	).withFlag(
+		"chainid", strconv.FormatUint(launchConfig.staticFiles.genesisChainId, 10),
+	).withBoolFlag(
+		"nousb",
+	).withBoolFlag(
+		"ipcdisable",
+	).withBoolFlag(
+		"http",
+	).withFlag(
+		"http.addr", "0.0.0.0",
+	).withFlag(
+		"http.port", strconv.Itoa(int(clefHttpPortNum)),
+	).withFlag(
+		"rules", path.Join(clefFilesMountpointOnNodes, clefRulesFilename),
+	).build()
+	if err != nil {
+		return nil, stacktrace.Propagate(err, "An error occurred building the command that runs Clef")
+	}
+
+	containerConfig := services.NewContainerConfigBuilder(
+		clefDockerImageName,
+	).WithUsedPorts(map[string]*services.PortSpec{
+		clefHttpPortId: services.NewPortSpec(clefHttpPortNum, services.PortProtocol_TCP),
+	}).WithEntrypointOverride(getShellEntrypointArgs(
+		keystoreCommand,
+		withStdinFile(initCommand, path.Join(clefFilesMountpointOnNodes, clefInitInputFilename)),
+		withStdinFile(setpwCommand, path.Join(clefFilesMountpointOnNodes, clefSetpwInputFilename)),
+		withStdinFile(attestCommand, path.Join(clefFilesMountpointOnNodes, clefAttestInputFilename)),
+		withStdinFile(runCommand, path.Join(clefFilesMountpointOnNodes, clefRunInputFilename)),
+	)).WithFiles(map[services.FilesArtifactUUID]string{
+		launchConfig.staticFiles.filesArtifactUuid: staticFilesMountpointOnNodes,
+		clefFilesArtifactUuid:                      clefFilesMountpointOnNodes,
+	}).Build()
+
+	return containerConfig, nil
+}
+
+// Returns a builder for a Clef command with the flags that every Clef command takes, which come before the subcommand
+func getClefCommandBuilder() *commandBuilder {
+	return newClefCommandBuilder().withFlag(
+		"configdir", clefConfigDirpath,
+	).withBoolFlag(
+		"suppress-bootwarn",
+	)
+}
+
+func getClefSignerInfo(launchConfig *nodeLaunchConfig) (map[services.ServiceID]*ModuleAPIClefSignerInfo, error) {
+	if launchConfig.signerBackend != clefSignerBackend {
+		return nil, nil
+	}
+	result := map[services.ServiceID]*ModuleAPIClefSignerInfo{}
+	for _, signerServiceId := range getSignerServiceIds(launchConfig) {
+		clefIpAddr, found := launchConfig.clefIpAddrs[signerServiceId]
+		if !found {
+			return nil, stacktrace.NewError("No Clef was found for signer '%v'", signerServiceId)
+		}
+		accountAddr, err := getSignerAccountAddress(launchConfig, signerServiceId)
+		if err != nil {
+			return nil, stacktrace.Propagate(err, "An error occurred getting the account address of signer '%v'", signerServiceId)
+		}
+		result[signerServiceId] = &ModuleAPIClefSignerInfo{
+			ServiceID:           getClefServiceId(signerServiceId),
+			IPAddrInsideNetwork: clefIpAddr,
+			HttpPortId:          clefHttpPortId,
+			AccountAddress:      accountAddr,
+		}
+	}
+	return result, nil
+}
@@ -0,0 +1,244 @@
+package impl
+
+import (
+	"crypto/ecdsa"
+	"encoding/hex"
+	"fmt"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/p2p/dnsdisc"
+	"github.com/ethereum/go-ethereum/p2p/enode"
+	"github.com/kurtosis-tech/kurtosis-sdk/api/golang/core/lib/services"
+	"github.com/kurtosis-tech/stacktrace"
+	"github.com/sirupsen/logrus"
+	"io/ioutil"
+	"os"
+	"path"
+	"sort"
+	"strings"
+)
+
+const (
+	dnsServerDockerImageName = "coredns/coredns:1.8.4"
+	dnsServerServiceID       = "dns-discovery"
+
+	dnsPortNum   uint16 = 53
+	tcpDnsPortId        = "tcpDns"
+	udpDnsPortId        = "udpDns"
+
+	// Mixed into the key that signs the tree, so it doesn't collide with the other keys that the module derives
+	dnsTreeSigningKeySeed = "ethereum-kurtosis-module-dns-tree-signing-key"
+	// The tree is only served inside the enclave, so its domain doesn't have to be registered anywhere
+	dnsTreeDomain       = "nodes.ethereum.kurtosis"
+	dnsTreeSequence     = 1
+	dnsRecordTtlSeconds = 60
+
+	dnsFilesTempDirPattern    = "dns-files-"
+	dnsFilesMountpointOnNodes = "/dns-files"
+	corefileFilename          = "Corefile"
+	dnsZoneFilename           = "db.zone"
+
+	// A TXT record holds strings of at most 255 bytes, which resolvers join back together
+	maxDnsTxtStringLength = 255
+
+	resolvConfFilepath = "/etc/resolv.conf"
+)
+
+// Derives the key that signs the tree from the key seed, so that the tree URL is known before the tree exists and is the
+// same on every run
+func getDnsTreeSigningKey(keySeed string) (*ecdsa.PrivateKey, error) {
+	signingKey, err := deriveKey(dnsTreeSigningKeySeed, keySeed, "")
+	if err != nil {
+		return nil, stacktrace.Propagate(err, "An error occurred deriving the key that signs the DNS discovery tree")
+	}
+	return signingKey, nil
+}
+
+// Returns the URL that Geth's --discovery.dns flag takes, which only depends on the signing key and the domain
+func getDnsTreeUrl(keySeed string) (string, error) {
+	signingKey, err := getDnsTreeSigningKey(keySeed)
+	if err != nil {
+		return "", stacktrace.Propagate(err, "An error occurred getting the key that signs the DNS discovery tree")
+	}
+	emptyTree, err := dnsdisc.MakeTree(dnsTreeSequence, []*enode.Node{}, []string{})
+	if err != nil {
+		return "", stacktrace.Propagate(err, "An error occurred creating an empty DNS discovery tree")
+	}
+	treeUrl, err := emptyTree.Sign(signingKey, dnsTreeDomain)
+	if err != nil {
+		return "", stacktrace.Propagate(err, "An error occurred signing an empty DNS discovery tree")
+	}
+	return treeUrl, nil
+}
+
+// Signs a tree with the records of the given nodes, and starts a DNS server that serves it inside the enclave
+func startDnsDiscoveryServer(
+	enclaveCtx nodeEnclave,
+	keySeed string,
+	nodeServiceCtxs map[services.ServiceID]*services.ServiceContext,
+) (*services.ServiceContext, error) {
+	nodeRecords := []*enode.Node{}
+	for _, serviceId := range getSortedServiceIds(nodeServiceCtxs) {
+		nodeRecord, err := getNodeRecord(keySeed, serviceId, nodeServiceCtxs[serviceId].GetPrivateIPAddress())
+		if err != nil {
+			return nil, stacktrace.Propagate(err, "An error occurred computing the record of node '%v'", serviceId)
+		}
+		nodeRecords = append(nodeRecords, nodeRecord)
+	}
+	tree, err := dnsdisc.MakeTree(dnsTreeSequence, nodeRecords, []string{})
+	if err != nil {
+		return nil, stacktrace.Propagate(err, "An error occurred creating the DNS discovery tree of nodes %v", getSortedServiceIds(nodeServiceCtxs))
+	}
+	signingKey, err := getDnsTreeSigningKey(keySeed)
+	if err != nil {
+		return nil, stacktrace.Propagate(err, "An error occurred getting the key that signs the DNS discovery tree")
+	}
+	treeUrl, err := tree.Sign(signingKey, dnsTreeDomain)
+	if err != nil {
+		return nil, stacktrace.Propagate(err, "An error occurred signing the DNS discovery tree")
+	}
+
+	dnsFilesArtifactUuid, err := uploadDnsFiles(enclaveCtx, tree.ToTXT(dnsTreeDomain))
+	if err != nil {
+		return nil, stacktrace.Propagate(err, "An error occurred uploading the files of the DNS server")
+	}
+	serviceCtx, err := enclaveCtx.AddService(dnsServerServiceID, getDnsServerContainerConfig(dnsFilesArtifactUuid))
+	if err != nil {
+		return nil, stacktrace.Propagate(err, "An error occurred adding the DNS server service")
+	}
+
+	logrus.Infof("Serving DNS discovery tree '%v' of %v nodes from DNS server with IP %v", treeUrl, len(nodeRecords), serviceCtx.GetPrivateIPAddress())
+	return serviceCtx, nil
+}
+
+// Makes the given nodes, which were started before the DNS server, resolve the tree through it
+// Geth notices the change on its own, and keeps retrying the tree root until then
+func pointNodesAtDnsServer(
+	nodeServiceCtxs map[services.ServiceID]*services.ServiceContext,
+	dnsServerIpAddr string,
+	maxConcurrency uint32,
+) error {
+	err := runForEachNode(getSortedServiceIds(nodeServiceCtxs), maxConcurrency, func(serviceId services.ServiceID) error {
+		cmd := getResolvConfCommand(dnsServerIpAddr)
+		exitCode, logOutput, err := nodeServiceCtxs[serviceId].ExecCommand(getShellEntrypointArgs(cmd))
+		if err != nil {
+			return stacktrace.Propagate(err, "Executing command '%v' on node '%v' returned an error", cmd, serviceId)
+		}
+		if exitCode != execCommandSuccessExitCode {
+			return stacktrace.NewError(
+				"Executing command '%v' on node '%v' returned non-%v exit code '%v' with the following logs:\n%v",
+				cmd,
+				serviceId,
+				execCommandSuccessExitCode,
+				exitCode,
+				logOutput,
+			)
+		}
+		return nil
+	})
+	if err != nil {
+		return stacktrace.Propagate(err, "An error occurred pointing the nodes at DNS server '%v'", dnsServerIpAddr)
+	}
+	return nil
+}
+
+func getResolvConfCommand(dnsServerIpAddr string) string {
+	return fmt.Sprintf("echo %v > %v", quoteShellValue("nameserver "+dnsServerIpAddr), quoteShellValue(resolvConfFilepath))
+}
+
+// Returns the command that makes a node resolve names through the DNS server, or an empty string if the network doesn't
+// use DNS discovery or the DNS server isn't up yet, in which case the node gets pointed at it later
+func getDnsServerResolverCommand(launchConfig *nodeLaunchConfig) string {
+	if launchConfig.nodeDiscovery != dnsNodeDiscovery || launchConfig.dnsServerIpAddr == "" {
+		return ""
+	}
+	return getResolvConfCommand(launchConfig.dnsServerIpAddr)
+}
+
+func getDnsDiscoveryInfo(keySeed string, dnsServerServiceCtx *services.ServiceContext) (*ModuleAPIDnsDiscoveryInfo, error) {
+	treeUrl, err := getDnsTreeUrl(keySeed)
+	if err != nil {
+		return nil, stacktrace.Propagate(err, "An error occurred getting the URL of the DNS discovery tree")
+	}
+	signingKey, err := getDnsTreeSigningKey(keySeed)
+	if err != nil {
+		return nil, stacktrace.Propagate(err, "An error occurred getting the key that signs the DNS discovery tree")
+	}
+	return &ModuleAPIDnsDiscoveryInfo{
+		ServiceID:           dnsServerServiceCtx.GetServiceID(),
+		IPAddrInsideNetwork: dnsServerServiceCtx.GetPrivateIPAddress(),
+		TreeURL:             treeUrl,
+		SigningKey:          hex.EncodeToString(crypto.FromECDSA(signingKey)),
+	}, nil
+}
+
+// Writes the CoreDNS config and a zone file holding the given TXT records, then uploads them
+func uploadDnsFiles(enclaveCtx filesUploader, txtRecords map[string]string) (services.FilesArtifactUUID, error) {
+	tempDirpath, err := ioutil.TempDir("", dnsFilesTempDirPattern)
+	if err != nil {
+		return "", stacktrace.Propagate(err, "An error occurred creating a temporary directory for the DNS files")
+	}
+	defer os.RemoveAll(tempDirpath)
+
+	corefile := fmt.Sprintf(
+		"%v:%v {\n    file %v\n}\n",
+		dnsTreeDomain,
+		dnsPortNum,
+		path.Join(dnsFilesMountpointOnNodes, dnsZoneFilename),
+	)
+	corefileFilepath := path.Join(tempDirpath, corefileFilename)
+	if err := ioutil.WriteFile(corefileFilepath, []byte(corefile), nodeFilePerms); err != nil {
+		return "", stacktrace.Propagate(err, "An error occurred writing the CoreDNS config to '%v'", corefileFilepath)
+	}
+
+	zoneLines := []string{
+		fmt.Sprintf("$ORIGIN %v.", dnsTreeDomain),
+		fmt.Sprintf("@ %v IN SOA ns.%v. admin.%v. %v 7200 3600 1209600 %v", dnsRecordTtlSeconds, dnsTreeDomain, dnsTreeDomain, dnsTreeSequence, dnsRecordTtlSeconds),
+	}
+	recordNames := []string{}
+	for recordName := range txtRecords {
+		recordNames = append(recordNames, recordName)
+	}
+	sort.Strings(recordNames)
+	for _, recordName := range recordNames {
+		quotedStrings := []string{}
+		for _, txtString := range splitDnsTxtValue(txtRecords[recordName]) {
+			quotedStrings = append(quotedStrings, fmt.Sprintf("%q", txtString))
+		}
+		zoneLines = append(zoneLines, fmt.Sprintf("%v. %v IN TXT %v", recordName, dnsRecordTtlSeconds, strings.Join(quotedStrings, " ")))
+	}
+	zoneFilepath := path.Join(tempDirpath, dnsZoneFilename)
+	if err := ioutil.WriteFile(zoneFilepath, []byte(strings.Join(zoneLines, "\n")+"\n"), nodeFilePerms); err != nil {
+		return "", stacktrace.Propagate(err, "An error occurred writing the DNS zone to '%v'", zoneFilepath)
+	}
+
+	dnsFilesArtifactUuid, err := enclaveCtx.UploadFiles(tempDirpath)
+	if err != nil {
+		return "", stacktrace.Propagate(err, "An error occurred uploading the DNS files")
+	}
+	return dnsFilesArtifactUuid, nil
+}
+
+func splitDnsTxtValue(value string) []string {
+	result := []string{}
+	for len(value) > maxDnsTxtStringLength {
+		result = append(result, value[:maxDnsTxtStringLength])
+		value = value[maxDnsTxtStringLength:]
+	}
+	return append(result, value)
+}
+
+func getDnsServerContainerConfig(dnsFilesArtifactUuid services.FilesArtifactUUID) *services.ContainerConfig {
+	return services.NewContainerConfigBuilder(
+		dnsServerDockerImageName,
+	).WithUsedPorts(map[string]*services.PortSpec{
+		// Answers too big for UDP get retried over TCP, which the bigger tree entries can need
+		tcpDnsPortId: services.NewPortSpec(dnsPortNum, services.PortProtocol_TCP),
+		udpDnsPortId: services.NewPortSpec(dnsPortNum, services.PortProtocol_UDP),
+	}).WithEntrypointOverride([]string{
+		"/coredns",
+		"-conf",
+		path.Join(dnsFilesMountpointOnNodes, corefileFilename),
+	}).WithFiles(map[services.FilesArtifactUUID]string{
+		dnsFilesArtifactUuid: dnsFilesMountpointOnNodes,
+	}).Build()
+}
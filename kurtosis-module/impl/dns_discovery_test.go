@@ -0,0 +1,136 @@
+package impl
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/p2p/dnsdisc"
+	"github.com/ethereum/go-ethereum/p2p/enode"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	// Enough records for the branches of the tree to hold more than one TXT string
+	testDnsTreeNodeCount = 20
+)
+
+var quotedDnsTxtStringRegex = regexp.MustCompile(`"(?:[^"\\]|\\.)*"`)
+
+func TestGetDnsTreeUrl(t *testing.T) {
+	tests := []struct {
+		name    string
+		keySeed string
+	}{
+		{
+			name: "no key seed",
+		},
+		{
+			name:    "key seed",
+			keySeed: testKeySeed,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			treeUrl, err := getDnsTreeUrl(test.keySeed)
+			require.NoError(t, err)
+			signingKey, err := getDnsTreeSigningKey(test.keySeed)
+			require.NoError(t, err)
+
+			domain, publicKey, err := dnsdisc.ParseURL(treeUrl)
+			require.NoError(t, err)
+			require.Equal(t, dnsTreeDomain, domain)
+			require.Equal(t, crypto.PubkeyToAddress(signingKey.PublicKey), crypto.PubkeyToAddress(*publicKey))
+		})
+	}
+}
+
+func TestSplitDnsTxtValue(t *testing.T) {
+	tests := []struct {
+		name                string
+		valueLength         int
+		expectedPartLengths []int
+	}{
+		{
+			name:                "empty",
+			valueLength:         0,
+			expectedPartLengths: []int{0},
+		},
+		{
+			name:                "one string",
+			valueLength:         maxDnsTxtStringLength,
+			expectedPartLengths: []int{maxDnsTxtStringLength},
+		},
+		{
+			name:                "one byte over one string",
+			valueLength:         maxDnsTxtStringLength + 1,
+			expectedPartLengths: []int{maxDnsTxtStringLength, 1},
+		},
+		{
+			name:                "two strings",
+			valueLength:         2 * maxDnsTxtStringLength,
+			expectedPartLengths: []int{maxDnsTxtStringLength, maxDnsTxtStringLength},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			value := strings.Repeat("a", test.valueLength)
+			parts := splitDnsTxtValue(value)
+			partLengths := []int{}
+			for _, part := range parts {
+				partLengths = append(partLengths, len(part))
+			}
+			require.Equal(t, test.expectedPartLengths, partLengths)
+			require.Equal(t, value, strings.Join(parts, ""))
+		})
+	}
+}
+
+// Every TXT record of the tree must be in the zone file, in strings that resolvers join back into the record
+func TestUploadDnsFiles(t *testing.T) {
+	nodeRecords := []*enode.Node{}
+	for i := 1; i <= testDnsTreeNodeCount; i++ {
+		nodeRecord, err := getNodeRecord("", getChildEthNodeServiceId(i), fmt.Sprintf("%v%v", fakeEnclaveIpAddrPrefix, i))
+		require.NoError(t, err)
+		nodeRecords = append(nodeRecords, nodeRecord)
+	}
+	tree, err := dnsdisc.MakeTree(dnsTreeSequence, nodeRecords, []string{})
+	require.NoError(t, err)
+	signingKey, err := getDnsTreeSigningKey("")
+	require.NoError(t, err)
+	_, err = tree.Sign(signingKey, dnsTreeDomain)
+	require.NoError(t, err)
+	txtRecords := tree.ToTXT(dnsTreeDomain)
+
+	enclave := newFakeEnclave()
+	dnsFilesArtifactUuid, err := uploadDnsFiles(enclave, txtRecords)
+	require.NoError(t, err)
+	zone, found := enclave.getUploadedFile(dnsFilesArtifactUuid, dnsZoneFilename)
+	require.True(t, found)
+
+	zoneTxtRecords := map[string]string{}
+	isAnyRecordSplit := false
+	for _, zoneLine := range strings.Split(strings.TrimSpace(zone), "\n") {
+		lineFields := strings.SplitN(zoneLine, " ", 5)
+		if len(lineFields) < 5 || lineFields[3] != "TXT" {
+			continue
+		}
+		recordName := strings.TrimSuffix(lineFields[0], ".")
+		quotedStrings := quotedDnsTxtStringRegex.FindAllString(lineFields[4], -1)
+		require.Equal(t, lineFields[4], strings.Join(quotedStrings, " "), "TXT record '%v' isn't a list of quoted strings", recordName)
+		isAnyRecordSplit = isAnyRecordSplit || len(quotedStrings) > 1
+		value := ""
+		for _, quotedString := range quotedStrings {
+			txtString, err := strconv.Unquote(quotedString)
+			require.NoError(t, err, "TXT record '%v' has invalid string '%v'", recordName, quotedString)
+			require.LessOrEqual(t, len(txtString), maxDnsTxtStringLength, "TXT record '%v' has a string that's too long", recordName)
+			value += txtString
+		}
+		zoneTxtRecords[recordName] = value
+	}
+	require.Equal(t, txtRecords, zoneTxtRecords)
+	require.True(t, isAnyRecordSplit, "No TXT record of the tree was long enough to be split")
+}
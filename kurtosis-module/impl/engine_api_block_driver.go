@@ -0,0 +1,382 @@
+package impl
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"github.com/kurtosis-tech/kurtosis-sdk/api/golang/core/lib/services"
+	"github.com/kurtosis-tech/stacktrace"
+	"github.com/sirupsen/logrus"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	engineApiJwtSecretFilename   = "jwtsecret"
+	engineApiJwtSecretNumBytes   = 32
+	engineApiJwtTempDirPattern   = "engine-api-jwt-"
+	engineApiJwtSecretFilePerms  = 0644
+	engineApiJwtHeaderJson       = `{"alg":"HS256","typ":"JWT"}`
+	engineApiAuthorizationHeader = "Authorization"
+	engineApiBearerPrefix        = "Bearer "
+
+	engineApiForkchoiceUpdatedMethod = "engine_forkchoiceUpdatedV1"
+	engineApiGetPayloadMethod        = "engine_getPayloadV1"
+	engineApiNewPayloadMethod        = "engine_newPayloadV1"
+	ethGetBlockByNumberMethod        = "eth_getBlockByNumber"
+	latestBlockTag                   = "latest"
+
+	engineApiValidPayloadStatus = "VALID"
+
+	// How long the driver gives the producer to fill the payload between requesting it and collecting it
+	engineApiPayloadBuildTime = 500 * time.Millisecond
+
+	prevRandaoNumBytes = 32
+	hexPrefix          = "0x"
+	hexBase            = 16
+	uint64BitSize      = 64
+)
+
+// Drives a post-merge network without any beacon client, by asking a producer node to build payloads over the Engine API
+// and then importing each payload into every node of the network
+type engineApiBlockDriver struct {
+	rpcTransport      rpcTransport
+	producerServiceId services.ServiceID
+
+	// IP addresses of every node that should import the produced blocks, including the producer
+	// Guarded by the mutex, because nodes can be added and removed while the driver runs
+	nodeIpAddrs map[services.ServiceID]string
+	mutex       *sync.Mutex
+
+	jwtSecret    []byte
+	feeRecipient string
+	slotTime     time.Duration
+
+	headBlockHash string
+	headTimestamp uint64
+
+	stopChan chan struct{}
+}
+
+func newEngineApiBlockDriver(
+	rpcTransport rpcTransport,
+	producerServiceId services.ServiceID,
+	nodeIpAddrs map[services.ServiceID]string,
+	jwtSecret []byte,
+	feeRecipient string,
+	slotTime time.Duration,
+) *engineApiBlockDriver {
+	return &engineApiBlockDriver{
+		rpcTransport:      rpcTransport,
+		producerServiceId: producerServiceId,
+		nodeIpAddrs:       nodeIpAddrs,
+		mutex:             &sync.Mutex{},
+		jwtSecret:         jwtSecret,
+		feeRecipient:      feeRecipient,
+		slotTime:          slotTime,
+		stopChan:          make(chan struct{}),
+	}
+}
+
+// Reads the producer's current head and starts producing a block every slot in the background
+func (driver *engineApiBlockDriver) start() error {
+	producerIpAddr, found := driver.nodeIpAddrs[driver.producerServiceId]
+	if !found {
+		return stacktrace.NewError("The block producer '%v' isn't one of the nodes driven by the Engine API block driver; this is a bug with this module", driver.producerServiceId)
+	}
+
+	headBlockResponse := new(EthAPIBlockResponse)
+	if err := sendEngineApiRpcCall(driver.rpcTransport, producerIpAddr, driver.jwtSecret, ethGetBlockByNumberMethod, []interface{}{latestBlockTag, false}, headBlockResponse); err != nil {
+		return stacktrace.Propagate(err, "An error occurred getting the head block of block producer '%v'", driver.producerServiceId)
+	}
+	if headBlockResponse.Error != nil {
+		return stacktrace.NewError("Block producer '%v' returned an error getting its head block: %v", driver.producerServiceId, headBlockResponse.Error.Message)
+	}
+	if headBlockResponse.Result == nil {
+		return stacktrace.NewError("Block producer '%v' didn't return a head block", driver.producerServiceId)
+	}
+	headTimestamp, err := parseHexUint64(headBlockResponse.Result.Timestamp)
+	if err != nil {
+		return stacktrace.Propagate(err, "An error occurred parsing the timestamp of the head block of block producer '%v'", driver.producerServiceId)
+	}
+	driver.headBlockHash = headBlockResponse.Result.Hash
+	driver.headTimestamp = headTimestamp
+
+	go driver.run()
+	logrus.Infof(
+		"Started the Engine API block driver on producer '%v', producing a block every %v on top of head '%v'",
+		driver.producerServiceId,
+		driver.slotTime,
+		driver.headBlockHash,
+	)
+	return nil
+}
+
+func (driver *engineApiBlockDriver) stop() {
+	close(driver.stopChan)
+}
+
+// Makes the node with the given ID import the blocks produced from now on, replacing its IP if the node was already driven
+func (driver *engineApiBlockDriver) setNode(serviceId services.ServiceID, ipAddr string) {
+	driver.mutex.Lock()
+	defer driver.mutex.Unlock()
+	driver.nodeIpAddrs[serviceId] = ipAddr
+}
+
+func (driver *engineApiBlockDriver) removeNode(serviceId services.ServiceID) {
+	driver.mutex.Lock()
+	defer driver.mutex.Unlock()
+	delete(driver.nodeIpAddrs, serviceId)
+}
+
+func (driver *engineApiBlockDriver) getNodeIpAddrs() map[services.ServiceID]string {
+	driver.mutex.Lock()
+	defer driver.mutex.Unlock()
+	result := map[services.ServiceID]string{}
+	for serviceId, ipAddr := range driver.nodeIpAddrs {
+		result[serviceId] = ipAddr
+	}
+	return result
+}
+
+func (driver *engineApiBlockDriver) run() {
+	ticker := time.NewTicker(driver.slotTime)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-driver.stopChan:
+			logrus.Infof("Stopped the Engine API block driver")
+			return
+		case <-ticker.C:
+			if err := driver.produceBlock(); err != nil {
+				logrus.Errorf("The Engine API block driver failed to produce a block on top of head '%v':\n%v", driver.headBlockHash, err)
+			}
+		}
+	}
+}
+
+func (driver *engineApiBlockDriver) produceBlock() error {
+	nodeIpAddrs := driver.getNodeIpAddrs()
+	producerIpAddr, found := nodeIpAddrs[driver.producerServiceId]
+	if !found {
+		return stacktrace.NewError("Producer '%v' is no longer one of the nodes driven by the Engine API block driver", driver.producerServiceId)
+	}
+
+	timestamp := uint64(time.Now().Unix())
+	if timestamp <= driver.headTimestamp {
+		timestamp = driver.headTimestamp + 1
+	}
+	prevRandao := make([]byte, prevRandaoNumBytes)
+	if _, err := rand.Read(prevRandao); err != nil {
+		return stacktrace.Propagate(err, "An error occurred generating the prevRandao value")
+	}
+	payloadAttributes := map[string]string{
+		"timestamp":             hexPrefix + strconv.FormatUint(timestamp, hexBase),
+		"prevRandao":            hexPrefix + hex.EncodeToString(prevRandao),
+		"suggestedFeeRecipient": driver.feeRecipient,
+	}
+
+	payloadId, err := driver.updateForkchoice(producerIpAddr, driver.headBlockHash, payloadAttributes)
+	if err != nil {
+		return stacktrace.Propagate(err, "An error occurred asking producer '%v' to build a payload", driver.producerServiceId)
+	}
+	if payloadId == "" {
+		return stacktrace.NewError("Producer '%v' didn't start building a payload on top of head '%v'", driver.producerServiceId, driver.headBlockHash)
+	}
+
+	time.Sleep(engineApiPayloadBuildTime)
+
+	getPayloadResponse := new(EngineAPIGetPayloadResponse)
+	if err := sendEngineApiRpcCall(driver.rpcTransport, producerIpAddr, driver.jwtSecret, engineApiGetPayloadMethod, []interface{}{payloadId}, getPayloadResponse); err != nil {
+		return stacktrace.Propagate(err, "An error occurred getting payload '%v' from producer '%v'", payloadId, driver.producerServiceId)
+	}
+	if getPayloadResponse.Error != nil {
+		return stacktrace.NewError("Producer '%v' returned an error getting payload '%v': %v", driver.producerServiceId, payloadId, getPayloadResponse.Error.Message)
+	}
+	payloadHeader := new(EngineAPIExecutionPayloadHeader)
+	if err := json.Unmarshal(getPayloadResponse.Result, payloadHeader); err != nil {
+		return stacktrace.Propagate(err, "An error occurred parsing payload '%v' returned by producer '%v'", payloadId, driver.producerServiceId)
+	}
+	payloadTimestamp, err := parseHexUint64(payloadHeader.Timestamp)
+	if err != nil {
+		return stacktrace.Propagate(err, "An error occurred parsing the timestamp of payload '%v'", payloadId)
+	}
+
+	// The producer has to accept its own block, otherwise the network can't move forward...
+	if err := driver.importPayload(driver.producerServiceId, producerIpAddr, getPayloadResponse.Result, payloadHeader.BlockHash); err != nil {
+		return stacktrace.Propagate(err, "An error occurred importing block '%v' into producer '%v'", payloadHeader.BlockHash, driver.producerServiceId)
+	}
+	driver.headBlockHash = payloadHeader.BlockHash
+	driver.headTimestamp = payloadTimestamp
+
+	// ...whereas a node that lags behind will catch up on a later block
+	for serviceId, ipAddr := range nodeIpAddrs {
+		if serviceId == driver.producerServiceId {
+			continue
+		}
+		if err := driver.importPayload(serviceId, ipAddr, getPayloadResponse.Result, payloadHeader.BlockHash); err != nil {
+			logrus.Warnf("Node '%v' couldn't import block '%v':\n%v", serviceId, payloadHeader.BlockHash, err)
+		}
+	}
+
+	logrus.Debugf("The Engine API block driver produced block '%v' with hash '%v'", payloadHeader.BlockNumber, payloadHeader.BlockHash)
+	return nil
+}
+
+// Hands the payload to the node and then makes it the node's head
+func (driver *engineApiBlockDriver) importPayload(serviceId services.ServiceID, ipAddr string, payload json.RawMessage, blockHash string) error {
+	newPayloadResponse := new(EngineAPINewPayloadResponse)
+	if err := sendEngineApiRpcCall(driver.rpcTransport, ipAddr, driver.jwtSecret, engineApiNewPayloadMethod, []interface{}{payload}, newPayloadResponse); err != nil {
+		return stacktrace.Propagate(err, "An error occurred sending new payload '%v' to node '%v'", blockHash, serviceId)
+	}
+	if newPayloadResponse.Error != nil {
+		return stacktrace.NewError("Node '%v' returned an error for new payload '%v': %v", serviceId, blockHash, newPayloadResponse.Error.Message)
+	}
+	if newPayloadResponse.Result == nil || newPayloadResponse.Result.Status != engineApiValidPayloadStatus {
+		return stacktrace.NewError("Node '%v' didn't consider new payload '%v' valid; got status '%+v'", serviceId, blockHash, newPayloadResponse.Result)
+	}
+
+	if _, err := driver.updateForkchoice(ipAddr, blockHash, nil); err != nil {
+		return stacktrace.Propagate(err, "An error occurred setting block '%v' as the head of node '%v'", blockHash, serviceId)
+	}
+	return nil
+}
+
+// Sets the head of the node at the given IP, returning the ID of the payload that the node started building if attributes were provided
+func (driver *engineApiBlockDriver) updateForkchoice(ipAddr string, headBlockHash string, payloadAttributes map[string]string) (string, error) {
+	// There's no beacon chain to finalize anything, so the head is also considered safe and final
+	forkchoiceState := map[string]string{
+		"headBlockHash":      headBlockHash,
+		"safeBlockHash":      headBlockHash,
+		"finalizedBlockHash": headBlockHash,
+	}
+	var rpcParams []interface{}
+	if payloadAttributes == nil {
+		rpcParams = []interface{}{forkchoiceState, nil}
+	} else {
+		rpcParams = []interface{}{forkchoiceState, payloadAttributes}
+	}
+
+	forkchoiceResponse := new(EngineAPIForkchoiceUpdatedResponse)
+	if err := sendEngineApiRpcCall(driver.rpcTransport, ipAddr, driver.jwtSecret, engineApiForkchoiceUpdatedMethod, rpcParams, forkchoiceResponse); err != nil {
+		return "", stacktrace.Propagate(err, "An error occurred sending the forkchoice update to the node with IP '%v'", ipAddr)
+	}
+	if forkchoiceResponse.Error != nil {
+		return "", stacktrace.NewError("The node with IP '%v' returned an error for the forkchoice update: %v", ipAddr, forkchoiceResponse.Error.Message)
+	}
+	if forkchoiceResponse.Result == nil || forkchoiceResponse.Result.PayloadStatus.Status != engineApiValidPayloadStatus {
+		return "", stacktrace.NewError("The node with IP '%v' didn't consider head '%v' valid; got '%+v'", ipAddr, headBlockHash, forkchoiceResponse.Result)
+	}
+	if forkchoiceResponse.Result.PayloadID == nil {
+		return "", nil
+	}
+	return *forkchoiceResponse.Result.PayloadID, nil
+}
+
+// Generates a JWT secret and uploads it so that it can be mounted on the nodes
+func createJwtSecret(enclaveCtx moduleEnclave) ([]byte, services.FilesArtifactUUID, error) {
+	jwtSecret := make([]byte, engineApiJwtSecretNumBytes)
+	if _, err := rand.Read(jwtSecret); err != nil {
+		return nil, "", stacktrace.Propagate(err, "An error occurred generating the JWT secret")
+	}
+	jwtSecretArtifactUuid, err := uploadJwtSecret(enclaveCtx, jwtSecret)
+	if err != nil {
+		return nil, "", stacktrace.Propagate(err, "An error occurred uploading the JWT secret")
+	}
+	return jwtSecret, jwtSecretArtifactUuid, nil
+}
+
+// Uploads the given JWT secret in the hex format that Geth reads
+func uploadJwtSecret(enclaveCtx moduleEnclave, jwtSecret []byte) (services.FilesArtifactUUID, error) {
+	registerSecret(hex.EncodeToString(jwtSecret))
+	tempDirpath, err := ioutil.TempDir("", engineApiJwtTempDirPattern)
+	if err != nil {
+		return "", stacktrace.Propagate(err, "An error occurred creating a temporary directory for the JWT secret")
+	}
+	defer os.RemoveAll(tempDirpath)
+
+	jwtSecretFilepath := path.Join(tempDirpath, engineApiJwtSecretFilename)
+	if err := ioutil.WriteFile(jwtSecretFilepath, []byte(hexPrefix+hex.EncodeToString(jwtSecret)), engineApiJwtSecretFilePerms); err != nil {
+		return "", stacktrace.Propagate(err, "An error occurred writing the JWT secret to '%v'", jwtSecretFilepath)
+	}
+
+	jwtSecretArtifactUuid, err := enclaveCtx.UploadFiles(tempDirpath)
+	if err != nil {
+		return "", stacktrace.Propagate(err, "An error occurred uploading the JWT secret from '%v'", tempDirpath)
+	}
+	return jwtSecretArtifactUuid, nil
+}
+
+// Builds the HS256 token that Geth expects on every call to its authenticated RPC endpoint
+func createJwt(jwtSecret []byte) string {
+	encoding := base64.RawURLEncoding
+	claimsJson := fmt.Sprintf(`{"iat":%v}`, time.Now().Unix())
+	unsignedToken := encoding.EncodeToString([]byte(engineApiJwtHeaderJson)) + "." + encoding.EncodeToString([]byte(claimsJson))
+
+	mac := hmac.New(sha256.New, jwtSecret)
+	mac.Write([]byte(unsignedToken))
+	return unsignedToken + "." + encoding.EncodeToString(mac.Sum(nil))
+}
+
+func sendEngineApiRpcCall(transport rpcTransport, privateIpAddr string, jwtSecret []byte, method string, params []interface{}, targetStruct interface{}) error {
+	requestBody, err := json.Marshal(map[string]interface{}{
+		"jsonrpc": "2.0",
+		"method":  method,
+		"params":  params,
+		"id":      1,
+	})
+	if err != nil {
+		return stacktrace.Propagate(err, "An error occurred serializing the '%v' Engine API request", method)
+	}
+	if err := sendAuthRpcCall(transport, privateIpAddr, jwtSecret, string(requestBody), targetStruct); err != nil {
+		return stacktrace.Propagate(err, "An error occurred calling '%v' on the Engine API of Geth node with ip '%v'", method, privateIpAddr)
+	}
+	return nil
+}
+
+// Sends an RPC call to the JWT-authenticated endpoint of a Geth node, which only serves the "eth" and "engine" namespaces
+func sendAuthRpcCall(transport rpcTransport, privateIpAddr string, jwtSecret []byte, rpcJsonString string, targetStruct interface{}) error {
+	url := fmt.Sprintf("http://%v:%v", privateIpAddr, authRpcPortNum)
+
+	logrus.Tracef("Sending authenticated RPC call to '%v' with JSON body '%v'...", url, rpcJsonString)
+
+	request, err := http.NewRequest(http.MethodPost, url, bytes.NewBufferString(rpcJsonString))
+	if err != nil {
+		return stacktrace.Propagate(err, "An error occurred creating the authenticated RPC request to '%v'", url)
+	}
+	request.Header.Set("Content-Type", jsonContentType)
+	request.Header.Set(engineApiAuthorizationHeader, engineApiBearerPrefix+createJwt(jwtSecret))
+
+	resp, err := transport.Do(request)
+	if err != nil {
+		return stacktrace.Propagate(err, "Failed to send authenticated RPC request to Geth node with ip '%v'", privateIpAddr)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return stacktrace.NewError("Received non-200 status code from the authenticated RPC endpoint: %v", resp.StatusCode)
+	}
+	if err := json.NewDecoder(resp.Body).Decode(targetStruct); err != nil {
+		return stacktrace.Propagate(err, "Error parsing the Geth node's authenticated RPC response into target struct.")
+	}
+	return nil
+}
+
+func parseHexUint64(hexStr string) (uint64, error) {
+	result, err := strconv.ParseUint(strings.TrimPrefix(hexStr, hexPrefix), hexBase, uint64BitSize)
+	if err != nil {
+		return 0, stacktrace.Propagate(err, "An error occurred parsing hex string '%v'", hexStr)
+	}
+	return result, nil
+}
@@ -1,6 +1,14 @@
 package impl
 
-import "github.com/kurtosis-tech/kurtosis-sdk/api/golang/core/lib/services"
+import (
+	"encoding/json"
+	"github.com/kurtosis-tech/kurtosis-sdk/api/golang/core/lib/services"
+)
+
+// The part of a JSON-RPC request that decides which endpoint of a node serves it
+type EthAPIRequest struct {
+	Method string `json:"method"`
+}
 
 // Struct representing object that will come back from the Ethereum cluster when getting node info
 type EthAPINodeInfoResponse struct {
@@ -15,3 +23,101 @@ type EthAPINodeInfo struct {
 type EthAPIAddPeerResponse struct {
 	Result bool `json:"result"`
 }
+
+type EthAPIBlockNumberResponse struct {
+	Result string `json:"result"`
+}
+
+type EthAPIClientVersionResponse struct {
+	Result string       `json:"result"`
+	Error  *EthAPIError `json:"error"`
+}
+
+// The result is 'false' when the node isn't syncing, and an object with the sync progress otherwise
+type EthAPISyncingResponse struct {
+	Result json.RawMessage `json:"result"`
+	Error  *EthAPIError    `json:"error"`
+}
+
+type EthAPISyncProgress struct {
+	CurrentBlock string `json:"currentBlock"`
+	HighestBlock string `json:"highestBlock"`
+}
+
+type EthAPIPeersResponse struct {
+	Result []*EthAPIPeer `json:"result"`
+	Error  *EthAPIError  `json:"error"`
+}
+
+type EthAPIPeer struct {
+	Enode   string            `json:"enode"`
+	Name    string            `json:"name"`
+	Network EthAPIPeerNetwork `json:"network"`
+}
+
+type EthAPIPeerNetwork struct {
+	RemoteAddress string `json:"remoteAddress"`
+}
+
+type EthAPITxpoolStatusResponse struct {
+	Result *EthAPITxpoolStatus `json:"result"`
+	Error  *EthAPIError        `json:"error"`
+}
+
+type EthAPITxpoolStatus struct {
+	Pending string `json:"pending"`
+	Queued  string `json:"queued"`
+}
+
+type EthAPIError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+type EthAPIBlockResponse struct {
+	Result *EthAPIBlock `json:"result"`
+	Error  *EthAPIError `json:"error"`
+}
+
+type EthAPIBlock struct {
+	Hash      string `json:"hash"`
+	Number    string `json:"number"`
+	Timestamp string `json:"timestamp"`
+}
+
+// Struct representing the response to an 'engine_forkchoiceUpdatedV1' Engine API call
+type EngineAPIForkchoiceUpdatedResponse struct {
+	Result *EngineAPIForkchoiceUpdatedResult `json:"result"`
+	Error  *EthAPIError                      `json:"error"`
+}
+
+type EngineAPIForkchoiceUpdatedResult struct {
+	PayloadStatus EngineAPIPayloadStatus `json:"payloadStatus"`
+	PayloadID     *string                `json:"payloadId"`
+}
+
+type EngineAPIPayloadStatus struct {
+	Status          string  `json:"status"`
+	LatestValidHash *string `json:"latestValidHash"`
+	ValidationError *string `json:"validationError"`
+}
+
+// Struct representing the response to an 'engine_getPayloadV1' Engine API call
+// The payload is kept raw so that it can be handed to 'engine_newPayloadV1' exactly as it was received
+type EngineAPIGetPayloadResponse struct {
+	Result json.RawMessage `json:"result"`
+	Error  *EthAPIError    `json:"error"`
+}
+
+// The subset of the execution payload fields that the block driver needs to read
+type EngineAPIExecutionPayloadHeader struct {
+	BlockHash   string `json:"blockHash"`
+	BlockNumber string `json:"blockNumber"`
+	Timestamp   string `json:"timestamp"`
+}
+
+// Struct representing the response to an 'engine_newPayloadV1' Engine API call
+type EngineAPINewPayloadResponse struct {
+	Result *EngineAPIPayloadStatus `json:"result"`
+	Error  *EthAPIError            `json:"error"`
+}
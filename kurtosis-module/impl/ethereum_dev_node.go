@@ -0,0 +1,115 @@
+package impl
+
+import (
+	static_files_consts "github.com/kurtosis-tech/ethereum-kurtosis-module/kurtosis-module/static-files-consts"
+	"github.com/kurtosis-tech/kurtosis-sdk/api/golang/core/lib/services"
+	"github.com/kurtosis-tech/stacktrace"
+	"github.com/sirupsen/logrus"
+	"path"
+	"strconv"
+	"strings"
+)
+
+// Starts a single Geth node in dev mode, which seals its own blocks and funds the signer account at genesis
+// The partially-filled result has the same shape as the one of a full network, so consumers can switch between the two
+func startEthDevNode(
+	enclaveCtx moduleEnclave,
+	staticFiles *staticFiles,
+	devPeriodSeconds uint32,
+) (*ModuleAPIExecuteResult, *services.ServiceContext, error) {
+	nodeFilesArtifactUuid, err := uploadNodeFiles(enclaveCtx, "", devNodeServiceID, "")
+	if err != nil {
+		return nil, nil, stacktrace.Propagate(err, "An error occurred uploading the files of the dev node")
+	}
+	containerConfig, err := getDevNodeContainerConfig(staticFiles.filesArtifactUuid, nodeFilesArtifactUuid, devPeriodSeconds)
+	if err != nil {
+		return nil, nil, stacktrace.Propagate(err, "An error occurred getting the container config of the dev node")
+	}
+
+	serviceCtx, err := enclaveCtx.AddService(devNodeServiceID, containerConfig)
+	if err != nil {
+		return nil, nil, stacktrace.Propagate(err, "An error occurred adding the Ethereum dev node service")
+	}
+
+	if err := waitForNodeAvailability(enclaveCtx, nil, devNodeServiceID, nil); err != nil {
+		return nil, nil, stacktrace.Propagate(err, "An error occurred waiting for the Ethereum dev node to become available")
+	}
+
+	logrus.Infof(
+		"Added Ethereum dev node service with public IP: %v and public ports: %+v",
+		serviceCtx.GetMaybePublicIPAddress(),
+		serviceCtx.GetPublicPorts(),
+	)
+
+	apiNodeInfo, err := getApiNodeObjFromNodeServiceCtx(serviceCtx, &nodeLaunchConfig{})
+	if err != nil {
+		return nil, nil, stacktrace.Propagate(err, "An error occurred getting the node info API object from the dev node's service context")
+	}
+
+	resultObj := &ModuleAPIExecuteResult{
+		// The dev node is the only node, so it also plays the role of the bootnode
+		BootnodeServiceIDs: []services.ServiceID{devNodeServiceID},
+		NodeInfo: map[services.ServiceID]*ModuleAPIEthereumNodeInfo{
+			devNodeServiceID: apiNodeInfo,
+		},
+	}
+	return resultObj, serviceCtx, nil
+}
+
+func getDevNodeContainerConfig(
+	staticFilesArtifactUuid services.FilesArtifactUUID,
+	nodeFilesArtifactUuid services.FilesArtifactUUID,
+	devPeriodSeconds uint32,
+) (*services.ContainerConfig, error) {
+	// When the keystore already holds an account, Geth uses it as the prefunded dev account rather than generating one,
+	// so the dev node signs with the same account as the Clique signer of a full network
+	devNodeCommand, err := newGethCommandBuilder(
+		ethereumDockerImageName,
+	).withBoolFlag(
+		"dev",
+	).withFlag(
+		"dev.period", strconv.FormatUint(uint64(devPeriodSeconds), 10),
+	).withFlag(
+		"keystore", getMountedPathOnNodeContainer(""), // The keystore arg expects a directory containing keys
+	).withFlag(
+		"password", getMountedPathOnNodeContainer(static_files_consts.SignerAccountPasswordStaticFileName),
+	).withFlag(
+		"datadir", gethDataDirpath,
+	).withBoolFlag(
+		"http",
+	).withFlag(
+		"http.api", strings.Join(httpApiModules, ","),
+	).withFlag(
+		"http.addr", "0.0.0.0",
+	).withFlag(
+		"http.port", strconv.Itoa(int(rpcPortNum)),
+	).withFlag(
+		"http.corsdomain", "*",
+	).withFlag(
+		"http.vhosts", "*",
+	).withFlag(
+		"nat", "extip:"+privateIPAddressPlaceholder,
+	).withFlag(
+		"port", strconv.Itoa(int(discoveryPortNum)),
+	).withFlag(
+		"nodekey", path.Join(nodeFilesMountpointOnNodes, nodeKeyFilename),
+	).build()
+	if err != nil {
+		return nil, stacktrace.Propagate(err, "An error occurred building the command that runs the dev node")
+	}
+
+	containerConfig := services.NewContainerConfigBuilder(
+		ethereumDockerImageName,
+	).WithUsedPorts(
+		usedPorts,
+	).WithEntrypointOverride(
+		getShellEntrypointArgs(devNodeCommand),
+	).WithFiles(map[services.FilesArtifactUUID]string{
+		staticFilesArtifactUuid: staticFilesMountpointOnNodes,
+		nodeFilesArtifactUuid:   nodeFilesMountpointOnNodes,
+	}).WithPrivateIPAddrPlaceholder(
+		privateIPAddressPlaceholder,
+	).Build()
+
+	return containerConfig, nil
+}
@@ -2,6 +2,8 @@ package impl
 
 import (
 	"bytes"
+	"crypto/ecdsa"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	static_files_consts "github.com/kurtosis-tech/ethereum-kurtosis-module/kurtosis-module/static-files-consts"
@@ -13,17 +15,33 @@ import (
 	"io/ioutil"
 	"net/http"
 	"path"
+	"regexp"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 )
 
+// Actions of the execute params, which Go callers of ExecuteParams set the action of their params to
+const (
+	CreateAction      = "create"
+	AddNodesAction    = "add_nodes"
+	StopNodeAction    = "stop_node"
+	RestartNodeAction = "restart_node"
+	RemoveNodeAction  = "remove_node"
+	StatusAction      = "status"
+	ReconcileAction   = "reconcile"
+)
+
 const (
 	ethereumDockerImageName = "ethereum/client-go:v1.10.8"
+	// The JWT-authenticated RPC endpoint, which the Engine API is served on, only exists in later Geth versions
+	authRpcEthereumDockerImageName = "ethereum/client-go:v1.10.26"
 
 	rpcPortNum       uint16 = 8545
 	wsPortNum        uint16 = 8546
 	discoveryPortNum uint16 = 30303
+	authRpcPortNum   uint16 = 8551
 	subnetRange             = "/24"
 
 	bootnodeServiceID           = "bootnode"
@@ -43,23 +61,85 @@ const (
 
 	ethNetworkId = 881239
 
-	maxNumPeerCountValidationAttempts      = 5
-	timeBetweenPeerCountValidationAttempts = 500 * time.Millisecond
+	maxNumPeerCountValidationAttempts            = 5
+	maxNumSelfPeeringPeerCountValidationAttempts = 240
+	timeBetweenPeerCountValidationAttempts       = 500 * time.Millisecond
 
 	// Port IDs
 	rpcPortId          = "rpc"
 	wsPortId           = "ws"
 	tcpDiscoveryPortId = "tcpDiscovery"
 	udpDiscoveryPortId = "udpDiscovery"
+	authRpcPortId      = "authRpc"
 
 	jsonOutputPrefixStr = ""
 	jsonOutputIndentStr = "  "
 
-	staticFilesMountpointOnNodes = "/files"
+	staticFilesMountpointOnNodes        = "/files"
+	engineApiJwtSecretMountpointOnNodes = "/jwt"
 
 	privateIPAddressPlaceholder = "KURTOSIS_PRIVATE_IP_ADDR_PLACEHOLDER"
+
+	defaultNumNodesToAdd = 1
+
+	// Geth GC and sync modes that a node spec can pick
+	archiveGcMode = "archive"
+	fullGcMode    = "full"
+	fullSyncMode  = "full"
+	snapSyncMode  = "snap"
+
+	nodeSpecEnvVar        = "ETHEREUM_KURTOSIS_MODULE_NODE_SPEC"
+	peeringStrategyEnvVar = "ETHEREUM_KURTOSIS_MODULE_PEERING_STRATEGY"
+
+	// How the nodes of the network get connected to each other
+	adminAddPeerPeeringStrategy = "admin_add_peer"
+	staticNodesPeeringStrategy  = "static_nodes"
+	trustedNodesPeeringStrategy = "trusted_nodes"
+	discoveryPeeringStrategy    = "discovery"
+
+	// What the bootnode does
+	signerBootnodeMode        = "signer"
+	discoveryOnlyBootnodeMode = "discovery_only"
+
+	defaultSignerCount = 1
+
+	// How the nodes discover the network
+	bootnodesNodeDiscovery = "bootnodes"
+	dnsNodeDiscovery       = "dns"
+
+	// Execute modes
+	networkMode = "network"
+	devMode     = "dev"
+
+	devNodeServiceID = "dev-node"
+
+	// Block production modes
+	cliqueBlockProduction          = "clique"
+	engineApiDriverBlockProduction = "engine_api_driver"
+
+	defaultEngineApiDriverSlotTimeSeconds = 5
+
+	// How the nodes serve their RPC API
+	openRpcProfile     = "open"
+	hardenedRpcProfile = "hardened"
+
+	// Where the signers keep the account they seal with
+	keystoreSignerBackend = "keystore"
+	clefSignerBackend     = "clef"
+
+	// How many nodes get started, queried or peered at once by default
+	defaultMaxConcurrency = 8
+
+	// Phases of the network startup, whose durations are reported in the result
+	clefSignersStartupPhase         = "clef_signers"
+	nodesStartupPhase               = "nodes"
+	dnsDiscoveryStartupPhase        = "dns_discovery"
+	peerConnectionStartupPhase      = "peer_connection"
+	peeringVerificationStartupPhase = "peering_verification"
 )
 
+var ethAddressRegex = regexp.MustCompile("^0x[0-9a-fA-F]{40}$")
+
 var usedPorts = map[string]*services.PortSpec{
 	rpcPortId:          services.NewPortSpec(rpcPortNum, services.PortProtocol_TCP),
 	wsPortId:           services.NewPortSpec(wsPortNum, services.PortProtocol_TCP),
@@ -67,48 +147,392 @@ var usedPorts = map[string]*services.PortSpec{
 	udpDiscoveryPortId: services.NewPortSpec(discoveryPortNum, services.PortProtocol_UDP),
 }
 
+// Settings shared by all the nodes of the network, derived from the execute params
+type nodeLaunchConfig struct {
+	dockerImage     string
+	genesisFilepath string
+	staticFiles     *staticFiles
+	// Only set when the network has separate signers, whose genesis is rendered by the module
+	networkFilesArtifactUuid services.FilesArtifactUUID
+
+	// When enabled, the bootnode doesn't mine and all nodes expose the Engine API so an external driver can produce blocks
+	isEngineApiEnabled    bool
+	jwtSecretArtifactUuid services.FilesArtifactUUID
+
+	// One of the peering strategy values of the execute params
+	peeringStrategy string
+
+	// One of the bootnode mode values of the execute params, along with the number of separate signers in "discovery_only" mode
+	bootnodeMode  string
+	signerCount   uint32
+	bootnodeCount uint32
+
+	// One of the node discovery values of the execute params, along with the IP of the DNS server once it's up
+	nodeDiscovery   string
+	dnsServerIpAddr string
+
+	// Never nil, with limits for every role
+	resourceDefaults *ModuleAPIResourceDefaults
+
+	// Only set with the "hardened" RPC profile, along with the JWT secret that the module signs its own calls with
+	hardenedRpc  *ModuleAPIHardenedRpcArgs
+	rpcJwtSecret []byte
+
+	// The transport of the module that RPC calls to the nodes are sent through
+	rpcTransport rpcTransport
+
+	// Mixed into the keys that the module derives for the network, where an empty one leaves them derivable by anyone
+	keySeed string
+
+	// One of the signer backend values of the execute params, along with the IPs of the Clefs by the service ID of their
+	// signer once they're up
+	signerBackend string
+	clefIpAddrs   map[services.ServiceID]string
+
+	// Keys of the imported accounts, in the order that the signers seal with them, along with the wei that each is funded
+	// with at genesis; only set when accounts were imported, in which case the account of the static files isn't used
+	importedAccountKeys []*ecdsa.PrivateKey
+	genesisBalanceWei   string
+}
+
 type EthereumKurtosisModule struct {
+	// Only set once a network producing its blocks through the Engine API has been started
+	engineApiBlockDriver *engineApiBlockDriver
+
+	// The launch config of the network started by this module, reused when nodes are added or restarted
+	// Only set once a network has been started by this instance of the module
+	networkLaunchConfig *nodeLaunchConfig
+
+	// Nodes paused by the "stop_node" action, which are left out of peering until they get restarted
+	stoppedNodeServiceIds map[services.ServiceID]bool
+
+	// Files artifacts of the Geth nodes started by this instance of the module, which hold the config each node runs with
+	nodeFilesArtifactUuids map[services.ServiceID]services.FilesArtifactUUID
+
+	// Services added by this instance of the module that are still in the enclave, in the order they were added
+	addedServiceIds []services.ServiceID
+
+	// Every RPC call to a node goes through this transport
+	rpcTransport rpcTransport
 }
 
 func NewEthereumKurtosisModule() *EthereumKurtosisModule {
-	return &EthereumKurtosisModule{}
+	return &EthereumKurtosisModule{
+		stoppedNodeServiceIds:  map[services.ServiceID]bool{},
+		nodeFilesArtifactUuids: map[services.ServiceID]services.FilesArtifactUUID{},
+		addedServiceIds:        []services.ServiceID{},
+		rpcTransport: &http.Client{
+			Timeout: rpcRequestTimeout,
+		},
+	}
 }
 
-func (e EthereumKurtosisModule) Execute(enclaveCtx *enclaves.EnclaveContext, serializedParams string) (serializedResult string, resultError error) {
-	logrus.Infof("Serialized execute params '%v'", serializedParams)
+func (e *EthereumKurtosisModule) Execute(enclaveCtx *enclaves.EnclaveContext, serializedParams string) (serializedResult string, resultError error) {
+	// The params can hold the secrets of the imported accounts, which haven't been registered yet
+	redactedSerializedParams := RedactSecrets(serializedParams)
+	logrus.Infof("Serialized execute params '%v'", redactedSerializedParams)
 	serializedParamsBytes := []byte(serializedParams)
 	var params ModuleAPIExecuteArgs
 	if err := json.Unmarshal(serializedParamsBytes, &params); err != nil {
-		return "", stacktrace.Propagate(err, "An error occurred deserializing the serialized params with value '%v'", serializedParams)
+		return "", stacktrace.Propagate(err, "An error occurred deserializing the serialized params with value '%v'", redactedSerializedParams)
+	}
+	if err := applyDefaultsAndValidateParams(&params); err != nil {
+		return "", stacktrace.Propagate(err, "The execute params were invalid")
+	}
+
+	// The status report starts nothing, so it's handled before anything gets uploaded
+	if params.Action == StatusAction {
+		statusObj, err := e.GetNetworkStatus(enclaveCtx)
+		if err != nil {
+			return "", stacktrace.Propagate(err, "An error occurred getting the status of the Ethereum network")
+		}
+		return serializeResult(statusObj)
 	}
 
-	staticFilesArtifactUuid, err := enclaveCtx.UploadFiles(static_files_consts.StaticFilesDirpathOnTestsuiteContainer)
+	resultObj, err := e.executeValidatedParams(enclaveCtx, &params)
 	if err != nil {
-		return "", stacktrace.Propagate(err, "An error occurred uploading the static files")
+		return "", stacktrace.Propagate(err, "An error occurred executing action '%v'", params.Action)
 	}
+	return serializeResult(resultObj)
+}
 
-	allNodeInfo, bootnodeServiceCtx, err := startEthNodes(enclaveCtx, staticFilesArtifactUuid)
+// Executes the given params like Execute does their JSON, for Go code that holds the enclave context itself, applying the
+// defaults to the given params and returning the result as it is
+// The "status" action has a result of its own, which GetNetworkStatus returns
+func (e *EthereumKurtosisModule) ExecuteParams(enclaveCtx *enclaves.EnclaveContext, params *ModuleAPIExecuteArgs) (*ModuleAPIExecuteResult, error) {
+	if err := applyDefaultsAndValidateParams(params); err != nil {
+		return nil, stacktrace.Propagate(err, "The execute params were invalid")
+	}
+	if params.Action == StatusAction {
+		return nil, stacktrace.NewError("Action '%v' has a result of its own, which GetNetworkStatus returns", StatusAction)
+	}
+	resultObj, err := e.executeValidatedParams(enclaveCtx, params)
 	if err != nil {
-		return "", stacktrace.Propagate(err, "An error occurred starting the Ethereum child nodes")
+		return nil, stacktrace.Propagate(err, "An error occurred executing action '%v'", params.Action)
+	}
+	return resultObj, nil
+}
+
+// Executes any action but "status" with the given params, which the defaults have already been applied to
+func (e *EthereumKurtosisModule) executeValidatedParams(enclaveCtx moduleEnclave, params *ModuleAPIExecuteArgs) (resultObj *ModuleAPIExecuteResult, resultError error) {
+	// The execution goes through an enclave that records the services it adds, which are all that a failed execution removes,
+	// on top of one that records them in the services added by the module, which are saved once any rollback is done
+	moduleEnclaveCtx := e.newServiceRecordingEnclave(enclaveCtx)
+	defer e.saveAddedServiceIds(moduleEnclaveCtx)
+	executionEnclaveCtx := &serviceRecordingEnclave{moduleEnclave: moduleEnclaveCtx}
+	defer func() {
+		if resultError == nil {
+			return
+		}
+		if params.KeepOnFailure {
+			logrus.Warnf("The execution failed, but the services it created are kept for debugging because keep-on-failure is set")
+			return
+		}
+		removedServiceIds, err := e.removeServices(executionEnclaveCtx, getReversedServiceIds(executionEnclaveCtx.addedServiceIds))
+		if err != nil {
+			resultError = stacktrace.Propagate(
+				resultError,
+				"The execution failed, and rolling back the services it created failed too after removing %v:\n%v",
+				removedServiceIds,
+				err,
+			)
+			return
+		}
+		resultError = stacktrace.Propagate(resultError, "The execution failed, so the services it created were removed: %v", removedServiceIds)
+	}()
+	enclaveCtx = executionEnclaveCtx
+	var err error
+
+	// The other actions start their nodes with the static files that the existing network was started with, and reconcile
+	// only loads them when it creates the network
+	var staticFiles *staticFiles
+	if params.Action == CreateAction {
+		staticFiles, err = loadStaticFiles(enclaveCtx, params.StaticFilesArtifactUUID)
+		if err != nil {
+			return nil, stacktrace.Propagate(err, "An error occurred loading the static files")
+		}
+		setDefaultFeeRecipient(params, staticFiles)
+	}
+
+	var signerServiceCtx *services.ServiceContext
+	switch {
+	case params.Action == ReconcileAction:
+		resultObj, signerServiceCtx, err = e.reconcileEthNetwork(enclaveCtx, params)
+		if err != nil {
+			return nil, stacktrace.Propagate(err, "An error occurred reconciling the Ethereum network")
+		}
+	case params.Action != CreateAction:
+		resultObj, signerServiceCtx, err = e.executeNetworkAction(enclaveCtx, params)
+		if err != nil {
+			return nil, stacktrace.Propagate(err, "An error occurred executing action '%v' on the existing Ethereum network", params.Action)
+		}
+	case params.Mode == devMode:
+		resultObj, signerServiceCtx, err = startEthDevNode(enclaveCtx, staticFiles, params.DevPeriodSeconds)
+		if err != nil {
+			return nil, stacktrace.Propagate(err, "An error occurred starting the Ethereum dev node")
+		}
+	default:
+		resultObj, signerServiceCtx, err = e.startEthNetwork(enclaveCtx, staticFiles, params)
+		if err != nil {
+			return nil, stacktrace.Propagate(err, "An error occurred starting the Ethereum network")
+		}
+	}
+	resultObj.Action = params.Action
+	resultObj.Mode = params.Mode
+
+	// The dev node always seals with the account of the static files
+	launchConfig := e.networkLaunchConfig
+	if params.Mode == devMode || launchConfig == nil {
+		launchConfig = &nodeLaunchConfig{staticFiles: staticFiles}
+	}
+	// The account of the static files isn't used by networks with imported accounts, so its keystore isn't returned either
+	if len(launchConfig.importedAccountKeys) == 0 {
+		signerKeystoreFilename := launchConfig.staticFiles.signerKeystoreFilename
+		signerKeystoreContent, err := getStaticFileContent(signerServiceCtx, signerKeystoreFilename)
+		if err != nil {
+			return nil, stacktrace.Propagate(err, "An error occurred getting static file content '%v'", signerKeystoreFilename)
+		}
+		registerSecret(signerKeystoreContent)
+		resultObj.SignerKeystoreContent = signerKeystoreContent
+
+		signerAccountPasswordContent, err := getStaticFileContent(signerServiceCtx, static_files_consts.SignerAccountPasswordStaticFileName)
+		if err != nil {
+			return nil, stacktrace.Propagate(err, "An error occurred getting static file content '%v'", static_files_consts.SignerAccountPasswordStaticFileName)
+		}
+		registerSecret(signerAccountPasswordContent)
+		resultObj.SignerAccountPassword = signerAccountPasswordContent
 	}
 
-	signerKeystoreContent, err := getStaticFileContent(bootnodeServiceCtx, static_files_consts.SignerKeystoreFileName)
+	accounts, err := getAccountsInfo(launchConfig, resultObj.SignerServiceIDs, params.IncludePrivateKeys)
 	if err != nil {
-		return "", stacktrace.Propagate(err, "An error occurred getting static file content '%v'", static_files_consts.SignerKeystoreFileName)
+		return nil, stacktrace.Propagate(err, "An error occurred getting the accounts managed by the module")
+	}
+	resultObj.Accounts = accounts
+
+	if params.SecretsOutput == filesArtifactSecretsOutput {
+		if err := moveSecretsToFilesArtifact(enclaveCtx, resultObj, launchConfig.staticFiles.signerKeystoreFilename); err != nil {
+			return nil, stacktrace.Propagate(err, "An error occurred moving the secrets of the result to a files artifact")
+		}
 	}
 
-	signerAccountPasswordContent, err := getStaticFileContent(bootnodeServiceCtx, static_files_consts.SignerAccountPasswordStaticFileName)
+	return resultObj, nil
+}
+
+// Starts the bootnode, the signers and the children, returning the partially-filled result along with the service context of
+// the Clique signer whose keystore is in the static files
+func (e *EthereumKurtosisModule) startEthNetwork(
+	enclaveCtx moduleEnclave,
+	staticFiles *staticFiles,
+	params *ModuleAPIExecuteArgs,
+) (*ModuleAPIExecuteResult, *services.ServiceContext, error) {
+	launchConfig := &nodeLaunchConfig{
+		dockerImage:      ethereumDockerImageName,
+		genesisFilepath:  getMountedPathOnNodeContainer(static_files_consts.GenesisStaticFileName),
+		staticFiles:      staticFiles,
+		peeringStrategy:  params.PeeringStrategy,
+		bootnodeMode:     params.BootnodeMode,
+		signerCount:      params.SignerCount,
+		bootnodeCount:    params.BootnodeCount,
+		nodeDiscovery:    params.NodeDiscovery,
+		resourceDefaults: params.ResourceDefaults,
+		hardenedRpc:      params.HardenedRpc,
+		signerBackend:    params.SignerBackend,
+		rpcTransport:     e.rpcTransport,
+		keySeed:          params.KeySeed,
+	}
+	if params.ImportedAccounts != nil {
+		importedAccountKeys, err := getImportedAccountKeys(enclaveCtx, params.ImportedAccounts, len(getSignerServiceIds(launchConfig)))
+		if err != nil {
+			return nil, nil, stacktrace.Propagate(err, "An error occurred getting the keys of the imported accounts")
+		}
+		launchConfig.importedAccountKeys = importedAccountKeys
+		launchConfig.genesisBalanceWei = params.ImportedAccounts.GenesisBalanceWei
+	}
+	if params.BlockProduction == engineApiDriverBlockProduction && !staticFiles.hasPostMergeGenesis {
+		return nil, nil, stacktrace.NewError(
+			"Block production '%v' needs post-merge genesis '%v', which isn't in the static files",
+			engineApiDriverBlockProduction,
+			static_files_consts.PostMergeGenesisStaticFileName,
+		)
+	}
+	// A genesis that isn't rendered is used as it is, so the signer must already be authorized by it, or no block ever gets sealed
+	if params.BlockProduction == cliqueBlockProduction && !isGenesisRendered(launchConfig) {
+		if err := verifyStaticSignerInGenesis(staticFiles); err != nil {
+			return nil, nil, stacktrace.Propagate(err, "The signer of the static files can't seal blocks with their genesis")
+		}
+	}
+	if isGenesisRendered(launchConfig) {
+		networkFilesArtifactUuid, err := uploadNetworkFiles(enclaveCtx, launchConfig)
+		if err != nil {
+			return nil, nil, stacktrace.Propagate(err, "An error occurred uploading the network files")
+		}
+		launchConfig.networkFilesArtifactUuid = networkFilesArtifactUuid
+		launchConfig.genesisFilepath = path.Join(networkFilesMountpointOnNodes, static_files_consts.GenesisStaticFileName)
+	}
+	var engineApiJwtSecret []byte
+	if params.BlockProduction == engineApiDriverBlockProduction {
+		if e.engineApiBlockDriver != nil {
+			return nil, nil, stacktrace.NewError("An Engine API block driver is already running for a network started by this module")
+		}
+		jwtSecret, jwtSecretArtifactUuid, err := createJwtSecret(enclaveCtx)
+		if err != nil {
+			return nil, nil, stacktrace.Propagate(err, "An error occurred creating the Engine API JWT secret")
+		}
+		engineApiJwtSecret = jwtSecret
+		launchConfig.dockerImage = authRpcEthereumDockerImageName
+		launchConfig.genesisFilepath = getMountedPathOnNodeContainer(static_files_consts.PostMergeGenesisStaticFileName)
+		launchConfig.isEngineApiEnabled = true
+		launchConfig.jwtSecretArtifactUuid = jwtSecretArtifactUuid
+	}
+	if launchConfig.hardenedRpc != nil {
+		jwtSecret, jwtSecretArtifactUuid, err := createJwtSecret(enclaveCtx)
+		if err != nil {
+			return nil, nil, stacktrace.Propagate(err, "An error occurred creating the RPC JWT secret")
+		}
+		launchConfig.dockerImage = authRpcEthereumDockerImageName
+		launchConfig.jwtSecretArtifactUuid = jwtSecretArtifactUuid
+		launchConfig.rpcJwtSecret = jwtSecret
+	}
+
+	allNodeInfo, nodeServiceCtxs, startupPhases, err := startEthNodes(enclaveCtx, launchConfig, params.Nodes, params.MaxConcurrency)
+	if err != nil {
+		return nil, nil, stacktrace.Propagate(err, "An error occurred starting the Ethereum child nodes")
+	}
+	bootnodeServiceIds := getBootnodeServiceIds(launchConfig)
+	allBootnodeInfo := map[services.ServiceID]*ModuleAPIBootnodeInfo{}
+	for _, serviceId := range bootnodeServiceIds {
+		bootnodeInfo, err := getApiBootnodeObjFromServiceCtx(nodeServiceCtxs[serviceId], launchConfig)
+		if err != nil {
+			return nil, nil, stacktrace.Propagate(err, "An error occurred getting the info API object of bootnode '%v'", serviceId)
+		}
+		allBootnodeInfo[serviceId] = bootnodeInfo
+	}
+	signerServiceIds := getSignerServiceIds(launchConfig)
+	clefSignerInfo, err := getClefSignerInfo(launchConfig)
 	if err != nil {
-		return "", stacktrace.Propagate(err, "An error occurred getting static file content '%v'", static_files_consts.SignerAccountPasswordStaticFileName)
+		return nil, nil, stacktrace.Propagate(err, "An error occurred getting the Clef signer info API objects")
 	}
 
 	resultObj := &ModuleAPIExecuteResult{
-		BootnodeServiceID:     bootnodeServiceID,
-		NodeInfo:              allNodeInfo,
-		SignerKeystoreContent: signerKeystoreContent,
-		SignerAccountPassword: signerAccountPasswordContent,
+		BootnodeServiceIDs: bootnodeServiceIds,
+		NodeInfo:           allNodeInfo,
+		BlockProduction:    params.BlockProduction,
+		PeeringStrategy:    params.PeeringStrategy,
+		BootnodeMode:       params.BootnodeMode,
+		BootnodeInfo:       allBootnodeInfo,
+		SignerServiceIDs:   signerServiceIds,
+		NodeDiscovery:      params.NodeDiscovery,
+		StartupPhases:      startupPhases,
+		RpcProfile:         params.RpcProfile,
+		SignerBackend:      params.SignerBackend,
+		ClefSigners:        clefSignerInfo,
+	}
+	if launchConfig.rpcJwtSecret != nil {
+		resultObj.RpcJwtSecret = hex.EncodeToString(launchConfig.rpcJwtSecret)
+	}
+	if launchConfig.nodeDiscovery == dnsNodeDiscovery {
+		dnsServerServiceCtx, err := enclaveCtx.GetServiceContext(dnsServerServiceID)
+		if err != nil {
+			return nil, nil, stacktrace.Propagate(err, "An error occurred getting the service context of the DNS discovery server")
+		}
+		dnsDiscoveryInfo, err := getDnsDiscoveryInfo(launchConfig.keySeed, dnsServerServiceCtx)
+		if err != nil {
+			return nil, nil, stacktrace.Propagate(err, "An error occurred getting the DNS discovery info API object")
+		}
+		resultObj.DnsDiscovery = dnsDiscoveryInfo
 	}
 
+	if launchConfig.isEngineApiEnabled {
+		nodeIpAddrs := map[services.ServiceID]string{}
+		for serviceId, nodeInfo := range allNodeInfo {
+			nodeIpAddrs[serviceId] = nodeInfo.IPAddrInsideNetwork
+		}
+		driver := newEngineApiBlockDriver(
+			launchConfig.rpcTransport,
+			bootnodeServiceID,
+			nodeIpAddrs,
+			engineApiJwtSecret,
+			params.EngineApiDriver.FeeRecipient,
+			time.Duration(params.EngineApiDriver.SlotTimeSeconds)*time.Second,
+		)
+		if err := driver.start(); err != nil {
+			return nil, nil, stacktrace.Propagate(err, "An error occurred starting the Engine API block driver")
+		}
+		e.engineApiBlockDriver = driver
+		resultObj.EngineApiJwtSecret = hex.EncodeToString(engineApiJwtSecret)
+	}
+	e.networkLaunchConfig = launchConfig
+	e.recordNodeFilesArtifactUuids(allNodeInfo)
+
+	return resultObj, nodeServiceCtxs[signerServiceIds[0]], nil
+}
+
+// ====================================================================================================
+//
+//	Private helper functions
+//
+// ====================================================================================================
+func serializeResult(resultObj interface{}) (string, error) {
 	resultBytes, err := json.MarshalIndent(resultObj, jsonOutputPrefixStr, jsonOutputIndentStr)
 	if err != nil {
 		return "", stacktrace.Propagate(err, "An error occurred serializing the result object '%+v'", resultObj)
@@ -120,200 +544,711 @@ func (e EthereumKurtosisModule) Execute(enclaveCtx *enclaves.EnclaveContext, ser
 	return resultStr, nil
 }
 
-// ====================================================================================================
-//
-//	Private helper functions
-//
-// ====================================================================================================
-func startEthBootnode(
-	enclaveCtx *enclaves.EnclaveContext,
-	staticFilesArtifactUuid services.FilesArtifactUUID,
-) (
-	nodeServiceCtx *services.ServiceContext,
-	enr string,
-	nodeInfo *ModuleAPIEthereumNodeInfo,
-	resultErr error,
-) {
-	getContainerConfig := getBootnodeContainerConfig(staticFilesArtifactUuid)
+func applyDefaultsAndValidateParams(params *ModuleAPIExecuteArgs) error {
+	switch params.Action {
+	case "":
+		params.Action = CreateAction
+	case CreateAction, ReconcileAction:
+	case AddNodesAction, StopNodeAction, RestartNodeAction, RemoveNodeAction, StatusAction:
+		return applyDefaultsAndValidateActionParams(params)
+	default:
+		return stacktrace.NewError(
+			"Unrecognized action '%v'; valid values are '%v', '%v', '%v', '%v', '%v', '%v' and '%v'",
+			params.Action,
+			CreateAction,
+			ReconcileAction,
+			AddNodesAction,
+			StopNodeAction,
+			RestartNodeAction,
+			RemoveNodeAction,
+			StatusAction,
+		)
+	}
+	if params.NodeCount != 0 || params.NodeServiceID != "" {
+		return stacktrace.NewError("A node count or node service ID was provided, but they're only used by actions on an existing network")
+	}
+	if params.MaxConcurrency == 0 {
+		params.MaxConcurrency = defaultMaxConcurrency
+	}
+	if err := applyDefaultsAndValidateSecretsOutput(params); err != nil {
+		return stacktrace.Propagate(err, "The secrets output was invalid")
+	}
+
+	switch params.Mode {
+	case "":
+		params.Mode = networkMode
+	case networkMode:
+	case devMode:
+		if params.Action == ReconcileAction {
+			return stacktrace.NewError("Action '%v' can only be executed in '%v' mode", ReconcileAction, networkMode)
+		}
+		if params.BlockProduction != "" || params.EngineApiDriver != nil {
+			return stacktrace.NewError("Block production can't be configured in '%v' mode, where the dev node seals its own blocks", devMode)
+		}
+		if unexpectedParamNames := getUnexpectedParamNames(params, devModeParamNames); len(unexpectedParamNames) > 0 {
+			return stacktrace.NewError("Nodes and their peering can't be configured in '%v' mode, which only ever starts one node, but got params %v", devMode, unexpectedParamNames)
+		}
+		return nil
+	default:
+		return stacktrace.NewError("Unrecognized mode '%v'; valid values are '%v' and '%v'", params.Mode, networkMode, devMode)
+	}
+	if params.DevPeriodSeconds != 0 {
+		return stacktrace.NewError("A dev period was provided, but it's only used in '%v' mode", devMode)
+	}
+	if params.KeySeed != "" && len(params.KeySeed) < minKeySeedLength {
+		return stacktrace.NewError("The key seed has %v characters, but at least %v are needed so it can't be guessed", len(params.KeySeed), minKeySeedLength)
+	}
+	registerSecret(params.KeySeed)
 
-	serviceCtx, err := enclaveCtx.AddService(bootnodeServiceID, getContainerConfig)
+	switch params.PeeringStrategy {
+	case "":
+		params.PeeringStrategy = adminAddPeerPeeringStrategy
+	case adminAddPeerPeeringStrategy, staticNodesPeeringStrategy, trustedNodesPeeringStrategy, discoveryPeeringStrategy:
+	default:
+		return stacktrace.NewError(
+			"Unrecognized peering strategy '%v'; valid values are '%v', '%v', '%v' and '%v'",
+			params.PeeringStrategy,
+			adminAddPeerPeeringStrategy,
+			staticNodesPeeringStrategy,
+			trustedNodesPeeringStrategy,
+			discoveryPeeringStrategy,
+		)
+	}
+
+	if err := applyDefaultsAndValidateBlockProductionParams(params); err != nil {
+		return stacktrace.Propagate(err, "The block production params were invalid")
+	}
+	if err := applyDefaultsAndValidateRpcParams(params); err != nil {
+		return stacktrace.Propagate(err, "The RPC params were invalid")
+	}
+	if err := applyDefaultsAndValidateSignerBackend(params); err != nil {
+		return stacktrace.Propagate(err, "The signer backend was invalid")
+	}
+	if err := applyDefaultsAndValidateBootnodeParams(params); err != nil {
+		return stacktrace.Propagate(err, "The bootnode params were invalid")
+	}
+	if err := applyDefaultsAndValidateImportedAccounts(params); err != nil {
+		return stacktrace.Propagate(err, "The imported accounts were invalid")
+	}
+	switch params.NodeDiscovery {
+	case "":
+		params.NodeDiscovery = bootnodesNodeDiscovery
+	case bootnodesNodeDiscovery:
+	case dnsNodeDiscovery:
+		// The discovery-only bootnodes would have nothing left to do
+		if params.BootnodeMode != signerBootnodeMode {
+			return stacktrace.NewError("Node discovery '%v' takes the place of the bootnodes, so it can only be used with bootnode mode '%v'", dnsNodeDiscovery, signerBootnodeMode)
+		}
+	default:
+		return stacktrace.NewError(
+			"Unrecognized node discovery '%v'; valid values are '%v' and '%v'",
+			params.NodeDiscovery,
+			bootnodesNodeDiscovery,
+			dnsNodeDiscovery,
+		)
+	}
+	resourceDefaults, err := applyDefaultsAndValidateResourceDefaults(params.ResourceDefaults)
 	if err != nil {
-		return nil, "", nil, stacktrace.Propagate(err, "An error occurred adding the Ethereum bootnode service")
+		return stacktrace.Propagate(err, "The resource defaults were invalid")
+	}
+	params.ResourceDefaults = resourceDefaults
+	if err := applyDefaultsAndValidateNodeSpecs(params); err != nil {
+		return stacktrace.Propagate(err, "The node specs were invalid")
 	}
+	return nil
+}
 
-	if err := enclaveCtx.WaitForHttpPostEndpointAvailability(bootnodeServiceID, uint32(rpcPortNum), "", adminInfoRpcCall, waitEndpointInitialDelayMilliseconds, waitEndpointRetries, waitEndpointRetriesDelayMilliseconds, ""); err != nil {
+func applyDefaultsAndValidateBlockProductionParams(params *ModuleAPIExecuteArgs) error {
+	switch params.BlockProduction {
+	case "":
+		params.BlockProduction = cliqueBlockProduction
+	case cliqueBlockProduction, engineApiDriverBlockProduction:
+	default:
+		return stacktrace.NewError(
+			"Unrecognized block production '%v'; valid values are '%v' and '%v'",
+			params.BlockProduction,
+			cliqueBlockProduction,
+			engineApiDriverBlockProduction,
+		)
+	}
 
-		return nil, "", nil, stacktrace.Propagate(err, "An error occurred waiting for service with ID '%v' to start", bootnodeServiceID)
+	if params.BlockProduction != engineApiDriverBlockProduction {
+		if params.EngineApiDriver != nil {
+			return stacktrace.NewError("Engine API driver settings were provided, but block production is '%v'", params.BlockProduction)
+		}
+		return nil
 	}
 
-	logrus.Infof(
-		"Added Ethereum bootnode service with public IP: %v and public ports: %+v",
-		serviceCtx.GetMaybePublicIPAddress(),
-		serviceCtx.GetPublicPorts(),
-	)
+	if params.EngineApiDriver == nil {
+		params.EngineApiDriver = &ModuleAPIEngineApiDriverArgs{}
+	}
+	// The fee recipient defaults to the account of the static files, which are only loaded once the enclave is reached
+	if params.EngineApiDriver.FeeRecipient != "" && !ethAddressRegex.MatchString(params.EngineApiDriver.FeeRecipient) {
+		return stacktrace.NewError("Engine API driver fee recipient '%v' isn't a valid hex-encoded address", params.EngineApiDriver.FeeRecipient)
+	}
+	if params.EngineApiDriver.SlotTimeSeconds == 0 {
+		params.EngineApiDriver.SlotTimeSeconds = defaultEngineApiDriverSlotTimeSeconds
+	}
+	return nil
+}
 
-	cmd := "geth attach data/geth.ipc --exec admin.nodeInfo.enr"
-	exitCode, logOutput, err := serviceCtx.ExecCommand([]string{
-		"/bin/sh",
-		"-c",
-		cmd,
-	})
-	if err != nil {
-		return nil, "", nil, stacktrace.Propagate(err, "Executing command '%v' returned an error", cmd)
+// Defaults the fee recipient of the Engine API block driver to the account of the given static files
+func setDefaultFeeRecipient(params *ModuleAPIExecuteArgs, staticFiles *staticFiles) {
+	if params.EngineApiDriver != nil && params.EngineApiDriver.FeeRecipient == "" {
+		params.EngineApiDriver.FeeRecipient = getAccountAddress(staticFiles.signerAccountKey)
 	}
-	if exitCode != execCommandSuccessExitCode {
-		return nil, "", nil, stacktrace.NewError("Executing command '%v' returned an failing exit code with logs:\n%v", cmd, logOutput)
+}
+
+func applyDefaultsAndValidateBootnodeParams(params *ModuleAPIExecuteArgs) error {
+	switch params.BootnodeMode {
+	case "":
+		params.BootnodeMode = signerBootnodeMode
+	case signerBootnodeMode, discoveryOnlyBootnodeMode:
+	default:
+		return stacktrace.NewError(
+			"Unrecognized bootnode mode '%v'; valid values are '%v' and '%v'",
+			params.BootnodeMode,
+			signerBootnodeMode,
+			discoveryOnlyBootnodeMode,
+		)
 	}
 
-	apiNodeInfo, err := getApiNodeObjFromNodeServiceCtx(serviceCtx)
-	if err != nil {
-		return nil, "", nil, stacktrace.Propagate(err, "An error occurred getting the node info API object from the boot node's service context")
+	if params.BootnodeMode != discoveryOnlyBootnodeMode {
+		if params.SignerCount != 0 {
+			return stacktrace.NewError("A signer count was provided, but with bootnode mode '%v' the bootnode is the only signer", params.BootnodeMode)
+		}
+		// The bootnode is the only signer, so a second one would also have to seal blocks
+		if params.BootnodeCount > 1 {
+			return stacktrace.NewError("Only one bootnode can be started with bootnode mode '%v'; use '%v' for more", params.BootnodeMode, discoveryOnlyBootnodeMode)
+		}
+		params.BootnodeCount = defaultBootnodeCount
+		return nil
+	}
+	// The Engine API driver produces the blocks through the bootnode, so there must be a Geth node there
+	if params.BlockProduction != cliqueBlockProduction {
+		return stacktrace.NewError("Bootnode mode '%v' is only supported with '%v' block production", discoveryOnlyBootnodeMode, cliqueBlockProduction)
 	}
+	if params.SignerCount == 0 {
+		params.SignerCount = defaultSignerCount
+	}
+	if params.BootnodeCount == 0 {
+		params.BootnodeCount = defaultBootnodeCount
+	}
+	return nil
+}
 
-	return serviceCtx, logOutput, apiNodeInfo, nil
+func applyDefaultsAndValidateNodeSpecs(params *ModuleAPIExecuteArgs) error {
+	if len(params.Nodes) == 0 {
+		for i := 1; i <= childEthNodeQuantity; i++ {
+			params.Nodes = append(params.Nodes, &ModuleAPINodeSpec{ServiceID: getChildEthNodeServiceId(i)})
+		}
+	}
+
+	seenServiceIds := map[services.ServiceID]bool{}
+	for _, nodeSpec := range params.Nodes {
+		if nodeSpec == nil {
+			return stacktrace.NewError("The node specs can't contain null entries")
+		}
+		if !strings.HasPrefix(string(nodeSpec.ServiceID), childEthNodeServiceIdPrefix) {
+			return stacktrace.NewError("Node service ID '%v' must start with '%v', which is how the module recognizes its nodes", nodeSpec.ServiceID, childEthNodeServiceIdPrefix)
+		}
+		if seenServiceIds[nodeSpec.ServiceID] {
+			return stacktrace.NewError("Node service ID '%v' is used by more than one node", nodeSpec.ServiceID)
+		}
+		seenServiceIds[nodeSpec.ServiceID] = true
+		if err := applyDefaultsAndValidateNodeSpec(nodeSpec); err != nil {
+			return stacktrace.Propagate(err, "The spec of node '%v' was invalid", nodeSpec.ServiceID)
+		}
+		applyResourceDefaults(nodeSpec, params.ResourceDefaults)
+	}
+	return nil
+}
+
+func applyDefaultsAndValidateNodeSpec(nodeSpec *ModuleAPINodeSpec) error {
+	switch nodeSpec.GcMode {
+	case "":
+		nodeSpec.GcMode = archiveGcMode
+	case archiveGcMode, fullGcMode:
+	default:
+		return stacktrace.NewError("Unrecognized GC mode '%v'; valid values are '%v' and '%v'", nodeSpec.GcMode, archiveGcMode, fullGcMode)
+	}
+
+	switch nodeSpec.SyncMode {
+	case "":
+		nodeSpec.SyncMode = fullSyncMode
+	case fullSyncMode, snapSyncMode:
+	default:
+		return stacktrace.NewError("Unrecognized sync mode '%v'; valid values are '%v' and '%v'", nodeSpec.SyncMode, fullSyncMode, snapSyncMode)
+	}
+
+	if err := validateMemoryLimit(nodeSpec.MemoryMegabytes); err != nil {
+		return stacktrace.Propagate(err, "The memory limit was invalid")
+	}
+	return nil
+}
+
+// Actions work on the network as it was created, so only the params of the action itself are accepted
+func applyDefaultsAndValidateActionParams(params *ModuleAPIExecuteArgs) error {
+	unexpectedParamNames := getUnexpectedParamNames(params, paramNamesByAction[params.Action])
+	if params.Action == StatusAction {
+		if len(unexpectedParamNames) > 0 {
+			return stacktrace.NewError("Action '%v' only reports on the nodes already in the enclave, so it takes no other params, but got params %v", params.Action, unexpectedParamNames)
+		}
+		return nil
+	}
+	if len(unexpectedParamNames) > 0 {
+		return stacktrace.NewError(
+			"Action '%v' uses the settings that the existing network was created with, so it only takes params %v, but got params %v",
+			params.Action,
+			paramNamesByAction[params.Action],
+			unexpectedParamNames,
+		)
+	}
+	if params.MaxConcurrency == 0 {
+		params.MaxConcurrency = defaultMaxConcurrency
+	}
+	if err := applyDefaultsAndValidateSecretsOutput(params); err != nil {
+		return stacktrace.Propagate(err, "The secrets output was invalid")
+	}
+
+	if params.Mode != "" && params.Mode != networkMode {
+		return stacktrace.NewError("Action '%v' can only be executed on a network started in '%v' mode", params.Action, networkMode)
+	}
+	params.Mode = networkMode
+
+	if params.Action == AddNodesAction {
+		if params.NodeCount == 0 {
+			params.NodeCount = defaultNumNodesToAdd
+		}
+		return nil
+	}
+	if params.NodeServiceID == "" {
+		return stacktrace.NewError("Action '%v' requires the service ID of the node to act on", params.Action)
+	}
+	return nil
 }
 
+// Starts the bootnodes along with the Geth nodes, returning the info of the Geth nodes and the service contexts of all the nodes
 func startEthNodes(
-	enclaveCtx *enclaves.EnclaveContext,
-	staticFilesArtifactUuid services.FilesArtifactUUID,
-) (map[services.ServiceID]*ModuleAPIEthereumNodeInfo, *services.ServiceContext, error) {
-	bootnodeServiceCtx, bootnodeEnr, bootnodeInfo, err := startEthBootnode(enclaveCtx, staticFilesArtifactUuid)
+	enclaveCtx nodeEnclave,
+	launchConfig *nodeLaunchConfig,
+	childNodeSpecs []*ModuleAPINodeSpec,
+	maxConcurrency uint32,
+) (map[services.ServiceID]*ModuleAPIEthereumNodeInfo, map[services.ServiceID]*services.ServiceContext, []*ModuleAPIStartupPhase, error) {
+	startupPhases := []*ModuleAPIStartupPhase{}
+
+	// The signers can't start without their Clef
+	if launchConfig.signerBackend == clefSignerBackend {
+		phaseStartTime := time.Now()
+		clefIpAddrs, err := startClefSigners(enclaveCtx, launchConfig, maxConcurrency)
+		if err != nil {
+			return nil, nil, nil, stacktrace.Propagate(err, "An error occurred starting the Clef signers")
+		}
+		launchConfig.clefIpAddrs = clefIpAddrs
+		startupPhases = recordStartupPhase(startupPhases, clefSignersStartupPhase, phaseStartTime)
+	}
+
+	phaseStartTime := time.Now()
+	bootnodeServiceCtxs, gethBootnodeInfo, err := startEthBootnodes(enclaveCtx, launchConfig, maxConcurrency)
+	if err != nil {
+		return nil, nil, nil, stacktrace.Propagate(err, "An error occurred starting the Ethereum bootnodes")
+	}
+	bootnodeEnrs, err := getBootnodeEnrs(launchConfig, bootnodeServiceCtxs)
 	if err != nil {
-		return nil, nil, stacktrace.Propagate(err, "An error occurred starting the Ethereum bootnode")
+		return nil, nil, nil, stacktrace.Propagate(err, "An error occurred getting the ENRs of the bootnodes")
 	}
 
-	// Start all child nodes without waiting for them to become available, to speed up startup
-	childNodeInfo := map[services.ServiceID]*ModuleAPIEthereumNodeInfo{}
-	allNodeServiceCtxs := map[services.ServiceID]*services.ServiceContext{
-		bootnodeServiceID: bootnodeServiceCtx,
+	// The other nodes already know the ENRs of the bootnodes, so they get started while the bootnodes are still coming up
+	bootnodeAvailabilityErrChan := make(chan error, 1)
+	existingNodeServiceCtxs := map[services.ServiceID]*services.ServiceContext{}
+	allNodeInfo := map[services.ServiceID]*ModuleAPIEthereumNodeInfo{}
+	nodeSpecs := childNodeSpecs
+	if launchConfig.bootnodeMode == discoveryOnlyBootnodeMode {
+		// The bootnode binary has no RPC endpoint to wait on, and the nodes keep retrying discovery until it answers
+		bootnodeAvailabilityErrChan <- nil
+		signerNodeSpecs, err := getSignerNodeSpecs(launchConfig.signerCount, launchConfig.resourceDefaults)
+		if err != nil {
+			return nil, nil, nil, stacktrace.Propagate(err, "An error occurred getting the specs of the signer nodes")
+		}
+		nodeSpecs = append(signerNodeSpecs, childNodeSpecs...)
+	} else {
+		go func() {
+			bootnodeAvailabilityErrChan <- waitForNodeAvailability(enclaveCtx, launchConfig.rpcTransport, bootnodeServiceID, launchConfig.rpcJwtSecret)
+		}()
+		existingNodeServiceCtxs[bootnodeServiceID] = bootnodeServiceCtxs[bootnodeServiceID]
+		allNodeInfo[bootnodeServiceID] = gethBootnodeInfo
+	}
+	newNodeInfo, newServiceCtxs, err := addEthChildNodes(enclaveCtx, launchConfig, bootnodeEnrs, existingNodeServiceCtxs, nodeSpecs, maxConcurrency)
+	if err != nil {
+		return nil, nil, nil, stacktrace.Propagate(err, "An error occurred adding the Ethereum child nodes")
+	}
+	if err := <-bootnodeAvailabilityErrChan; err != nil {
+		return nil, nil, nil, stacktrace.Propagate(err, "An error occurred waiting for the Ethereum bootnode to become available")
 	}
-	for i := 1; i <= childEthNodeQuantity; i++ {
-		serviceId := services.ServiceID(childEthNodeServiceIdPrefix + strconv.Itoa(i))
+	startupPhases = recordStartupPhase(startupPhases, nodesStartupPhase, phaseStartTime)
 
-		containerConfig := getEthNodeContainerConfig(bootnodeEnr, staticFilesArtifactUuid)
+	// The tree holds the records of all the Geth nodes, so it can only be served once they all have an IP
+	gethNodeServiceCtxs := map[services.ServiceID]*services.ServiceContext{}
+	for serviceId, serviceCtx := range existingNodeServiceCtxs {
+		gethNodeServiceCtxs[serviceId] = serviceCtx
+	}
+	for newServiceId, newServiceCtx := range newServiceCtxs {
+		gethNodeServiceCtxs[newServiceId] = newServiceCtx
+	}
+	if launchConfig.nodeDiscovery == dnsNodeDiscovery {
+		phaseStartTime = time.Now()
+		dnsServerServiceCtx, err := startDnsDiscoveryServer(enclaveCtx, launchConfig.keySeed, gethNodeServiceCtxs)
+		if err != nil {
+			return nil, nil, nil, stacktrace.Propagate(err, "An error occurred starting the DNS discovery server")
+		}
+		launchConfig.dnsServerIpAddr = dnsServerServiceCtx.GetPrivateIPAddress()
+		if err := pointNodesAtDnsServer(newServiceCtxs, launchConfig.dnsServerIpAddr, maxConcurrency); err != nil {
+			return nil, nil, nil, stacktrace.Propagate(err, "An error occurred pointing the nodes at the DNS discovery server")
+		}
+		startupPhases = recordStartupPhase(startupPhases, dnsDiscoveryStartupPhase, phaseStartTime)
+	}
+
+	// The bootnode finds the other nodes through discovery, but connecting them together is left to us
+	phaseStartTime = time.Now()
+	if launchConfig.peeringStrategy == adminAddPeerPeeringStrategy {
+		if err := connectNewPeers(newServiceCtxs, map[services.ServiceID]*services.ServiceContext{}, launchConfig, maxConcurrency); err != nil {
+			return nil, nil, nil, stacktrace.Propagate(err, "An error occurred connecting the child nodes together")
+		}
+		startupPhases = recordStartupPhase(startupPhases, peerConnectionStartupPhase, phaseStartTime)
+	}
+
+	// Finally, verify that each Geth node has the correct number of peers
+	phaseStartTime = time.Now()
+	if err := verifyFullyPeered(gethNodeServiceCtxs, launchConfig, maxConcurrency, 0); err != nil {
+		return nil, nil, nil, stacktrace.Propagate(err, "An error occurred verifying that the nodes are peered with each other")
+	}
+	startupPhases = recordStartupPhase(startupPhases, peeringVerificationStartupPhase, phaseStartTime)
+
+	for newServiceId, newInfo := range newNodeInfo {
+		allNodeInfo[newServiceId] = newInfo
+	}
+	allNodeServiceCtxs := map[services.ServiceID]*services.ServiceContext{}
+	for serviceId, serviceCtx := range bootnodeServiceCtxs {
+		allNodeServiceCtxs[serviceId] = serviceCtx
+	}
+	for serviceId, serviceCtx := range gethNodeServiceCtxs {
+		allNodeServiceCtxs[serviceId] = serviceCtx
+	}
+
+	return allNodeInfo, allNodeServiceCtxs, startupPhases, nil
+}
+
+// Adds child nodes that discover the network through the bootnodes with the given ENRs, waiting for all of them to become available
+// With a peering strategy that writes peer lists, each node lists the existing nodes and the new nodes added before it, so
+// the nodes get added one at a time; otherwise they get added concurrently
+func addEthChildNodes(
+	enclaveCtx nodeEnclave,
+	launchConfig *nodeLaunchConfig,
+	bootnodeEnrs []string,
+	existingNodeServiceCtxs map[services.ServiceID]*services.ServiceContext,
+	nodeSpecs []*ModuleAPINodeSpec,
+	maxConcurrency uint32,
+) (
+	map[services.ServiceID]*ModuleAPIEthereumNodeInfo,
+	map[services.ServiceID]*services.ServiceContext,
+	error,
+) {
+	serviceIds := []services.ServiceID{}
+	nodeSpecsByServiceId := map[services.ServiceID]*ModuleAPINodeSpec{}
+	for _, nodeSpec := range nodeSpecs {
+		serviceIds = append(serviceIds, nodeSpec.ServiceID)
+		nodeSpecsByServiceId[nodeSpec.ServiceID] = nodeSpec
+	}
+	sortServiceIds(serviceIds)
+
+	peerEnodes := []string{}
+	maxAddConcurrency := maxConcurrency
+	if getPeerListConfigKey(launchConfig.peeringStrategy) != "" {
+		existingEnodeAddrs, err := getSortedEnodeAddresses(launchConfig.keySeed, existingNodeServiceCtxs)
+		if err != nil {
+			return nil, nil, stacktrace.Propagate(err, "An error occurred getting the enode addresses of the existing nodes")
+		}
+		peerEnodes = existingEnodeAddrs
+		maxAddConcurrency = 1
+	}
+
+	resultsMutex := &sync.Mutex{}
+	childNodeInfo := map[services.ServiceID]*ModuleAPIEthereumNodeInfo{}
+	childServiceCtxs := map[services.ServiceID]*services.ServiceContext{}
+	err := runForEachNode(serviceIds, maxAddConcurrency, func(serviceId services.ServiceID) error {
+		resultsMutex.Lock()
+		nodePeerEnodes := append([]string{}, peerEnodes...)
+		resultsMutex.Unlock()
+		gethConfig, err := renderGethConfig(serviceId, launchConfig, nodeSpecsByServiceId[serviceId], bootnodeEnrs, nodePeerEnodes)
+		if err != nil {
+			return stacktrace.Propagate(err, "An error occurred rendering the Geth config of Ethereum node with service ID '%v'", serviceId)
+		}
+		nodeFilesArtifactUuid, err := uploadNodeFiles(enclaveCtx, launchConfig.keySeed, serviceId, gethConfig)
+		if err != nil {
+			return stacktrace.Propagate(err, "An error occurred uploading the files of Ethereum node with service ID '%v'", serviceId)
+		}
+		accountFilesArtifactUuid, err := uploadAccountFiles(enclaveCtx, launchConfig, serviceId)
+		if err != nil {
+			return stacktrace.Propagate(err, "An error occurred uploading the account files of Ethereum node with service ID '%v'", serviceId)
+		}
+		containerConfig, err := getEthNodeContainerConfig(launchConfig, nodeSpecsByServiceId[serviceId], nodeFilesArtifactUuid, accountFilesArtifactUuid)
+		if err != nil {
+			return stacktrace.Propagate(err, "An error occurred getting the container config for Ethereum node with service ID '%v'", serviceId)
+		}
 
 		serviceCtx, err := enclaveCtx.AddService(serviceId, containerConfig)
 		if err != nil {
-			return nil, nil, stacktrace.Propagate(err, "An error occurred adding Ethereum node with service ID '%v'", serviceId)
+			return stacktrace.Propagate(err, "An error occurred adding Ethereum node with service ID '%v'", serviceId)
 		}
 		logrus.Infof(
-			"Added Ethereum child node service with public IP: %v and public ports: %+v",
+			"Added Ethereum child node service '%v' with public IP: %v and public ports: %+v",
+			serviceId,
 			serviceCtx.GetMaybePublicIPAddress(),
 			serviceCtx.GetPublicPorts(),
 		)
 
-		apiNodeInfo, err := getApiNodeObjFromNodeServiceCtx(serviceCtx)
+		apiNodeInfo, err := getApiNodeObjFromNodeServiceCtx(serviceCtx, launchConfig)
 		if err != nil {
-			return nil, nil, stacktrace.Propagate(err, "An error occurred getting the node info API object from the service context of child node '%v'", serviceId)
+			return stacktrace.Propagate(err, "An error occurred getting the node info API object from the service context of child node '%v'", serviceId)
 		}
+		apiNodeInfo.NodeFilesArtifactUUID = nodeFilesArtifactUuid
 
+		resultsMutex.Lock()
+		defer resultsMutex.Unlock()
 		childNodeInfo[serviceId] = apiNodeInfo
-		allNodeServiceCtxs[serviceId] = serviceCtx
+		childServiceCtxs[serviceId] = serviceCtx
+		peerEnodes = append(peerEnodes, apiNodeInfo.Enode)
+		return nil
+	})
+	if err != nil {
+		return nil, nil, stacktrace.Propagate(err, "An error occurred adding the child nodes")
 	}
 
-	// Now after all child nodes are started, wait for them to become available
-	for childServiceId := range childNodeInfo {
-		if err := enclaveCtx.WaitForHttpPostEndpointAvailability(childServiceId, uint32(rpcPortNum), "", adminInfoRpcCall, waitEndpointInitialDelayMilliseconds, waitEndpointRetries, waitEndpointRetriesDelayMilliseconds, ""); err != nil {
-			return nil, nil, stacktrace.Propagate(err, "An error occurred waiting for child node with ID '%v' to start", childServiceId)
+	// Waiting is done separately from adding, so that it stays concurrent even when the nodes get added one at a time
+	err = runForEachNode(serviceIds, maxConcurrency, func(serviceId services.ServiceID) error {
+		if err := waitForNodeAvailability(enclaveCtx, launchConfig.rpcTransport, serviceId, launchConfig.rpcJwtSecret); err != nil {
+			return stacktrace.Propagate(err, "An error occurred waiting for child node with ID '%v' to start", serviceId)
 		}
+		return nil
+	})
+	if err != nil {
+		return nil, nil, stacktrace.Propagate(err, "An error occurred waiting for the child nodes to become available")
 	}
 
-	// Get the child ENRs, for use in adding peers...
-	childEnodeAddrs := map[services.ServiceID]string{}
-	peersToConnectPerNode := map[services.ServiceID][]string{}
-	for childServiceId := range childNodeInfo {
-		childServiceCtx, found := allNodeServiceCtxs[childServiceId]
-		if !found {
-			return nil, nil, stacktrace.NewError("No service context found for child node '%v'; this is a bug with this module", childServiceId)
-		}
+	return childNodeInfo, childServiceCtxs, nil
+}
 
-		childPeers := []string{}
-		for _, peerEnode := range childEnodeAddrs {
-			childPeers = append(childPeers, peerEnode)
-		}
-		peersToConnectPerNode[childServiceId] = childPeers
+// Connects every new node to all the existing nodes and to each other, because Geth gossip is sloww
+// Only the "admin_add_peer" peering strategy connects nodes this way; with the others the nodes find each other themselves
+func connectNewPeers(
+	newNodeServiceCtxs map[services.ServiceID]*services.ServiceContext,
+	existingNodeServiceCtxs map[services.ServiceID]*services.ServiceContext,
+	launchConfig *nodeLaunchConfig,
+	maxConcurrency uint32,
+) error {
+	if launchConfig.peeringStrategy != adminAddPeerPeeringStrategy {
+		return nil
+	}
+	existingEnodeAddrs, err := getEnodeAddresses(launchConfig.keySeed, existingNodeServiceCtxs)
+	if err != nil {
+		return stacktrace.Propagate(err, "An error occurred getting the enode addresses of the existing nodes")
+	}
+	newEnodeAddrs, err := getEnodeAddresses(launchConfig.keySeed, newNodeServiceCtxs)
+	if err != nil {
+		return stacktrace.Propagate(err, "An error occurred getting the enode addresses of the new nodes")
+	}
+	if err := connectPeers(newNodeServiceCtxs, newEnodeAddrs, existingEnodeAddrs, launchConfig.rpcTransport, launchConfig.rpcJwtSecret, maxConcurrency); err != nil {
+		return stacktrace.Propagate(err, "An error occurred connecting the new nodes")
+	}
+	return nil
+}
 
-		enodeAddr, err := getEnodeAddress(childServiceCtx.GetPrivateIPAddress())
+func getEnodeAddresses(keySeed string, serviceCtxs map[services.ServiceID]*services.ServiceContext) (map[services.ServiceID]string, error) {
+	enodeAddrs := map[services.ServiceID]string{}
+	for serviceId, serviceCtx := range serviceCtxs {
+		enodeAddr, err := getEnodeAddress(keySeed, serviceCtx)
 		if err != nil {
-			return nil, nil, stacktrace.Propagate(err, "Couldn't get enode address for child node '%v'", childServiceId)
+			return nil, stacktrace.Propagate(err, "Couldn't get enode address for node '%v'", serviceId)
 		}
-		childEnodeAddrs[childServiceId] = enodeAddr
+		enodeAddrs[serviceId] = enodeAddr
+	}
+	return enodeAddrs, nil
+}
+
+// Returns the enode addresses of the given nodes in service ID order, so the peer lists built from them are deterministic
+func getSortedEnodeAddresses(keySeed string, serviceCtxs map[services.ServiceID]*services.ServiceContext) ([]string, error) {
+	enodeAddrs, err := getEnodeAddresses(keySeed, serviceCtxs)
+	if err != nil {
+		return nil, stacktrace.Propagate(err, "An error occurred getting the enode addresses")
+	}
+	result := []string{}
+	for _, serviceId := range getSortedServiceIds(serviceCtxs) {
+		result = append(result, enodeAddrs[serviceId])
 	}
+	return result, nil
+}
 
-	// ...and connect all the peers together, because Geth gossip is sloww
-	for childServiceId, childPeersToConnect := range peersToConnectPerNode {
-		childServiceCtx, found := allNodeServiceCtxs[childServiceId]
-		if !found {
-			return nil, nil, stacktrace.NewError("No service context for child '%v'; this is a bug with this module", childServiceId)
+// Makes each new node add all the existing nodes as peers, along with the new nodes that come before it in service ID
+// order, so that every pair of nodes gets connected exactly once
+func connectPeers(
+	newNodeServiceCtxs map[services.ServiceID]*services.ServiceContext,
+	newEnodeAddrs map[services.ServiceID]string,
+	existingEnodeAddrs map[services.ServiceID]string,
+	rpcTransport rpcTransport,
+	rpcJwtSecret []byte,
+	maxConcurrency uint32,
+) error {
+	newServiceIds := getSortedServiceIds(newNodeServiceCtxs)
+	peersToConnectPerNode := map[services.ServiceID][]string{}
+	for idx, newServiceId := range newServiceIds {
+		peersToConnect := []string{}
+		for _, enodeAddr := range existingEnodeAddrs {
+			peersToConnect = append(peersToConnect, enodeAddr)
+		}
+		for _, precedingServiceId := range newServiceIds[:idx] {
+			enodeAddr, found := newEnodeAddrs[precedingServiceId]
+			if !found {
+				return stacktrace.NewError("No enode address for node '%v'; this is a bug with this module", precedingServiceId)
+			}
+			peersToConnect = append(peersToConnect, enodeAddr)
 		}
-		for _, peerEnode := range childPeersToConnect {
-			if err := addPeer(childServiceCtx.GetPrivateIPAddress(), peerEnode); err != nil {
-				return nil, nil, stacktrace.Propagate(
+		peersToConnectPerNode[newServiceId] = peersToConnect
+	}
+
+	err := runForEachNode(newServiceIds, maxConcurrency, func(serviceId services.ServiceID) error {
+		for _, peerEnode := range peersToConnectPerNode[serviceId] {
+			if err := addPeer(rpcTransport, newNodeServiceCtxs[serviceId], rpcJwtSecret, peerEnode); err != nil {
+				return stacktrace.Propagate(
 					err,
-					"An error occurred connecting peer enode '%v' to child with service ID '%v'",
+					"An error occurred connecting peer enode '%v' to node with service ID '%v'",
 					peerEnode,
-					childServiceId,
+					serviceId,
 				)
 			}
 		}
+		return nil
+	})
+	if err != nil {
+		return stacktrace.Propagate(err, "An error occurred connecting the peers")
 	}
+	return nil
+}
 
-	// Finally, verify that each node has the correct number of peers
+// Verifies that each of the given nodes is peered with all the others, and with at most the given number of stopped nodes
+func verifyFullyPeered(
+	allNodeServiceCtxs map[services.ServiceID]*services.ServiceContext,
+	launchConfig *nodeLaunchConfig,
+	maxConcurrency uint32,
+	numRecentlyStoppedPeers int,
+) error {
 	numExpectedPeersPerNode := len(allNodeServiceCtxs) - 1
-	for serviceId, serviceCtx := range allNodeServiceCtxs {
-		isPeerCountValidated := false
-		for i := 0; i < maxNumPeerCountValidationAttempts; i++ {
-			if verifyErr := verifyExpectedNumberPeers(serviceId, serviceCtx, numExpectedPeersPerNode); verifyErr == nil {
-				isPeerCountValidated = true
-				break
-			} else {
-				logrus.Debugf(
-					"Verifying expected number of peers on node '%v' failed with error:\n%v",
-					serviceId,
-					verifyErr,
-				)
-				time.Sleep(timeBetweenPeerCountValidationAttempts)
+	// Nodes that peer on their own can take a while to find and dial each other, unlike nodes told to add each other
+	numValidationAttempts := maxNumPeerCountValidationAttempts
+	if launchConfig.peeringStrategy != adminAddPeerPeeringStrategy {
+		numValidationAttempts = maxNumSelfPeeringPeerCountValidationAttempts
+	}
+	err := runForEachNode(getSortedServiceIds(allNodeServiceCtxs), maxConcurrency, func(serviceId services.ServiceID) error {
+		serviceCtx := allNodeServiceCtxs[serviceId]
+		for i := 0; i < numValidationAttempts; i++ {
+			verifyErr := verifyExpectedNumberPeers(serviceId, serviceCtx, launchConfig.dockerImage, numExpectedPeersPerNode, numRecentlyStoppedPeers)
+			if verifyErr == nil {
+				return nil
 			}
-		}
-		if !isPeerCountValidated {
-			return nil, nil, stacktrace.NewError(
-				"Service '%v' didn't reach expected number of peers '%v', even after %v attempts with %v between attempts",
+			logrus.Debugf(
+				"Verifying expected number of peers on node '%v' failed with error:\n%v",
 				serviceId,
-				numExpectedPeersPerNode,
-				maxNumPeerCountValidationAttempts,
-				timeBetweenPeerCountValidationAttempts,
+				verifyErr,
 			)
+			time.Sleep(timeBetweenPeerCountValidationAttempts)
 		}
+		return stacktrace.NewError(
+			"Service '%v' didn't reach expected number of peers '%v', even after %v attempts with %v between attempts",
+			serviceId,
+			numExpectedPeersPerNode,
+			numValidationAttempts,
+			timeBetweenPeerCountValidationAttempts,
+		)
+	})
+	if err != nil {
+		return stacktrace.Propagate(err, "Not all nodes are fully peered")
 	}
+	return nil
+}
 
-	allNodeInfo := map[services.ServiceID]*ModuleAPIEthereumNodeInfo{
-		bootnodeServiceID: bootnodeInfo,
+// Reads the spec that a child node was started with, falling back to the default spec for nodes started before specs existed
+func getNodeSpec(serviceId services.ServiceID, serviceCtx *services.ServiceContext) (*ModuleAPINodeSpec, error) {
+	nodeSpec := &ModuleAPINodeSpec{ServiceID: serviceId}
+	exitCode, serializedNodeSpec, err := serviceCtx.ExecCommand([]string{"printenv", nodeSpecEnvVar})
+	if err != nil {
+		return nil, stacktrace.Propagate(err, "An error occurred reading the spec of node '%v'", serviceId)
 	}
-	for childServiceId, childInfo := range childNodeInfo {
-		allNodeInfo[childServiceId] = childInfo
+	if exitCode == execCommandSuccessExitCode {
+		if err := json.Unmarshal([]byte(serializedNodeSpec), nodeSpec); err != nil {
+			return nil, stacktrace.Propagate(err, "An error occurred deserializing spec '%v' of node '%v'", serializedNodeSpec, serviceId)
+		}
+	}
+	if err := applyDefaultsAndValidateNodeSpec(nodeSpec); err != nil {
+		return nil, stacktrace.Propagate(err, "Node '%v' was started with an invalid spec", serviceId)
 	}
+	return nodeSpec, nil
+}
 
-	return allNodeInfo, bootnodeServiceCtx, nil
+func getChildEthNodeServiceId(childIdx int) services.ServiceID {
+	return services.ServiceID(childEthNodeServiceIdPrefix + strconv.Itoa(childIdx))
 }
 
-func getApiNodeObjFromNodeServiceCtx(serviceCtx *services.ServiceContext) (*ModuleAPIEthereumNodeInfo, error) {
-	return &ModuleAPIEthereumNodeInfo{
+// Waits for the node to answer RPC calls, on its JWT-authenticated endpoint if a JWT secret is given
+func waitForNodeAvailability(enclaveCtx serviceWaiter, transport rpcTransport, serviceId services.ServiceID, rpcJwtSecret []byte) error {
+	if rpcJwtSecret != nil {
+		if err := waitForAuthRpcAvailability(enclaveCtx, transport, serviceId, rpcJwtSecret); err != nil {
+			return stacktrace.Propagate(err, "An error occurred waiting for service with ID '%v' to start", serviceId)
+		}
+		return nil
+	}
+	if err := enclaveCtx.WaitForHttpPostEndpointAvailability(serviceId, uint32(rpcPortNum), "", adminInfoRpcCall, waitEndpointInitialDelayMilliseconds, waitEndpointRetries, waitEndpointRetriesDelayMilliseconds, ""); err != nil {
+		return stacktrace.Propagate(err, "An error occurred waiting for service with ID '%v' to start", serviceId)
+	}
+	return nil
+}
+
+func getApiNodeObjFromNodeServiceCtx(serviceCtx *services.ServiceContext, launchConfig *nodeLaunchConfig) (*ModuleAPIEthereumNodeInfo, error) {
+	nodeInfo := &ModuleAPIEthereumNodeInfo{
 		IPAddrInsideNetwork: serviceCtx.GetPrivateIPAddress(),
 		IPAddrOnHostMachine: serviceCtx.GetMaybePublicIPAddress(),
-		RpcPortId:           rpcPortId,
-		WsPortId:            wsPortId,
 		TcpDiscoveryPortId:  tcpDiscoveryPortId,
 		UdpDiscoveryPortId:  udpDiscoveryPortId,
-	}, nil
+	}
+	if launchConfig.hardenedRpc == nil {
+		nodeInfo.RpcPortId = rpcPortId
+		nodeInfo.WsPortId = wsPortId
+	}
+	if isAuthRpcEnabled(launchConfig) {
+		nodeInfo.AuthRpcPortId = authRpcPortId
+	}
+	nodeRecord, err := getNodeRecord(launchConfig.keySeed, serviceCtx.GetServiceID(), serviceCtx.GetPrivateIPAddress())
+	if err != nil {
+		return nil, stacktrace.Propagate(err, "An error occurred computing the record of node '%v'", serviceCtx.GetServiceID())
+	}
+	nodeInfo.Enode = nodeRecord.URLv4()
+	nodeInfo.Enr = nodeRecord.String()
+	return nodeInfo, nil
 }
 
-func verifyExpectedNumberPeers(serviceId services.ServiceID, serviceCtx *services.ServiceContext, numExpectedPeers int) error {
-	cmd := "geth attach data/geth.ipc --exec admin.peers"
-	exitCode, logOutput, err := serviceCtx.ExecCommand([]string{
-		"/bin/sh",
-		"-c",
-		cmd,
-	})
+// A node that was stopped recently can still be counted as a peer until its connections time out, so up to the given number
+// of extra peers are tolerated
+func verifyExpectedNumberPeers(serviceId services.ServiceID, serviceCtx commandExecutor, dockerImage string, numExpectedPeers int, numRecentlyStoppedPeers int) error {
+	cmd, err := newGethCommandBuilder(
+		dockerImage,
+		"attach",
+	).withFlag(
+		"exec", "admin.peers",
+	).withArg(
+		path.Join(gethDataDirpath, gethIpcFilename),
+	).build()
+	if err != nil {
+		return stacktrace.Propagate(err, "An error occurred building the command that gets the peers of node '%v'", serviceId)
+	}
+	exitCode, logOutput, err := serviceCtx.ExecCommand(getShellEntrypointArgs(cmd))
 	if err != nil {
 		return stacktrace.Propagate(
 			err,
@@ -335,27 +1270,30 @@ func verifyExpectedNumberPeers(serviceId services.ServiceID, serviceCtx *service
 
 	// peersQuantity := strings.Count(logOutputStr, enodePrefix) - strings.Count(logOutputStr, handshakeProtocol)
 	peersQuantity := strings.Count(logOutput, enodePrefix) - strings.Count(logOutput, handshakeProtocol)
-	if peersQuantity != numExpectedPeers {
+	if peersQuantity < numExpectedPeers || peersQuantity > numExpectedPeers+numRecentlyStoppedPeers {
 		return stacktrace.NewError(
-			"Expected '%v' peers for node '%v' but got '%v'",
+			"Expected '%v' peers for node '%v', plus up to '%v' recently stopped peers, but got '%v'",
 			numExpectedPeers,
 			serviceId,
+			numRecentlyStoppedPeers,
 			peersQuantity,
 		)
 	}
 	return nil
 }
 
-func sendRpcCall(privateIpAddr string, rpcJsonString string, targetStruct interface{}) error {
+func sendRpcCall(transport rpcTransport, privateIpAddr string, rpcJsonString string, targetStruct interface{}) error {
 	url := fmt.Sprintf("http://%v:%v", privateIpAddr, rpcPortNum)
 	var jsonByteArray = []byte(rpcJsonString)
 
 	logrus.Debugf("Sending RPC call to '%v' with JSON body '%v'...", url, rpcJsonString)
 
-	client := http.Client{
-		Timeout: rpcRequestTimeout,
+	request, err := http.NewRequest(http.MethodPost, url, bytes.NewBuffer(jsonByteArray))
+	if err != nil {
+		return stacktrace.Propagate(err, "An error occurred creating the RPC request to '%v'", url)
 	}
-	resp, err := client.Post(url, jsonContentType, bytes.NewBuffer(jsonByteArray))
+	request.Header.Set("Content-Type", jsonContentType)
+	resp, err := transport.Do(request)
 	if err != nil {
 		return stacktrace.Propagate(err, "Failed to send RPC request to Geth node with ip '%v'", privateIpAddr)
 	}
@@ -383,12 +1321,12 @@ func sendRpcCall(privateIpAddr string, rpcJsonString string, targetStruct interf
 }
 
 // Geth gossiping is slowww, so we manually add nodes to speed it up
-func addPeer(privateIpAddr string, peerEnode string) error {
+func addPeer(transport rpcTransport, serviceCtx *services.ServiceContext, rpcJwtSecret []byte, peerEnode string) error {
 	adminAddPeerRpcCall := fmt.Sprintf(`{"jsonrpc":"2.0", "method": "admin_addPeer", "params": ["%v"], "id":70}`, peerEnode)
-	logrus.Infof("Admin add peer rpc call: %v", adminAddPeerRpcCall)
+	logrus.Debugf("Admin add peer rpc call: %v", adminAddPeerRpcCall)
 	addPeerResponse := new(EthAPIAddPeerResponse)
-	err := sendRpcCall(privateIpAddr, adminAddPeerRpcCall, addPeerResponse)
-	logrus.Infof("addPeer response: %+v", addPeerResponse)
+	err := sendNodeRpcCall(transport, serviceCtx, rpcJwtSecret, adminAddPeerRpcCall, addPeerResponse)
+	logrus.Debugf("addPeer response: %+v", addPeerResponse)
 	if err != nil {
 		return stacktrace.Propagate(err, "Failed to send addPeer RPC call for enode %v", peerEnode)
 	}
@@ -396,22 +1334,13 @@ func addPeer(privateIpAddr string, peerEnode string) error {
 		return stacktrace.NewError(
 			"Ethereum returned 'false' response to addPeer request to add enode '%v' to node with IP '%v'",
 			peerEnode,
-			privateIpAddr,
+			serviceCtx.GetPrivateIPAddress(),
 		)
 	}
 	return nil
 }
 
-func getEnodeAddress(privateIpAddr string) (string, error) {
-	nodeInfoResponse := new(EthAPINodeInfoResponse)
-	err := sendRpcCall(privateIpAddr, adminInfoRpcCall, nodeInfoResponse)
-	if err != nil {
-		return "", stacktrace.Propagate(err, "Failed to send admin node info RPC request to Geth node with ip %v", privateIpAddr)
-	}
-	return nodeInfoResponse.Result.Enode, nil
-}
-
-func getStaticFileContent(serviceCtx *services.ServiceContext, staticFileName string) (string, error) {
+func getStaticFileContent(serviceCtx commandExecutor, staticFileName string) (string, error) {
 	absFilepathOnNode := getMountedPathOnNodeContainer(staticFileName)
 	catStaticFileCmd := []string{
 		"cat",
@@ -428,98 +1357,174 @@ func getStaticFileContent(serviceCtx *services.ServiceContext, staticFileName st
 	return fileContents, nil
 }
 
-func getBootnodeContainerConfig(staticFilesArtifactUuid services.FilesArtifactUUID) *services.ContainerConfig {
-
-	entryPointArgs := []string{
-		"/bin/sh",
-		"-c",
-		fmt.Sprintf(
-			"geth init --datadir data %v && "+
-				"geth "+
-				"--keystore %v "+
-				"--datadir data "+
-				"--networkid %v "+
-				"-http "+
-				"--http.api admin,eth,net,web3,miner,personal,txpool,debug "+
-				"--http.addr=0.0.0.0 "+
-				"--http.port=%v "+
-				"--http.corsdomain '*' "+
-				"--http.vhosts=* "+
-				"--nat extip:"+privateIPAddressPlaceholder+" "+
-				"--port=%v "+
-				"--unlock 0x14f6136b48b74b147926c9f24323d16c1e54a026 --"+
-				"mine "+
-				"--allow-insecure-unlock "+
-				"--password %v",
-			getMountedPathOnNodeContainer(static_files_consts.GenesisStaticFileName),
-			getMountedPathOnNodeContainer(""), // The keystore arg expects a directory containing keys
-			ethNetworkId,
-			rpcPortNum,
-			discoveryPortNum,
-			getMountedPathOnNodeContainer(static_files_consts.SignerAccountPasswordStaticFileName),
-		),
+func getBootnodeContainerConfig(
+	launchConfig *nodeLaunchConfig,
+	nodeFilesArtifactUuid services.FilesArtifactUUID,
+	accountFilesArtifactUuid services.FilesArtifactUUID,
+) (*services.ContainerConfig, error) {
+	initCommand, err := getGethInitCommand(launchConfig)
+	if err != nil {
+		return nil, stacktrace.Propagate(err, "An error occurred building the command that initializes the bootnode")
+	}
+	signerImportCommand, err := getSignerImportCommand(launchConfig, bootnodeServiceID)
+	if err != nil {
+		return nil, stacktrace.Propagate(err, "An error occurred building the command that imports the account of the bootnode")
+	}
+	// Once blocks come from the Engine API, the signer must not try to seal them itself
+	gethCommandBuilder, err := withSignerFlags(getGethCommandBuilder(launchConfig), launchConfig, bootnodeServiceID, !launchConfig.isEngineApiEnabled)
+	if err != nil {
+		return nil, stacktrace.Propagate(err, "An error occurred adding the signer flags of the bootnode")
+	}
+	gethCommand, err := gethCommandBuilder.build()
+	if err != nil {
+		return nil, stacktrace.Propagate(err, "An error occurred building the command that runs the bootnode")
+	}
+	envVars, err := getBootnodeEnvVars(launchConfig)
+	if err != nil {
+		return nil, stacktrace.Propagate(err, "An error occurred getting the environment variables of the bootnode")
 	}
 
 	containerConfig := services.NewContainerConfigBuilder(
-		ethereumDockerImageName,
+		launchConfig.dockerImage,
 	).WithUsedPorts(
-		usedPorts,
+		getUsedPorts(launchConfig),
 	).WithEntrypointOverride(
-		entryPointArgs,
-	).WithFiles(map[services.FilesArtifactUUID]string{
-		staticFilesArtifactUuid: staticFilesMountpointOnNodes,
-	}).WithPrivateIPAddrPlaceholder(
+		getShellEntrypointArgs(initCommand, signerImportCommand, gethCommand),
+	).WithFiles(
+		getFilesArtifactMountpoints(launchConfig, nodeFilesArtifactUuid, accountFilesArtifactUuid),
+	).WithEnvironmentVariableOverrides(
+		envVars,
+	).WithCPUAllocationMillicpus(
+		launchConfig.resourceDefaults.Bootnode.CpuMillicpus,
+	).WithMemoryAllocationMegabytes(
+		launchConfig.resourceDefaults.Bootnode.MemoryMegabytes,
+	).WithPrivateIPAddrPlaceholder(
 		privateIPAddressPlaceholder,
 	).Build()
 
-	return containerConfig
+	return containerConfig, nil
 }
 
 func getEthNodeContainerConfig(
-	bootnodeEnr string,
-	staticFilesArtifactUUid services.FilesArtifactUUID,
-) *services.ContainerConfig {
-
-	entryPointArgs := []string{
-		"/bin/sh",
-		"-c",
-		fmt.Sprintf(
-			"geth init --datadir data %v && "+
-				"geth "+
-				"--datadir data "+
-				"--networkid %v "+
-				"-http "+
-				"--http.api admin,eth,net,web3,miner,personal,txpool,debug "+
-				"--http.addr=0.0.0.0 "+
-				"--http.port=%v "+
-				"--http.corsdomain '*' "+
-				"--http.vhosts=* "+
-				"--nat extip:"+privateIPAddressPlaceholder+" "+
-				"--gcmode archive "+
-				"--syncmode full "+
-				"--port=%v "+
-				"--bootnodes %v",
-			getMountedPathOnNodeContainer(static_files_consts.GenesisStaticFileName),
-			ethNetworkId,
-			rpcPortNum,
-			discoveryPortNum,
-			bootnodeEnr,
-		),
+	launchConfig *nodeLaunchConfig,
+	nodeSpec *ModuleAPINodeSpec,
+	nodeFilesArtifactUuid services.FilesArtifactUUID,
+	accountFilesArtifactUuid services.FilesArtifactUUID,
+) (*services.ContainerConfig, error) {
+	serializedNodeSpec, err := json.Marshal(nodeSpec)
+	if err != nil {
+		return nil, stacktrace.Propagate(err, "An error occurred serializing the spec of node '%v'", nodeSpec.ServiceID)
+	}
+
+	initCommand, err := getGethInitCommand(launchConfig)
+	if err != nil {
+		return nil, stacktrace.Propagate(err, "An error occurred building the command that initializes node '%v'", nodeSpec.ServiceID)
+	}
+	signerImportCommand := ""
+	gethCommandBuilder := getGethCommandBuilder(launchConfig)
+	if isSignerNodeServiceId(nodeSpec.ServiceID) {
+		signerImportCommand, err = getSignerImportCommand(launchConfig, nodeSpec.ServiceID)
+		if err != nil {
+			return nil, stacktrace.Propagate(err, "An error occurred building the command that imports the account of signer '%v'", nodeSpec.ServiceID)
+		}
+		gethCommandBuilder, err = withSignerFlags(gethCommandBuilder, launchConfig, nodeSpec.ServiceID, true)
+		if err != nil {
+			return nil, stacktrace.Propagate(err, "An error occurred adding the signer flags of signer '%v'", nodeSpec.ServiceID)
+		}
+	}
+	gethCommand, err := gethCommandBuilder.build()
+	if err != nil {
+		return nil, stacktrace.Propagate(err, "An error occurred building the command that runs node '%v'", nodeSpec.ServiceID)
+	}
+	envVars := map[string]string{
+		nodeSpecEnvVar:        string(serializedNodeSpec),
+		peeringStrategyEnvVar: launchConfig.peeringStrategy,
+	}
+	if err := setHardenedRpcEnvVar(envVars, launchConfig); err != nil {
+		return nil, stacktrace.Propagate(err, "An error occurred recording the hardened RPC settings on node '%v'", nodeSpec.ServiceID)
 	}
 
 	containerConfig := services.NewContainerConfigBuilder(
-		ethereumDockerImageName,
+		launchConfig.dockerImage,
 	).WithUsedPorts(
-		usedPorts,
+		getUsedPorts(launchConfig),
 	).WithEntrypointOverride(
-		entryPointArgs,
-	).WithFiles(map[services.FilesArtifactUUID]string{
-		staticFilesArtifactUUid: staticFilesMountpointOnNodes,
-	}).WithPrivateIPAddrPlaceholder(
+		getShellEntrypointArgs(initCommand, getDnsServerResolverCommand(launchConfig), signerImportCommand, gethCommand),
+	).WithFiles(
+		getFilesArtifactMountpoints(launchConfig, nodeFilesArtifactUuid, accountFilesArtifactUuid),
+	).WithEnvironmentVariableOverrides(
+		envVars,
+	).WithCPUAllocationMillicpus(
+		nodeSpec.CpuMillicpus,
+	).WithMemoryAllocationMegabytes(
+		nodeSpec.MemoryMegabytes,
+	).WithPrivateIPAddrPlaceholder(
 		privateIPAddressPlaceholder,
 	).Build()
 
-	return containerConfig
+	return containerConfig, nil
+}
+
+func getGethInitCommand(launchConfig *nodeLaunchConfig) (string, error) {
+	return newGethCommandBuilder(
+		launchConfig.dockerImage,
+		"init",
+	).withFlag(
+		"datadir", gethDataDirpath,
+	).withArg(
+		launchConfig.genesisFilepath,
+	).build()
+}
+
+// Returns a builder for the command that runs Geth from the config of the node, with the settings that have no config field
+func getGethCommandBuilder(launchConfig *nodeLaunchConfig) *commandBuilder {
+	return newGethCommandBuilder(
+		launchConfig.dockerImage,
+	).withFlag(
+		"config", path.Join(nodeFilesMountpointOnNodes, gethConfigFilename),
+	).withFlag(
+		"nat", "extip:"+privateIPAddressPlaceholder,
+	).withFlag(
+		"nodekey", path.Join(nodeFilesMountpointOnNodes, nodeKeyFilename),
+	)
+}
+
+func getUsedPorts(launchConfig *nodeLaunchConfig) map[string]*services.PortSpec {
+	if !isAuthRpcEnabled(launchConfig) {
+		return usedPorts
+	}
+	result := map[string]*services.PortSpec{
+		authRpcPortId: services.NewPortSpec(authRpcPortNum, services.PortProtocol_TCP),
+	}
+	for portId, portSpec := range usedPorts {
+		if launchConfig.hardenedRpc != nil && (portId == rpcPortId || portId == wsPortId) {
+			continue
+		}
+		result[portId] = portSpec
+	}
+	return result
+}
+
+// The account files are only mounted on the nodes that have any
+func getFilesArtifactMountpoints(
+	launchConfig *nodeLaunchConfig,
+	nodeFilesArtifactUuid services.FilesArtifactUUID,
+	accountFilesArtifactUuid services.FilesArtifactUUID,
+) map[services.FilesArtifactUUID]string {
+	result := map[services.FilesArtifactUUID]string{
+		launchConfig.staticFiles.filesArtifactUuid: staticFilesMountpointOnNodes,
+		nodeFilesArtifactUuid:                      nodeFilesMountpointOnNodes,
+	}
+	if accountFilesArtifactUuid != "" {
+		result[accountFilesArtifactUuid] = accountFilesMountpointOnNodes
+	}
+	if isAuthRpcEnabled(launchConfig) {
+		result[launchConfig.jwtSecretArtifactUuid] = engineApiJwtSecretMountpointOnNodes
+	}
+	if launchConfig.networkFilesArtifactUuid != "" {
+		result[launchConfig.networkFilesArtifactUuid] = networkFilesMountpointOnNodes
+	}
+	return result
 }
 
 func getMountedPathOnNodeContainer(staticFilename string) string {
@@ -7,7 +7,7 @@ import (
 	"github.com/sirupsen/logrus"
 )
 
-const(
+const (
 	defaultLogLevel = "info"
 )
 
@@ -44,9 +44,9 @@ func setLogLevel(logLevelStr string) error {
 	}
 
 	logrus.SetLevel(level)
-	logrus.SetFormatter(&logrus.TextFormatter{
+	logrus.SetFormatter(newRedactingFormatter(&logrus.TextFormatter{
 		ForceColors:   true,
 		FullTimestamp: true,
-	})
+	}))
 	return nil
 }
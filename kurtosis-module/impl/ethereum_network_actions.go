@@ -0,0 +1,634 @@
+package impl
+
+import (
+	"encoding/hex"
+	static_files_consts "github.com/kurtosis-tech/ethereum-kurtosis-module/kurtosis-module/static-files-consts"
+	"github.com/kurtosis-tech/kurtosis-sdk/api/golang/core/lib/services"
+	"github.com/kurtosis-tech/stacktrace"
+	"github.com/sirupsen/logrus"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	containerStopTimeoutSeconds = 10
+
+	blockNumberRpcCall = `{"jsonrpc":"2.0","method":"eth_blockNumber","params":[],"id":83}`
+
+	maxNumResyncValidationAttempts      = 120
+	timeBetweenResyncValidationAttempts = 1 * time.Second
+)
+
+// A network that this module started earlier in the enclave
+type existingEthNetwork struct {
+	launchConfig *nodeLaunchConfig
+
+	// Every node of the network, including the bootnode, the signers and the stopped nodes
+	nodeServiceCtxs map[services.ServiceID]*services.ServiceContext
+
+	// Only set when the nodes discover the network through DNS
+	dnsServerServiceCtx *services.ServiceContext
+}
+
+func (e *EthereumKurtosisModule) executeNetworkAction(
+	enclaveCtx moduleEnclave,
+	params *ModuleAPIExecuteArgs,
+) (*ModuleAPIExecuteResult, *services.ServiceContext, error) {
+	network, err := e.loadExistingEthNetwork(enclaveCtx)
+	if err != nil {
+		return nil, nil, stacktrace.Propagate(err, "An error occurred loading the Ethereum network started earlier in the enclave")
+	}
+
+	switch params.Action {
+	case AddNodesAction:
+		err = e.addNodesToNetwork(enclaveCtx, network, params.NodeCount, params.MaxConcurrency)
+	case StopNodeAction:
+		err = e.stopNetworkNode(enclaveCtx, network, params.NodeServiceID)
+	case RestartNodeAction:
+		err = e.restartNetworkNode(enclaveCtx, network, params.NodeServiceID, params.MaxConcurrency)
+	case RemoveNodeAction:
+		err = e.removeNetworkNode(enclaveCtx, network, params.NodeServiceID)
+	default:
+		return nil, nil, stacktrace.NewError("Unrecognized action '%v'; this is a bug with this module", params.Action)
+	}
+	if err != nil {
+		return nil, nil, stacktrace.Propagate(err, "An error occurred executing action '%v'", params.Action)
+	}
+
+	// Reload the network, so the result reflects what the action did
+	updatedNetwork, err := e.loadExistingEthNetwork(enclaveCtx)
+	if err != nil {
+		return nil, nil, stacktrace.Propagate(err, "An error occurred reloading the Ethereum network after action '%v'", params.Action)
+	}
+	return e.getExistingNetworkResult(updatedNetwork)
+}
+
+func (e *EthereumKurtosisModule) loadExistingEthNetwork(enclaveCtx moduleEnclave) (*existingEthNetwork, error) {
+	serviceIds, err := enclaveCtx.GetServices()
+	if err != nil {
+		return nil, stacktrace.Propagate(err, "An error occurred getting the services in the enclave")
+	}
+	if _, found := serviceIds[bootnodeServiceID]; !found {
+		if _, found := serviceIds[devNodeServiceID]; found {
+			return nil, stacktrace.NewError("The enclave runs a node in '%v' mode, on which actions can't be executed", devMode)
+		}
+		return nil, stacktrace.NewError("No bootnode '%v' was found in the enclave; a network must be created before actions can be executed on it", bootnodeServiceID)
+	}
+
+	nodeServiceCtxs := map[services.ServiceID]*services.ServiceContext{}
+	for serviceId := range serviceIds {
+		if !isBootnodeServiceId(serviceId) && !isSignerNodeServiceId(serviceId) && !strings.HasPrefix(string(serviceId), childEthNodeServiceIdPrefix) {
+			continue
+		}
+		serviceCtx, err := enclaveCtx.GetServiceContext(serviceId)
+		if err != nil {
+			return nil, stacktrace.Propagate(err, "An error occurred getting the service context of node '%v'", serviceId)
+		}
+		nodeServiceCtxs[serviceId] = serviceCtx
+	}
+
+	// The Clefs are never stopped or restarted, so their IPs stay the same for as long as the network exists
+	clefIpAddrs := map[services.ServiceID]string{}
+	for serviceId := range serviceIds {
+		if !isClefServiceId(serviceId) {
+			continue
+		}
+		serviceCtx, err := enclaveCtx.GetServiceContext(serviceId)
+		if err != nil {
+			return nil, stacktrace.Propagate(err, "An error occurred getting the service context of Clef '%v'", serviceId)
+		}
+		clefIpAddrs[getClefSignerServiceId(serviceId)] = serviceCtx.GetPrivateIPAddress()
+	}
+
+	var dnsServerServiceCtx *services.ServiceContext
+	if _, found := serviceIds[dnsServerServiceID]; found {
+		if dnsServerServiceCtx, err = enclaveCtx.GetServiceContext(dnsServerServiceID); err != nil {
+			return nil, stacktrace.Propagate(err, "An error occurred getting the service context of the DNS discovery server")
+		}
+	}
+
+	for stoppedServiceId := range e.stoppedNodeServiceIds {
+		if _, found := nodeServiceCtxs[stoppedServiceId]; !found {
+			delete(e.stoppedNodeServiceIds, stoppedServiceId)
+		}
+	}
+
+	// The network was started by a previous instance of the module, so its launch config has to be rebuilt
+	if e.networkLaunchConfig == nil {
+		hardenedRpc, err := getHardenedRpcArgs(nodeServiceCtxs[bootnodeServiceID])
+		if err != nil {
+			return nil, stacktrace.Propagate(err, "An error occurred getting the hardened RPC settings of the network")
+		}
+		if _, found := nodeServiceCtxs[bootnodeServiceID].GetPrivatePorts()[authRpcPortId]; found && hardenedRpc == nil {
+			return nil, stacktrace.NewError(
+				"The network produces its blocks through the Engine API, but its block driver isn't running in this instance of the module",
+			)
+		}
+		peeringStrategy, err := getPeeringStrategy(nodeServiceCtxs[bootnodeServiceID])
+		if err != nil {
+			return nil, stacktrace.Propagate(err, "An error occurred getting the peering strategy of the network")
+		}
+		resourceDefaults, err := getResourceDefaults(nodeServiceCtxs[bootnodeServiceID])
+		if err != nil {
+			return nil, stacktrace.Propagate(err, "An error occurred getting the resource defaults of the network")
+		}
+		staticFiles, err := getExistingStaticFiles(enclaveCtx, nodeServiceCtxs[bootnodeServiceID])
+		if err != nil {
+			return nil, stacktrace.Propagate(err, "An error occurred getting the static files of the network")
+		}
+		keySeed, err := getKeySeed(nodeServiceCtxs[bootnodeServiceID])
+		if err != nil {
+			return nil, stacktrace.Propagate(err, "An error occurred getting the key seed of the network")
+		}
+		launchConfig := &nodeLaunchConfig{
+			dockerImage:      ethereumDockerImageName,
+			genesisFilepath:  getMountedPathOnNodeContainer(static_files_consts.GenesisStaticFileName),
+			staticFiles:      staticFiles,
+			peeringStrategy:  peeringStrategy,
+			bootnodeMode:     signerBootnodeMode,
+			nodeDiscovery:    bootnodesNodeDiscovery,
+			resourceDefaults: resourceDefaults,
+			signerBackend:    keystoreSignerBackend,
+			rpcTransport:     e.rpcTransport,
+			keySeed:          keySeed,
+		}
+		if len(clefIpAddrs) > 0 {
+			launchConfig.signerBackend = clefSignerBackend
+			launchConfig.clefIpAddrs = clefIpAddrs
+		}
+		if dnsServerServiceCtx != nil {
+			launchConfig.nodeDiscovery = dnsNodeDiscovery
+			launchConfig.dnsServerIpAddr = dnsServerServiceCtx.GetPrivateIPAddress()
+		}
+		for serviceId := range nodeServiceCtxs {
+			if isBootnodeServiceId(serviceId) {
+				launchConfig.bootnodeCount++
+			}
+		}
+		if isDiscoveryOnlyBootnode(nodeServiceCtxs[bootnodeServiceID]) {
+			for serviceId := range nodeServiceCtxs {
+				if isSignerNodeServiceId(serviceId) {
+					launchConfig.signerCount++
+				}
+			}
+			launchConfig.bootnodeMode = discoveryOnlyBootnodeMode
+		}
+		importedAccountKeys, genesisBalanceWei, err := getImportedAccounts(nodeServiceCtxs[bootnodeServiceID])
+		if err != nil {
+			return nil, stacktrace.Propagate(err, "An error occurred getting the imported accounts of the network")
+		}
+		launchConfig.importedAccountKeys = importedAccountKeys
+		launchConfig.genesisBalanceWei = genesisBalanceWei
+		if isGenesisRendered(launchConfig) {
+			// The genesis is rendered the same way from the signers and the imported accounts, so it matches the one the
+			// network was started with
+			networkFilesArtifactUuid, err := uploadNetworkFiles(enclaveCtx, launchConfig)
+			if err != nil {
+				return nil, stacktrace.Propagate(err, "An error occurred uploading the network files")
+			}
+			launchConfig.networkFilesArtifactUuid = networkFilesArtifactUuid
+			launchConfig.genesisFilepath = path.Join(networkFilesMountpointOnNodes, static_files_consts.GenesisStaticFileName)
+		}
+		if hardenedRpc != nil {
+			// The discovery-only bootnodes have no JWT secret, unlike the Geth nodes
+			gethNodeServiceId := services.ServiceID(bootnodeServiceID)
+			if launchConfig.bootnodeMode == discoveryOnlyBootnodeMode {
+				gethNodeServiceId = getSignerNodeServiceId(firstSignerNodeIdx)
+			}
+			gethNodeServiceCtx, found := nodeServiceCtxs[gethNodeServiceId]
+			if !found {
+				return nil, stacktrace.NewError("No node '%v' was found to read the JWT secret of the network from", gethNodeServiceId)
+			}
+			rpcJwtSecret, err := getRpcJwtSecret(gethNodeServiceCtx)
+			if err != nil {
+				return nil, stacktrace.Propagate(err, "An error occurred getting the JWT secret of the network")
+			}
+			// The secret is uploaded again, so the nodes started from now on get the same one
+			jwtSecretArtifactUuid, err := uploadJwtSecret(enclaveCtx, rpcJwtSecret)
+			if err != nil {
+				return nil, stacktrace.Propagate(err, "An error occurred uploading the JWT secret of the network")
+			}
+			launchConfig.dockerImage = authRpcEthereumDockerImageName
+			launchConfig.hardenedRpc = hardenedRpc
+			launchConfig.rpcJwtSecret = rpcJwtSecret
+			launchConfig.jwtSecretArtifactUuid = jwtSecretArtifactUuid
+		}
+		e.networkLaunchConfig = launchConfig
+	}
+
+	return &existingEthNetwork{
+		launchConfig:        e.networkLaunchConfig,
+		nodeServiceCtxs:     nodeServiceCtxs,
+		dnsServerServiceCtx: dnsServerServiceCtx,
+	}, nil
+}
+
+func (e *EthereumKurtosisModule) addNodesToNetwork(
+	enclaveCtx moduleEnclave,
+	network *existingEthNetwork,
+	numNodesToAdd uint32,
+	maxConcurrency uint32,
+) error {
+	bootnodeEnrs, err := e.getBootnodeEnrsForNewNodes(network)
+	if err != nil {
+		return stacktrace.Propagate(err, "New nodes can't discover the network")
+	}
+
+	highestChildIdx := 0
+	for serviceId := range network.nodeServiceCtxs {
+		childIdx, err := strconv.Atoi(strings.TrimPrefix(string(serviceId), childEthNodeServiceIdPrefix))
+		if err == nil && childIdx > highestChildIdx {
+			highestChildIdx = childIdx
+		}
+	}
+	newServiceIds := []services.ServiceID{}
+	newNodeSpecs := []*ModuleAPINodeSpec{}
+	for i := 1; i <= int(numNodesToAdd); i++ {
+		newNodeSpec := &ModuleAPINodeSpec{ServiceID: getChildEthNodeServiceId(highestChildIdx + i)}
+		if err := applyDefaultsAndValidateNodeSpec(newNodeSpec); err != nil {
+			return stacktrace.Propagate(err, "The default spec of new node '%v' was invalid; this is a bug with this module", newNodeSpec.ServiceID)
+		}
+		applyResourceDefaults(newNodeSpec, network.launchConfig.resourceDefaults)
+		newServiceIds = append(newServiceIds, newNodeSpec.ServiceID)
+		newNodeSpecs = append(newNodeSpecs, newNodeSpec)
+	}
+
+	runningNodeServiceCtxs := e.getRunningGethNodeServiceCtxs(network)
+	newNodeInfo, newNodeServiceCtxs, err := addEthChildNodes(enclaveCtx, network.launchConfig, bootnodeEnrs, runningNodeServiceCtxs, newNodeSpecs, maxConcurrency)
+	if err != nil {
+		return stacktrace.Propagate(err, "An error occurred adding child nodes '%+v'", newServiceIds)
+	}
+	e.recordNodeFilesArtifactUuids(newNodeInfo)
+	if err := connectNewPeers(newNodeServiceCtxs, runningNodeServiceCtxs, network.launchConfig, maxConcurrency); err != nil {
+		return stacktrace.Propagate(err, "An error occurred connecting the new nodes to the network")
+	}
+
+	allNodeServiceCtxs := map[services.ServiceID]*services.ServiceContext{}
+	for serviceId, serviceCtx := range runningNodeServiceCtxs {
+		allNodeServiceCtxs[serviceId] = serviceCtx
+	}
+	for serviceId, serviceCtx := range newNodeServiceCtxs {
+		allNodeServiceCtxs[serviceId] = serviceCtx
+		if e.engineApiBlockDriver != nil {
+			e.engineApiBlockDriver.setNode(serviceId, serviceCtx.GetPrivateIPAddress())
+		}
+	}
+	if err := verifyFullyPeered(allNodeServiceCtxs, network.launchConfig, maxConcurrency, 0); err != nil {
+		return stacktrace.Propagate(err, "An error occurred verifying that the new nodes are peered with the network")
+	}
+
+	logrus.Infof("Added nodes '%+v' to the network", newServiceIds)
+	return nil
+}
+
+func (e *EthereumKurtosisModule) stopNetworkNode(
+	enclaveCtx moduleEnclave,
+	network *existingEthNetwork,
+	serviceId services.ServiceID,
+) error {
+	if _, found := network.nodeServiceCtxs[serviceId]; !found {
+		return stacktrace.NewError("No node '%v' was found in the network", serviceId)
+	}
+	if e.stoppedNodeServiceIds[serviceId] {
+		return stacktrace.NewError("Node '%v' is already stopped", serviceId)
+	}
+	if serviceId == bootnodeServiceID && e.engineApiBlockDriver != nil {
+		return stacktrace.NewError("Bootnode '%v' produces the blocks of the Engine API block driver, so it can't be stopped", serviceId)
+	}
+
+	if err := enclaveCtx.PauseService(serviceId); err != nil {
+		return stacktrace.Propagate(err, "An error occurred pausing node '%v'", serviceId)
+	}
+	e.stoppedNodeServiceIds[serviceId] = true
+	if e.engineApiBlockDriver != nil {
+		e.engineApiBlockDriver.removeNode(serviceId)
+	}
+
+	logrus.Infof("Stopped node '%v'", serviceId)
+	return nil
+}
+
+func (e *EthereumKurtosisModule) restartNetworkNode(
+	enclaveCtx moduleEnclave,
+	network *existingEthNetwork,
+	serviceId services.ServiceID,
+	maxConcurrency uint32,
+) error {
+	serviceCtx, found := network.nodeServiceCtxs[serviceId]
+	if !found {
+		return stacktrace.NewError("No node '%v' was found in the network", serviceId)
+	}
+	otherRunningNodeServiceCtxs := e.getRunningGethNodeServiceCtxs(network)
+	delete(otherRunningNodeServiceCtxs, serviceId)
+
+	bootnodeEnrs := []string{}
+	var nodeSpec *ModuleAPINodeSpec
+	if !isBootnodeServiceId(serviceId) {
+		enrs, err := e.getBootnodeEnrsForNewNodes(network)
+		if err != nil {
+			return stacktrace.Propagate(err, "Node '%v' can't discover the network once restarted", serviceId)
+		}
+		bootnodeEnrs = enrs
+
+		// The restarted node keeps the spec it was started with
+		if e.stoppedNodeServiceIds[serviceId] {
+			if err := enclaveCtx.UnpauseService(serviceId); err != nil {
+				return stacktrace.Propagate(err, "An error occurred unpausing stopped node '%v' to read its spec", serviceId)
+			}
+			delete(e.stoppedNodeServiceIds, serviceId)
+		}
+		spec, err := getNodeSpec(serviceId, serviceCtx)
+		if err != nil {
+			return stacktrace.Propagate(err, "An error occurred getting the spec of node '%v'", serviceId)
+		}
+		nodeSpec = spec
+	}
+
+	// The restarted node has to catch up with at least the chain that the rest of the network has now
+	targetBlockNumber := uint64(0)
+	for otherServiceId, otherServiceCtx := range otherRunningNodeServiceCtxs {
+		blockNumber, err := getBlockNumber(network.launchConfig.rpcTransport, otherServiceCtx, network.launchConfig.rpcJwtSecret)
+		if err != nil {
+			return stacktrace.Propagate(err, "An error occurred getting the block number of node '%v'", otherServiceId)
+		}
+		if blockNumber > targetBlockNumber {
+			targetBlockNumber = blockNumber
+		}
+	}
+
+	// A paused container can't be stopped, so a stopped node gets unpaused before being replaced
+	if e.stoppedNodeServiceIds[serviceId] {
+		if err := enclaveCtx.UnpauseService(serviceId); err != nil {
+			return stacktrace.Propagate(err, "An error occurred unpausing stopped node '%v' before restarting it", serviceId)
+		}
+	}
+	if err := enclaveCtx.RemoveService(serviceId, containerStopTimeoutSeconds); err != nil {
+		return stacktrace.Propagate(err, "An error occurred removing node '%v' before restarting it", serviceId)
+	}
+	delete(e.stoppedNodeServiceIds, serviceId)
+	delete(e.nodeFilesArtifactUuids, serviceId)
+
+	var restartedServiceCtx *services.ServiceContext
+	if isBootnodeServiceId(serviceId) {
+		peerEnodes, err := getSortedEnodeAddresses(network.launchConfig.keySeed, otherRunningNodeServiceCtxs)
+		if err != nil {
+			return stacktrace.Propagate(err, "An error occurred getting the enode addresses of the nodes that the bootnode gets peered with")
+		}
+		serviceCtx, nodeInfo, err := startEthBootnode(enclaveCtx, network.launchConfig, serviceId, peerEnodes)
+		if err != nil {
+			return stacktrace.Propagate(err, "An error occurred starting bootnode '%v' again", serviceId)
+		}
+		if nodeInfo != nil {
+			e.recordNodeFilesArtifactUuids(map[services.ServiceID]*ModuleAPIEthereumNodeInfo{serviceId: nodeInfo})
+		}
+		// A discovery-only bootnode has no chain to resync, and the Geth nodes are peered without it
+		if network.launchConfig.bootnodeMode == discoveryOnlyBootnodeMode {
+			logrus.Infof("Restarted discovery-only bootnode '%v'", serviceId)
+			return nil
+		}
+		if err := waitForNodeAvailability(enclaveCtx, network.launchConfig.rpcTransport, serviceId, network.launchConfig.rpcJwtSecret); err != nil {
+			return stacktrace.Propagate(err, "An error occurred waiting for restarted bootnode '%v' to become available", serviceId)
+		}
+		restartedServiceCtx = serviceCtx
+	} else {
+		childNodeInfo, childServiceCtxs, err := addEthChildNodes(enclaveCtx, network.launchConfig, bootnodeEnrs, otherRunningNodeServiceCtxs, []*ModuleAPINodeSpec{nodeSpec}, maxConcurrency)
+		if err != nil {
+			return stacktrace.Propagate(err, "An error occurred starting child node '%v' again", serviceId)
+		}
+		e.recordNodeFilesArtifactUuids(childNodeInfo)
+		restartedServiceCtx = childServiceCtxs[serviceId]
+	}
+	restartedNodeServiceCtxs := map[services.ServiceID]*services.ServiceContext{
+		serviceId: restartedServiceCtx,
+	}
+
+	if err := connectNewPeers(restartedNodeServiceCtxs, otherRunningNodeServiceCtxs, network.launchConfig, maxConcurrency); err != nil {
+		return stacktrace.Propagate(err, "An error occurred connecting restarted node '%v' to the network", serviceId)
+	}
+	allNodeServiceCtxs := map[services.ServiceID]*services.ServiceContext{
+		serviceId: restartedServiceCtx,
+	}
+	for otherServiceId, otherServiceCtx := range otherRunningNodeServiceCtxs {
+		allNodeServiceCtxs[otherServiceId] = otherServiceCtx
+	}
+	// The other nodes can still be peered with the stopped nodes that they haven't dropped yet
+	if err := verifyFullyPeered(allNodeServiceCtxs, network.launchConfig, maxConcurrency, len(e.stoppedNodeServiceIds)); err != nil {
+		return stacktrace.Propagate(err, "An error occurred verifying that restarted node '%v' is peered with the network", serviceId)
+	}
+
+	if e.engineApiBlockDriver != nil {
+		e.engineApiBlockDriver.setNode(serviceId, restartedServiceCtx.GetPrivateIPAddress())
+	}
+	if err := waitForNodeToReachBlock(serviceId, restartedServiceCtx, network.launchConfig.rpcTransport, network.launchConfig.rpcJwtSecret, targetBlockNumber); err != nil {
+		return stacktrace.Propagate(err, "Restarted node '%v' didn't resync with the network", serviceId)
+	}
+
+	logrus.Infof("Restarted node '%v', which resynced up to block %v", serviceId, targetBlockNumber)
+	return nil
+}
+
+func (e *EthereumKurtosisModule) removeNetworkNode(
+	enclaveCtx moduleEnclave,
+	network *existingEthNetwork,
+	serviceId services.ServiceID,
+) error {
+	if isBootnodeServiceId(serviceId) {
+		return stacktrace.NewError("Bootnode '%v' is given to every node to discover the network, so it can't be removed", serviceId)
+	}
+	// The signers are listed in the genesis, so without one of them the others would lose their turns to seal blocks
+	if isSignerNodeServiceId(serviceId) {
+		return stacktrace.NewError("Signer '%v' is one of the signers in the genesis, so it can't be removed, only stopped or restarted", serviceId)
+	}
+	if _, found := network.nodeServiceCtxs[serviceId]; !found {
+		return stacktrace.NewError("No node '%v' was found in the network", serviceId)
+	}
+
+	if e.stoppedNodeServiceIds[serviceId] {
+		if err := enclaveCtx.UnpauseService(serviceId); err != nil {
+			return stacktrace.Propagate(err, "An error occurred unpausing stopped node '%v' before removing it", serviceId)
+		}
+	}
+	if err := enclaveCtx.RemoveService(serviceId, containerStopTimeoutSeconds); err != nil {
+		return stacktrace.Propagate(err, "An error occurred removing node '%v'", serviceId)
+	}
+	delete(e.stoppedNodeServiceIds, serviceId)
+	delete(e.nodeFilesArtifactUuids, serviceId)
+	if e.engineApiBlockDriver != nil {
+		e.engineApiBlockDriver.removeNode(serviceId)
+	}
+
+	logrus.Infof("Removed node '%v'", serviceId)
+	return nil
+}
+
+// Returns the result for the network, along with the service context of a running node from which the static files can be read
+func (e *EthereumKurtosisModule) getExistingNetworkResult(network *existingEthNetwork) (*ModuleAPIExecuteResult, *services.ServiceContext, error) {
+	allNodeInfo := map[services.ServiceID]*ModuleAPIEthereumNodeInfo{}
+	for serviceId, serviceCtx := range network.nodeServiceCtxs {
+		if isDiscoveryOnlyBootnode(serviceCtx) {
+			continue
+		}
+		nodeInfo, err := getApiNodeObjFromNodeServiceCtx(serviceCtx, network.launchConfig)
+		if err != nil {
+			return nil, nil, stacktrace.Propagate(err, "An error occurred getting the node info API object from the service context of node '%v'", serviceId)
+		}
+		nodeInfo.IsStopped = e.stoppedNodeServiceIds[serviceId]
+		nodeInfo.NodeFilesArtifactUUID = e.nodeFilesArtifactUuids[serviceId]
+		allNodeInfo[serviceId] = nodeInfo
+	}
+
+	bootnodeServiceIds := getBootnodeServiceIds(network.launchConfig)
+	allBootnodeInfo := map[services.ServiceID]*ModuleAPIBootnodeInfo{}
+	for _, serviceId := range bootnodeServiceIds {
+		bootnodeInfo, err := getApiBootnodeObjFromServiceCtx(network.nodeServiceCtxs[serviceId], network.launchConfig)
+		if err != nil {
+			return nil, nil, stacktrace.Propagate(err, "An error occurred getting the info API object of bootnode '%v'", serviceId)
+		}
+		bootnodeInfo.IsStopped = e.stoppedNodeServiceIds[serviceId]
+		allBootnodeInfo[serviceId] = bootnodeInfo
+	}
+	signerServiceIds := getSignerServiceIds(network.launchConfig)
+	clefSignerInfo, err := getClefSignerInfo(network.launchConfig)
+	if err != nil {
+		return nil, nil, stacktrace.Propagate(err, "An error occurred getting the Clef signer info API objects")
+	}
+
+	resultObj := &ModuleAPIExecuteResult{
+		BootnodeServiceIDs: bootnodeServiceIds,
+		NodeInfo:           allNodeInfo,
+		BlockProduction:    cliqueBlockProduction,
+		PeeringStrategy:    network.launchConfig.peeringStrategy,
+		BootnodeMode:       network.launchConfig.bootnodeMode,
+		BootnodeInfo:       allBootnodeInfo,
+		SignerServiceIDs:   signerServiceIds,
+		NodeDiscovery:      network.launchConfig.nodeDiscovery,
+		RpcProfile:         getRpcProfile(network.launchConfig),
+		SignerBackend:      network.launchConfig.signerBackend,
+		ClefSigners:        clefSignerInfo,
+	}
+	if network.launchConfig.rpcJwtSecret != nil {
+		resultObj.RpcJwtSecret = hex.EncodeToString(network.launchConfig.rpcJwtSecret)
+	}
+	if network.dnsServerServiceCtx != nil {
+		dnsDiscoveryInfo, err := getDnsDiscoveryInfo(network.launchConfig.keySeed, network.dnsServerServiceCtx)
+		if err != nil {
+			return nil, nil, stacktrace.Propagate(err, "An error occurred getting the DNS discovery info API object")
+		}
+		resultObj.DnsDiscovery = dnsDiscoveryInfo
+	}
+	if e.engineApiBlockDriver != nil {
+		resultObj.BlockProduction = engineApiDriverBlockProduction
+		resultObj.EngineApiJwtSecret = hex.EncodeToString(e.engineApiBlockDriver.jwtSecret)
+	}
+
+	runningNodeServiceCtxs := e.getRunningGethNodeServiceCtxs(network)
+	for _, signerServiceId := range signerServiceIds {
+		if signerServiceCtx, found := runningNodeServiceCtxs[signerServiceId]; found {
+			return resultObj, signerServiceCtx, nil
+		}
+	}
+	for _, serviceCtx := range runningNodeServiceCtxs {
+		return resultObj, serviceCtx, nil
+	}
+	return nil, nil, stacktrace.NewError("Every node of the network is stopped, so there's no node to read the static files from")
+}
+
+// Remembers the files artifacts of the given nodes, so they can be reported after the nodes were started
+func (e *EthereumKurtosisModule) recordNodeFilesArtifactUuids(nodeInfo map[services.ServiceID]*ModuleAPIEthereumNodeInfo) {
+	for serviceId, info := range nodeInfo {
+		e.nodeFilesArtifactUuids[serviceId] = info.NodeFilesArtifactUUID
+	}
+}
+
+// Reads the peering strategy that the given node was started with, falling back to the default for nodes started before
+// peering strategies existed
+func getPeeringStrategy(serviceCtx *services.ServiceContext) (string, error) {
+	exitCode, peeringStrategy, err := serviceCtx.ExecCommand([]string{"printenv", peeringStrategyEnvVar})
+	if err != nil {
+		return "", stacktrace.Propagate(err, "An error occurred reading the peering strategy of node '%v'", serviceCtx.GetServiceID())
+	}
+	if exitCode != execCommandSuccessExitCode {
+		return adminAddPeerPeeringStrategy, nil
+	}
+	return strings.TrimSpace(peeringStrategy), nil
+}
+
+func (e *EthereumKurtosisModule) getRunningNodeServiceCtxs(network *existingEthNetwork) map[services.ServiceID]*services.ServiceContext {
+	result := map[services.ServiceID]*services.ServiceContext{}
+	for serviceId, serviceCtx := range network.nodeServiceCtxs {
+		if !e.stoppedNodeServiceIds[serviceId] {
+			result[serviceId] = serviceCtx
+		}
+	}
+	return result
+}
+
+// Returns the running nodes that are Geth nodes, leaving out a discovery-only bootnode, which can't be peered with or queried
+func (e *EthereumKurtosisModule) getRunningGethNodeServiceCtxs(network *existingEthNetwork) map[services.ServiceID]*services.ServiceContext {
+	result := e.getRunningNodeServiceCtxs(network)
+	if network.launchConfig.bootnodeMode == discoveryOnlyBootnodeMode {
+		for _, serviceId := range getBootnodeServiceIds(network.launchConfig) {
+			delete(result, serviceId)
+		}
+	}
+	return result
+}
+
+// Returns the ENRs that new nodes discover the network through, as long as at least one of the bootnodes is running
+func (e *EthereumKurtosisModule) getBootnodeEnrsForNewNodes(network *existingEthNetwork) ([]string, error) {
+	runningNodeServiceCtxs := e.getRunningNodeServiceCtxs(network)
+	bootnodeServiceIds := getBootnodeServiceIds(network.launchConfig)
+	isBootnodeRunning := false
+	for _, serviceId := range bootnodeServiceIds {
+		if _, found := runningNodeServiceCtxs[serviceId]; found {
+			isBootnodeRunning = true
+		}
+	}
+	if !isBootnodeRunning {
+		return nil, stacktrace.NewError("Every bootnode of %v is stopped, so one must be restarted first", bootnodeServiceIds)
+	}
+	bootnodeEnrs, err := getBootnodeEnrs(network.launchConfig, network.nodeServiceCtxs)
+	if err != nil {
+		return nil, stacktrace.Propagate(err, "An error occurred getting the ENRs of the bootnodes")
+	}
+	return bootnodeEnrs, nil
+}
+
+func waitForNodeToReachBlock(serviceId services.ServiceID, serviceCtx *services.ServiceContext, transport rpcTransport, rpcJwtSecret []byte, targetBlockNumber uint64) error {
+	blockNumber := uint64(0)
+	for i := 0; i < maxNumResyncValidationAttempts; i++ {
+		currentBlockNumber, err := getBlockNumber(transport, serviceCtx, rpcJwtSecret)
+		if err == nil && currentBlockNumber >= targetBlockNumber {
+			return nil
+		}
+		if err != nil {
+			logrus.Debugf("Getting the block number of node '%v' failed with error:\n%v", serviceId, err)
+		} else {
+			blockNumber = currentBlockNumber
+		}
+		time.Sleep(timeBetweenResyncValidationAttempts)
+	}
+	return stacktrace.NewError(
+		"Node '%v' only reached block %v rather than %v, even after %v attempts with %v between attempts",
+		serviceId,
+		blockNumber,
+		targetBlockNumber,
+		maxNumResyncValidationAttempts,
+		timeBetweenResyncValidationAttempts,
+	)
+}
+
+func getBlockNumber(transport rpcTransport, serviceCtx *services.ServiceContext, rpcJwtSecret []byte) (uint64, error) {
+	privateIpAddr := serviceCtx.GetPrivateIPAddress()
+	blockNumberResponse := new(EthAPIBlockNumberResponse)
+	if err := sendNodeRpcCall(transport, serviceCtx, rpcJwtSecret, blockNumberRpcCall, blockNumberResponse); err != nil {
+		return 0, stacktrace.Propagate(err, "Failed to send block number RPC request to Geth node with ip %v", privateIpAddr)
+	}
+	blockNumber, err := parseHexUint64(blockNumberResponse.Result)
+	if err != nil {
+		return 0, stacktrace.Propagate(err, "An error occurred parsing the block number returned by Geth node with ip %v", privateIpAddr)
+	}
+	return blockNumber, nil
+}
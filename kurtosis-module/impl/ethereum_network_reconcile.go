@@ -0,0 +1,357 @@
+package impl
+
+import (
+	"crypto/ecdsa"
+	"github.com/kurtosis-tech/kurtosis-sdk/api/golang/core/lib/services"
+	"github.com/kurtosis-tech/stacktrace"
+	"github.com/sirupsen/logrus"
+	"strings"
+	"time"
+)
+
+// Makes the enclave match the requested network, creating it if it doesn't exist yet and otherwise only changing the child
+// nodes that differ from the requested ones, and restarting the stopped bootnodes and signers
+func (e *EthereumKurtosisModule) reconcileEthNetwork(
+	enclaveCtx moduleEnclave,
+	params *ModuleAPIExecuteArgs,
+) (*ModuleAPIExecuteResult, *services.ServiceContext, error) {
+	serviceIds, err := enclaveCtx.GetServices()
+	if err != nil {
+		return nil, nil, stacktrace.Propagate(err, "An error occurred getting the services in the enclave")
+	}
+	if _, found := serviceIds[bootnodeServiceID]; !found {
+		staticFiles, err := loadStaticFiles(enclaveCtx, params.StaticFilesArtifactUUID)
+		if err != nil {
+			return nil, nil, stacktrace.Propagate(err, "An error occurred loading the static files")
+		}
+		setDefaultFeeRecipient(params, staticFiles)
+		return e.createReconciledEthNetwork(enclaveCtx, staticFiles, params, serviceIds)
+	}
+
+	network, err := e.loadExistingEthNetwork(enclaveCtx)
+	if err != nil {
+		return nil, nil, stacktrace.Propagate(err, "An error occurred loading the Ethereum network started earlier in the enclave")
+	}
+	setDefaultFeeRecipient(params, network.launchConfig.staticFiles)
+	if err := e.verifyBlockProductionMatches(params); err != nil {
+		return nil, nil, stacktrace.Propagate(err, "The block production of the existing network can't be reconciled in place")
+	}
+	// Nodes peered by another strategy would have been started differently, so the strategy can't change in place either
+	if params.PeeringStrategy != network.launchConfig.peeringStrategy {
+		return nil, nil, stacktrace.NewError(
+			"The existing network uses peering strategy '%v', but '%v' was requested",
+			network.launchConfig.peeringStrategy,
+			params.PeeringStrategy,
+		)
+	}
+	// The signers are listed in the genesis, so they can't change without recreating the network either
+	if params.BootnodeMode != network.launchConfig.bootnodeMode || params.SignerCount != network.launchConfig.signerCount {
+		return nil, nil, stacktrace.NewError(
+			"The existing network uses bootnode mode '%v' with %v separate signers, but bootnode mode '%v' with %v separate signers was requested",
+			network.launchConfig.bootnodeMode,
+			network.launchConfig.signerCount,
+			params.BootnodeMode,
+			params.SignerCount,
+		)
+	}
+	// Every node was given the ENRs of all the bootnodes, so they can't change without recreating the nodes either
+	if params.BootnodeCount != network.launchConfig.bootnodeCount {
+		return nil, nil, stacktrace.NewError(
+			"The existing network has %v bootnodes, but %v were requested",
+			network.launchConfig.bootnodeCount,
+			params.BootnodeCount,
+		)
+	}
+
+	// The static files are mounted on every node and hold the genesis, so they can't change without recreating the network either
+	existingStaticFilesArtifactUuid := services.FilesArtifactUUID("")
+	if network.launchConfig.staticFiles.isCallerSupplied {
+		existingStaticFilesArtifactUuid = network.launchConfig.staticFiles.filesArtifactUuid
+	}
+	if params.StaticFilesArtifactUUID != existingStaticFilesArtifactUuid {
+		return nil, nil, stacktrace.NewError(
+			"The existing network uses the static files of files artifact '%v', but files artifact '%v' was requested (an empty one means the static files built into the module)",
+			existingStaticFilesArtifactUuid,
+			params.StaticFilesArtifactUUID,
+		)
+	}
+
+	// The nodes were started with or without the tree URL, so the node discovery can't change in place either
+	if params.NodeDiscovery != network.launchConfig.nodeDiscovery {
+		return nil, nil, stacktrace.NewError(
+			"The existing network uses node discovery '%v', but '%v' was requested",
+			network.launchConfig.nodeDiscovery,
+			params.NodeDiscovery,
+		)
+	}
+
+	// The RPC settings are in the config of every node, and the JWT secret is mounted on every node
+	if !isHardenedRpcMatching(network.launchConfig, params) {
+		return nil, nil, stacktrace.NewError(
+			"The existing network uses RPC profile '%v' with hardened RPC settings %+v, but RPC profile '%v' with hardened RPC settings %+v was requested",
+			getRpcProfile(network.launchConfig),
+			network.launchConfig.hardenedRpc,
+			params.RpcProfile,
+			params.HardenedRpc,
+		)
+	}
+
+	// The signers were started with or without their Clef, which holds their account
+	if params.SignerBackend != network.launchConfig.signerBackend {
+		return nil, nil, stacktrace.NewError(
+			"The existing network uses signer backend '%v', but '%v' was requested",
+			network.launchConfig.signerBackend,
+			params.SignerBackend,
+		)
+	}
+
+	// The node keys derive from the key seed, and so do the accounts of the extra signers, which are in the genesis
+	if params.KeySeed != network.launchConfig.keySeed {
+		return nil, nil, stacktrace.NewError("The existing network was started with a different key seed than the requested one")
+	}
+
+	// The imported accounts are in the genesis, which the existing nodes were initialized with
+	importedAccountKeys := []*ecdsa.PrivateKey{}
+	genesisBalanceWei := ""
+	if params.ImportedAccounts != nil {
+		importedAccountKeys, err = getImportedAccountKeys(enclaveCtx, params.ImportedAccounts, len(getSignerServiceIds(network.launchConfig)))
+		if err != nil {
+			return nil, nil, stacktrace.Propagate(err, "An error occurred getting the keys of the imported accounts")
+		}
+		genesisBalanceWei = params.ImportedAccounts.GenesisBalanceWei
+	}
+	if !isImportedAccountsMatching(network.launchConfig, importedAccountKeys, genesisBalanceWei) {
+		return nil, nil, stacktrace.NewError(
+			"The existing network has %v imported accounts funded with %v wei, which differ from the %v requested accounts funded with %v wei",
+			len(network.launchConfig.importedAccountKeys),
+			network.launchConfig.genesisBalanceWei,
+			len(importedAccountKeys),
+			genesisBalanceWei,
+		)
+	}
+
+	// The other nodes get their resource defaults through their specs, which are compared below
+	existingResourceDefaults := network.launchConfig.resourceDefaults
+	if *params.ResourceDefaults.Bootnode != *existingResourceDefaults.Bootnode || *params.ResourceDefaults.Signer != *existingResourceDefaults.Signer {
+		return nil, nil, stacktrace.NewError(
+			"The existing network has bootnode and signer resource defaults %+v and %+v, but %+v and %+v were requested",
+			*existingResourceDefaults.Bootnode,
+			*existingResourceDefaults.Signer,
+			*params.ResourceDefaults.Bootnode,
+			*params.ResourceDefaults.Signer,
+		)
+	}
+
+	diff := &ModuleAPIReconcileDiff{
+		AddedServiceIDs:     []services.ServiceID{},
+		RemovedServiceIDs:   []services.ServiceID{},
+		RecreatedServiceIDs: []services.ServiceID{},
+		UnchangedServiceIDs: []services.ServiceID{},
+	}
+
+	// Children need the bootnodes to discover the network, so the stopped bootnodes get restarted first, followed by the
+	// stopped signers, which the network needs to produce blocks
+	fixedServiceIds := getBootnodeServiceIds(network.launchConfig)
+	for _, signerServiceId := range getSignerServiceIds(network.launchConfig) {
+		if signerServiceId != bootnodeServiceID {
+			fixedServiceIds = append(fixedServiceIds, signerServiceId)
+		}
+	}
+	for _, serviceId := range fixedServiceIds {
+		if !e.stoppedNodeServiceIds[serviceId] {
+			diff.UnchangedServiceIDs = append(diff.UnchangedServiceIDs, serviceId)
+			continue
+		}
+		if err := e.restartNetworkNode(enclaveCtx, network, serviceId, params.MaxConcurrency); err != nil {
+			return nil, nil, stacktrace.Propagate(err, "An error occurred restarting stopped node '%v'", serviceId)
+		}
+		diff.RecreatedServiceIDs = append(diff.RecreatedServiceIDs, serviceId)
+		if network, err = e.loadExistingEthNetwork(enclaveCtx); err != nil {
+			return nil, nil, stacktrace.Propagate(err, "An error occurred reloading the Ethereum network after restarting node '%v'", serviceId)
+		}
+	}
+
+	desiredNodeSpecs := map[services.ServiceID]*ModuleAPINodeSpec{}
+	for _, nodeSpec := range params.Nodes {
+		desiredNodeSpecs[nodeSpec.ServiceID] = nodeSpec
+	}
+
+	nodeSpecsToStart := []*ModuleAPINodeSpec{}
+	serviceIdsToRemove := []services.ServiceID{}
+	for serviceId, serviceCtx := range network.nodeServiceCtxs {
+		if isBootnodeServiceId(serviceId) || isSignerNodeServiceId(serviceId) {
+			continue
+		}
+		desiredNodeSpec, found := desiredNodeSpecs[serviceId]
+		if !found {
+			serviceIdsToRemove = append(serviceIdsToRemove, serviceId)
+			diff.RemovedServiceIDs = append(diff.RemovedServiceIDs, serviceId)
+			continue
+		}
+
+		// A stopped node can't be asked for its spec, and has to be replaced to run again anyway
+		isRecreationNeeded := e.stoppedNodeServiceIds[serviceId]
+		if !isRecreationNeeded {
+			currentNodeSpec, err := getNodeSpec(serviceId, serviceCtx)
+			if err != nil {
+				return nil, nil, stacktrace.Propagate(err, "An error occurred getting the spec of node '%v'", serviceId)
+			}
+			isRecreationNeeded = *currentNodeSpec != *desiredNodeSpec
+		}
+		if isRecreationNeeded {
+			serviceIdsToRemove = append(serviceIdsToRemove, serviceId)
+			nodeSpecsToStart = append(nodeSpecsToStart, desiredNodeSpec)
+			diff.RecreatedServiceIDs = append(diff.RecreatedServiceIDs, serviceId)
+		} else {
+			diff.UnchangedServiceIDs = append(diff.UnchangedServiceIDs, serviceId)
+		}
+	}
+	for _, nodeSpec := range params.Nodes {
+		if _, found := network.nodeServiceCtxs[nodeSpec.ServiceID]; !found {
+			nodeSpecsToStart = append(nodeSpecsToStart, nodeSpec)
+			diff.AddedServiceIDs = append(diff.AddedServiceIDs, nodeSpec.ServiceID)
+		}
+	}
+
+	for _, serviceId := range serviceIdsToRemove {
+		if e.stoppedNodeServiceIds[serviceId] {
+			if err := enclaveCtx.UnpauseService(serviceId); err != nil {
+				return nil, nil, stacktrace.Propagate(err, "An error occurred unpausing stopped node '%v' before removing it", serviceId)
+			}
+		}
+		if err := enclaveCtx.RemoveService(serviceId, containerStopTimeoutSeconds); err != nil {
+			return nil, nil, stacktrace.Propagate(err, "An error occurred removing node '%v'", serviceId)
+		}
+		delete(e.stoppedNodeServiceIds, serviceId)
+		delete(e.nodeFilesArtifactUuids, serviceId)
+		delete(network.nodeServiceCtxs, serviceId)
+		if e.engineApiBlockDriver != nil {
+			e.engineApiBlockDriver.removeNode(serviceId)
+		}
+	}
+
+	runningNodeServiceCtxs := e.getRunningGethNodeServiceCtxs(network)
+	allNodeServiceCtxs := map[services.ServiceID]*services.ServiceContext{}
+	for serviceId, serviceCtx := range runningNodeServiceCtxs {
+		allNodeServiceCtxs[serviceId] = serviceCtx
+	}
+	if len(nodeSpecsToStart) > 0 {
+		bootnodeEnrs, err := getBootnodeEnrs(network.launchConfig, network.nodeServiceCtxs)
+		if err != nil {
+			return nil, nil, stacktrace.Propagate(err, "An error occurred getting the ENRs of the bootnodes")
+		}
+		newNodeInfo, newNodeServiceCtxs, err := addEthChildNodes(enclaveCtx, network.launchConfig, bootnodeEnrs, runningNodeServiceCtxs, nodeSpecsToStart, params.MaxConcurrency)
+		if err != nil {
+			return nil, nil, stacktrace.Propagate(err, "An error occurred starting the added and recreated nodes")
+		}
+		e.recordNodeFilesArtifactUuids(newNodeInfo)
+		if err := connectNewPeers(newNodeServiceCtxs, runningNodeServiceCtxs, network.launchConfig, params.MaxConcurrency); err != nil {
+			return nil, nil, stacktrace.Propagate(err, "An error occurred connecting the added and recreated nodes to the network")
+		}
+		for serviceId, serviceCtx := range newNodeServiceCtxs {
+			allNodeServiceCtxs[serviceId] = serviceCtx
+			if e.engineApiBlockDriver != nil {
+				e.engineApiBlockDriver.setNode(serviceId, serviceCtx.GetPrivateIPAddress())
+			}
+		}
+	}
+
+	// The peering is verified even when nothing changed, so that a successful reconcile always means a healthy network
+	if err := verifyFullyPeered(allNodeServiceCtxs, network.launchConfig, params.MaxConcurrency, 0); err != nil {
+		return nil, nil, stacktrace.Propagate(err, "An error occurred verifying that the reconciled nodes are peered with each other")
+	}
+
+	updatedNetwork, err := e.loadExistingEthNetwork(enclaveCtx)
+	if err != nil {
+		return nil, nil, stacktrace.Propagate(err, "An error occurred reloading the Ethereum network after reconciling it")
+	}
+	resultObj, signerServiceCtx, err := e.getExistingNetworkResult(updatedNetwork)
+	if err != nil {
+		return nil, nil, stacktrace.Propagate(err, "An error occurred getting the result for the reconciled network")
+	}
+	sortReconcileDiff(diff)
+	resultObj.ReconcileDiff = diff
+
+	logrus.Infof("Reconciled the network with diff: %+v", diff)
+	return resultObj, signerServiceCtx, nil
+}
+
+func (e *EthereumKurtosisModule) createReconciledEthNetwork(
+	enclaveCtx moduleEnclave,
+	staticFiles *staticFiles,
+	params *ModuleAPIExecuteArgs,
+	serviceIds map[services.ServiceID]bool,
+) (*ModuleAPIExecuteResult, *services.ServiceContext, error) {
+	for serviceId := range serviceIds {
+		if serviceId == devNodeServiceID || serviceId == dnsServerServiceID || isClefServiceId(serviceId) || isBootnodeServiceId(serviceId) || isSignerNodeServiceId(serviceId) || strings.HasPrefix(string(serviceId), childEthNodeServiceIdPrefix) {
+			return nil, nil, stacktrace.NewError(
+				"Node '%v' exists in the enclave without bootnode '%v', so it isn't part of a network that can be reconciled; it must be removed first",
+				serviceId,
+				bootnodeServiceID,
+			)
+		}
+	}
+
+	resultObj, signerServiceCtx, err := e.startEthNetwork(enclaveCtx, staticFiles, params)
+	if err != nil {
+		return nil, nil, stacktrace.Propagate(err, "An error occurred creating the Ethereum network")
+	}
+
+	diff := &ModuleAPIReconcileDiff{
+		IsNetworkCreated:    true,
+		AddedServiceIDs:     append([]services.ServiceID{}, resultObj.BootnodeServiceIDs...),
+		RemovedServiceIDs:   []services.ServiceID{},
+		RecreatedServiceIDs: []services.ServiceID{},
+		UnchangedServiceIDs: []services.ServiceID{},
+	}
+	for _, signerServiceId := range resultObj.SignerServiceIDs {
+		if signerServiceId != bootnodeServiceID {
+			diff.AddedServiceIDs = append(diff.AddedServiceIDs, signerServiceId)
+		}
+	}
+	for _, nodeSpec := range params.Nodes {
+		diff.AddedServiceIDs = append(diff.AddedServiceIDs, nodeSpec.ServiceID)
+	}
+	for _, clefSignerInfo := range resultObj.ClefSigners {
+		diff.AddedServiceIDs = append(diff.AddedServiceIDs, clefSignerInfo.ServiceID)
+	}
+	if resultObj.DnsDiscovery != nil {
+		diff.AddedServiceIDs = append(diff.AddedServiceIDs, resultObj.DnsDiscovery.ServiceID)
+	}
+	sortReconcileDiff(diff)
+	resultObj.ReconcileDiff = diff
+	return resultObj, signerServiceCtx, nil
+}
+
+// The block production is a property of the whole network, so it can't be changed without recreating the network
+func (e *EthereumKurtosisModule) verifyBlockProductionMatches(params *ModuleAPIExecuteArgs) error {
+	driver := e.engineApiBlockDriver
+	if driver == nil {
+		if params.BlockProduction != cliqueBlockProduction {
+			return stacktrace.NewError("The existing network uses '%v' block production, but '%v' was requested", cliqueBlockProduction, params.BlockProduction)
+		}
+		return nil
+	}
+
+	if params.BlockProduction != engineApiDriverBlockProduction {
+		return stacktrace.NewError("The existing network uses '%v' block production, but '%v' was requested", engineApiDriverBlockProduction, params.BlockProduction)
+	}
+	requestedSlotTime := time.Duration(params.EngineApiDriver.SlotTimeSeconds) * time.Second
+	if !strings.EqualFold(driver.feeRecipient, params.EngineApiDriver.FeeRecipient) || driver.slotTime != requestedSlotTime {
+		return stacktrace.NewError(
+			"The Engine API block driver runs with fee recipient '%v' and slot time %v, but fee recipient '%v' and slot time %v were requested",
+			driver.feeRecipient,
+			driver.slotTime,
+			params.EngineApiDriver.FeeRecipient,
+			requestedSlotTime,
+		)
+	}
+	return nil
+}
+
+func sortReconcileDiff(diff *ModuleAPIReconcileDiff) {
+	sortServiceIds(diff.AddedServiceIDs)
+	sortServiceIds(diff.RemovedServiceIDs)
+	sortServiceIds(diff.RecreatedServiceIDs)
+	sortServiceIds(diff.UnchangedServiceIDs)
+}
@@ -0,0 +1,182 @@
+package impl
+
+import (
+	"encoding/json"
+	"github.com/kurtosis-tech/kurtosis-sdk/api/golang/core/lib/enclaves"
+	"github.com/kurtosis-tech/kurtosis-sdk/api/golang/core/lib/services"
+	"github.com/kurtosis-tech/stacktrace"
+	"strings"
+)
+
+const (
+	clientVersionRpcCall = `{"jsonrpc":"2.0","method":"web3_clientVersion","params":[],"id":67}`
+	headBlockRpcCall     = `{"jsonrpc":"2.0","method":"eth_getBlockByNumber","params":["latest", false],"id":1}`
+	syncingRpcCall       = `{"jsonrpc":"2.0","method":"eth_syncing","params":[],"id":1}`
+	peersRpcCall         = `{"jsonrpc":"2.0","method":"admin_peers","params":[],"id":1}`
+	txpoolStatusRpcCall  = `{"jsonrpc":"2.0","method":"txpool_status","params":[],"id":1}`
+
+	notSyncingResult = "false"
+)
+
+// Queries every node that the module started in the enclave, without changing anything, which is what the "status" action
+// returns
+func (e *EthereumKurtosisModule) GetNetworkStatus(enclaveCtx *enclaves.EnclaveContext) (*ModuleAPIStatusResult, error) {
+	return e.getNetworkStatus(enclaveCtx)
+}
+
+func (e *EthereumKurtosisModule) getNetworkStatus(enclaveCtx moduleEnclave) (*ModuleAPIStatusResult, error) {
+	serviceIds, err := enclaveCtx.GetServices()
+	if err != nil {
+		return nil, stacktrace.Propagate(err, "An error occurred getting the services in the enclave")
+	}
+
+	allNodeStatus := map[services.ServiceID]*ModuleAPINodeStatus{}
+	for serviceId := range serviceIds {
+		if !isBootnodeServiceId(serviceId) && serviceId != devNodeServiceID && !isSignerNodeServiceId(serviceId) && !strings.HasPrefix(string(serviceId), childEthNodeServiceIdPrefix) {
+			continue
+		}
+		if e.stoppedNodeServiceIds[serviceId] {
+			allNodeStatus[serviceId] = &ModuleAPINodeStatus{IsStopped: true}
+			continue
+		}
+
+		serviceCtx, err := enclaveCtx.GetServiceContext(serviceId)
+		if err != nil {
+			return nil, stacktrace.Propagate(err, "An error occurred getting the service context of node '%v'", serviceId)
+		}
+		// Discovery-only bootnodes have no RPC endpoint nor chain to report on
+		if isDiscoveryOnlyBootnode(serviceCtx) {
+			continue
+		}
+		// A node that can't be queried is reported rather than failing the whole status, as it's exactly what the caller wants to know about
+		nodeStatus := &ModuleAPINodeStatus{}
+		if err := fillNodeStatus(e.rpcTransport, serviceCtx, nodeStatus); err != nil {
+			nodeStatus.Error = err.Error()
+		}
+		allNodeStatus[serviceId] = nodeStatus
+	}
+	if len(allNodeStatus) == 0 {
+		return nil, stacktrace.NewError("No node started by this module was found in the enclave")
+	}
+
+	return &ModuleAPIStatusResult{
+		Action:     StatusAction,
+		NodeStatus: allNodeStatus,
+		HeadsAgree: doHeadsAgree(allNodeStatus),
+	}, nil
+}
+
+func fillNodeStatus(transport rpcTransport, serviceCtx *services.ServiceContext, nodeStatus *ModuleAPINodeStatus) error {
+	rpcJwtSecret, err := getNodeRpcJwtSecret(serviceCtx)
+	if err != nil {
+		return stacktrace.Propagate(err, "An error occurred getting the secret that calls to the node are signed with")
+	}
+
+	clientVersionResponse := new(EthAPIClientVersionResponse)
+	if err := sendNodeRpcCall(transport, serviceCtx, rpcJwtSecret, clientVersionRpcCall, clientVersionResponse); err != nil {
+		return stacktrace.Propagate(err, "An error occurred getting the client version")
+	}
+	if clientVersionResponse.Error != nil {
+		return stacktrace.NewError("Getting the client version returned an error: %v", clientVersionResponse.Error.Message)
+	}
+	nodeStatus.ClientVersion = clientVersionResponse.Result
+
+	headBlockResponse := new(EthAPIBlockResponse)
+	if err := sendNodeRpcCall(transport, serviceCtx, rpcJwtSecret, headBlockRpcCall, headBlockResponse); err != nil {
+		return stacktrace.Propagate(err, "An error occurred getting the head block")
+	}
+	if headBlockResponse.Error != nil {
+		return stacktrace.NewError("Getting the head block returned an error: %v", headBlockResponse.Error.Message)
+	}
+	if headBlockResponse.Result == nil {
+		return stacktrace.NewError("No head block was returned")
+	}
+	headBlockNumber, err := parseHexUint64(headBlockResponse.Result.Number)
+	if err != nil {
+		return stacktrace.Propagate(err, "An error occurred parsing the number of the head block")
+	}
+	nodeStatus.HeadBlockNumber = headBlockNumber
+	nodeStatus.HeadBlockHash = headBlockResponse.Result.Hash
+
+	syncingResponse := new(EthAPISyncingResponse)
+	if err := sendNodeRpcCall(transport, serviceCtx, rpcJwtSecret, syncingRpcCall, syncingResponse); err != nil {
+		return stacktrace.Propagate(err, "An error occurred getting the sync status")
+	}
+	if syncingResponse.Error != nil {
+		return stacktrace.NewError("Getting the sync status returned an error: %v", syncingResponse.Error.Message)
+	}
+	if string(syncingResponse.Result) != notSyncingResult {
+		syncProgress := new(EthAPISyncProgress)
+		if err := json.Unmarshal(syncingResponse.Result, syncProgress); err != nil {
+			return stacktrace.Propagate(err, "An error occurred parsing sync progress '%v'", string(syncingResponse.Result))
+		}
+		currentBlock, err := parseHexUint64(syncProgress.CurrentBlock)
+		if err != nil {
+			return stacktrace.Propagate(err, "An error occurred parsing the current block of the sync progress")
+		}
+		highestBlock, err := parseHexUint64(syncProgress.HighestBlock)
+		if err != nil {
+			return stacktrace.Propagate(err, "An error occurred parsing the highest block of the sync progress")
+		}
+		nodeStatus.IsSyncing = true
+		nodeStatus.SyncCurrentBlock = currentBlock
+		nodeStatus.SyncHighestBlock = highestBlock
+	}
+
+	peersResponse := new(EthAPIPeersResponse)
+	if err := sendNodeRpcCall(transport, serviceCtx, rpcJwtSecret, peersRpcCall, peersResponse); err != nil {
+		return stacktrace.Propagate(err, "An error occurred getting the peers")
+	}
+	if peersResponse.Error != nil {
+		return stacktrace.NewError("Getting the peers returned an error: %v", peersResponse.Error.Message)
+	}
+	nodeStatus.Peers = []*ModuleAPIPeerInfo{}
+	for _, peer := range peersResponse.Result {
+		nodeStatus.Peers = append(nodeStatus.Peers, &ModuleAPIPeerInfo{
+			Enode:         peer.Enode,
+			Name:          peer.Name,
+			RemoteAddress: peer.Network.RemoteAddress,
+		})
+	}
+
+	txpoolStatusResponse := new(EthAPITxpoolStatusResponse)
+	if err := sendNodeRpcCall(transport, serviceCtx, rpcJwtSecret, txpoolStatusRpcCall, txpoolStatusResponse); err != nil {
+		return stacktrace.Propagate(err, "An error occurred getting the txpool status")
+	}
+	if txpoolStatusResponse.Error != nil {
+		return stacktrace.NewError("Getting the txpool status returned an error: %v", txpoolStatusResponse.Error.Message)
+	}
+	if txpoolStatusResponse.Result == nil {
+		return stacktrace.NewError("No txpool status was returned")
+	}
+	txpoolPending, err := parseHexUint64(txpoolStatusResponse.Result.Pending)
+	if err != nil {
+		return stacktrace.Propagate(err, "An error occurred parsing the number of pending txpool transactions")
+	}
+	txpoolQueued, err := parseHexUint64(txpoolStatusResponse.Result.Queued)
+	if err != nil {
+		return stacktrace.Propagate(err, "An error occurred parsing the number of queued txpool transactions")
+	}
+	nodeStatus.TxpoolPending = txpoolPending
+	nodeStatus.TxpoolQueued = txpoolQueued
+
+	return nil
+}
+
+func doHeadsAgree(allNodeStatus map[services.ServiceID]*ModuleAPINodeStatus) bool {
+	headBlockHash := ""
+	for _, nodeStatus := range allNodeStatus {
+		if nodeStatus.IsStopped {
+			continue
+		}
+		if nodeStatus.Error != "" {
+			return false
+		}
+		if headBlockHash == "" {
+			headBlockHash = nodeStatus.HeadBlockHash
+		} else if nodeStatus.HeadBlockHash != headBlockHash {
+			return false
+		}
+	}
+	return true
+}
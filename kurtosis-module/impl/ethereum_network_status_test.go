@@ -0,0 +1,122 @@
+package impl
+
+import (
+	"testing"
+
+	"github.com/kurtosis-tech/kurtosis-sdk/api/golang/core/lib/services"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	testAdvancedHeadBlockNumber = 5
+)
+
+func TestGetNetworkStatus(t *testing.T) {
+	tests := []struct {
+		name                     string
+		createParams             *ModuleAPIExecuteArgs
+		laterActions             []*ModuleAPIExecuteArgs
+		unavailableServiceIds    []services.ServiceID
+		advancedServiceIds       []services.ServiceID
+		expectedReportedIds      []services.ServiceID
+		expectedStoppedIds       []services.ServiceID
+		expectedErroredIds       []services.ServiceID
+		expectedHeadsAgree       bool
+		expectedHeadBlockNumbers map[services.ServiceID]uint64
+	}{
+		{
+			name:                "running network",
+			createParams:        &ModuleAPIExecuteArgs{},
+			expectedReportedIds: []services.ServiceID{bootnodeServiceID, getChildEthNodeServiceId(1), getChildEthNodeServiceId(2)},
+			expectedHeadsAgree:  true,
+		},
+		{
+			name:                "discovery-only bootnode",
+			createParams:        &ModuleAPIExecuteArgs{BootnodeMode: discoveryOnlyBootnodeMode, SignerCount: 1},
+			expectedReportedIds: []services.ServiceID{getSignerNodeServiceId(1), getChildEthNodeServiceId(1), getChildEthNodeServiceId(2)},
+			expectedHeadsAgree:  true,
+		},
+		{
+			name:                "stopped node",
+			createParams:        &ModuleAPIExecuteArgs{},
+			laterActions:        []*ModuleAPIExecuteArgs{{Action: StopNodeAction, NodeServiceID: getChildEthNodeServiceId(1)}},
+			expectedReportedIds: []services.ServiceID{bootnodeServiceID, getChildEthNodeServiceId(1), getChildEthNodeServiceId(2)},
+			expectedStoppedIds:  []services.ServiceID{getChildEthNodeServiceId(1)},
+			expectedHeadsAgree:  true,
+		},
+		{
+			name:                  "unreachable node",
+			createParams:          &ModuleAPIExecuteArgs{},
+			unavailableServiceIds: []services.ServiceID{getChildEthNodeServiceId(2)},
+			expectedReportedIds:   []services.ServiceID{bootnodeServiceID, getChildEthNodeServiceId(1), getChildEthNodeServiceId(2)},
+			expectedErroredIds:    []services.ServiceID{getChildEthNodeServiceId(2)},
+			expectedHeadsAgree:    false,
+		},
+		{
+			name:                "disagreeing heads",
+			createParams:        &ModuleAPIExecuteArgs{},
+			advancedServiceIds:  []services.ServiceID{bootnodeServiceID},
+			expectedReportedIds: []services.ServiceID{bootnodeServiceID, getChildEthNodeServiceId(1), getChildEthNodeServiceId(2)},
+			expectedHeadsAgree:  false,
+			expectedHeadBlockNumbers: map[services.ServiceID]uint64{
+				bootnodeServiceID:           testAdvancedHeadBlockNumber,
+				getChildEthNodeServiceId(1): 0,
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			enclave := newFakeEnclave()
+			module := newFakeEnclaveModule(enclave)
+			require.NoError(t, applyDefaultsAndValidateParams(test.createParams))
+			_, err := module.executeValidatedParams(enclave, test.createParams)
+			require.NoError(t, err)
+			for _, params := range test.laterActions {
+				require.NoError(t, applyDefaultsAndValidateParams(params))
+				_, err := module.executeValidatedParams(enclave, params)
+				require.NoError(t, err)
+			}
+			for _, serviceId := range test.unavailableServiceIds {
+				enclave.setUnavailable(serviceId)
+			}
+			for _, serviceId := range test.advancedServiceIds {
+				enclave.setHeadBlockNumber(serviceId, testAdvancedHeadBlockNumber)
+			}
+
+			statusObj, err := module.getNetworkStatus(enclave)
+			require.NoError(t, err)
+			require.Equal(t, StatusAction, statusObj.Action)
+			reportedIds := []services.ServiceID{}
+			stoppedIds := []services.ServiceID{}
+			erroredIds := []services.ServiceID{}
+			for serviceId, nodeStatus := range statusObj.NodeStatus {
+				reportedIds = append(reportedIds, serviceId)
+				switch {
+				case nodeStatus.IsStopped:
+					stoppedIds = append(stoppedIds, serviceId)
+				case nodeStatus.Error != "":
+					erroredIds = append(erroredIds, serviceId)
+				default:
+					require.Equal(t, fakeGethClientVersion, nodeStatus.ClientVersion, "Node '%v' reported the wrong client version", serviceId)
+					require.NotEmpty(t, nodeStatus.HeadBlockHash, "Node '%v' reported no head block", serviceId)
+				}
+			}
+			require.ElementsMatch(t, test.expectedReportedIds, reportedIds)
+			require.ElementsMatch(t, test.expectedStoppedIds, stoppedIds)
+			require.ElementsMatch(t, test.expectedErroredIds, erroredIds)
+			require.Equal(t, test.expectedHeadsAgree, statusObj.HeadsAgree)
+			for serviceId, expectedHeadBlockNumber := range test.expectedHeadBlockNumbers {
+				require.Equal(t, expectedHeadBlockNumber, statusObj.NodeStatus[serviceId].HeadBlockNumber, "Node '%v' reported the wrong head block", serviceId)
+			}
+		})
+	}
+}
+
+// A status is only a status of the network that the module started, so an enclave without its nodes has none
+func TestGetNetworkStatus_NoNodes(t *testing.T) {
+	enclave := newFakeEnclave()
+	_, err := enclave.AddService(testOtherServiceId, services.NewContainerConfigBuilder(ethereumDockerImageName).Build())
+	require.NoError(t, err)
+	_, err = newFakeEnclaveModule(enclave).getNetworkStatus(enclave)
+	require.Error(t, err)
+}
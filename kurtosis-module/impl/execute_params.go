@@ -0,0 +1,67 @@
+package impl
+
+import (
+	"reflect"
+	"strings"
+)
+
+const (
+	actionParamName = "action"
+)
+
+// Params that every action on an existing network takes, which only change how the action is executed and reported
+var actionExecutionParamNames = []string{
+	"mode",
+	"secrets_output",
+	"include_private_keys",
+	"max_concurrency",
+	"keep_on_failure",
+}
+
+// The params that each action on an existing network takes, by their JSON name; the actions work on the network as it was
+// created, so a param that isn't listed here is rejected rather than ignored
+var paramNamesByAction = map[string][]string{
+	StatusAction:      {},
+	AddNodesAction:    append([]string{"node_count"}, actionExecutionParamNames...),
+	StopNodeAction:    append([]string{"node_service_id"}, actionExecutionParamNames...),
+	RestartNodeAction: append([]string{"node_service_id"}, actionExecutionParamNames...),
+	RemoveNodeAction:  append([]string{"node_service_id"}, actionExecutionParamNames...),
+}
+
+// The params that "dev" mode takes, by their JSON name, as the dev node has no peers, bootnodes or signers to configure
+var devModeParamNames = []string{
+	"mode",
+	"dev_period_seconds",
+	"static_files_artifact_uuid",
+	"secrets_output",
+	"include_private_keys",
+	"max_concurrency",
+	"keep_on_failure",
+}
+
+// Returns the JSON names of the params that were provided, other than the action, but aren't among the given ones
+// A param counts as provided when it isn't empty, which is how the module tells a provided param from a missing one
+func getUnexpectedParamNames(params *ModuleAPIExecuteArgs, expectedParamNames []string) []string {
+	isExpected := map[string]bool{actionParamName: true}
+	for _, paramName := range expectedParamNames {
+		isExpected[paramName] = true
+	}
+
+	paramsValue := reflect.ValueOf(params).Elem()
+	paramsType := paramsValue.Type()
+	result := []string{}
+	for i := 0; i < paramsType.NumField(); i++ {
+		paramName := strings.Split(paramsType.Field(i).Tag.Get("json"), ",")[0]
+		if isExpected[paramName] {
+			continue
+		}
+		paramValue := paramsValue.Field(i)
+		// An empty JSON array or object is as good as a missing one
+		isEmptyCollection := (paramValue.Kind() == reflect.Slice || paramValue.Kind() == reflect.Map) && paramValue.Len() == 0
+		if paramValue.IsZero() || isEmptyCollection {
+			continue
+		}
+		result = append(result, paramName)
+	}
+	return result
+}
@@ -0,0 +1,97 @@
+package impl
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestApplyDefaultsAndValidateParams_ParamsByAction(t *testing.T) {
+	tests := []struct {
+		name          string
+		params        *ModuleAPIExecuteArgs
+		isErrExpected bool
+	}{
+		{
+			name:   "status without params",
+			params: &ModuleAPIExecuteArgs{Action: StatusAction},
+		},
+		{
+			name:          "status with a param",
+			params:        &ModuleAPIExecuteArgs{Action: StatusAction, MaxConcurrency: 2},
+			isErrExpected: true,
+		},
+		{
+			name:   "add nodes with a node count",
+			params: &ModuleAPIExecuteArgs{Action: AddNodesAction, NodeCount: 2, KeepOnFailure: true},
+		},
+		{
+			name:          "add nodes with a node service ID",
+			params:        &ModuleAPIExecuteArgs{Action: AddNodesAction, NodeServiceID: "ethereum-node-1"},
+			isErrExpected: true,
+		},
+		{
+			name:          "stop node with a node count",
+			params:        &ModuleAPIExecuteArgs{Action: StopNodeAction, NodeServiceID: "ethereum-node-1", NodeCount: 1},
+			isErrExpected: true,
+		},
+		{
+			name:          "restart node with a network setting",
+			params:        &ModuleAPIExecuteArgs{Action: RestartNodeAction, NodeServiceID: "ethereum-node-1", ImportedAccounts: &ModuleAPIImportedAccountsArgs{}},
+			isErrExpected: true,
+		},
+		{
+			name:   "remove node with an empty node list",
+			params: &ModuleAPIExecuteArgs{Action: RemoveNodeAction, NodeServiceID: "ethereum-node-1", Nodes: []*ModuleAPINodeSpec{}},
+		},
+		{
+			name:   "create with a key seed",
+			params: &ModuleAPIExecuteArgs{KeySeed: testKeySeed},
+		},
+		{
+			name:          "create with a short key seed",
+			params:        &ModuleAPIExecuteArgs{KeySeed: "short-seed"},
+			isErrExpected: true,
+		},
+		{
+			name:          "add nodes with a key seed",
+			params:        &ModuleAPIExecuteArgs{Action: AddNodesAction, KeySeed: testKeySeed},
+			isErrExpected: true,
+		},
+		{
+			name:   "dev mode with a dev period",
+			params: &ModuleAPIExecuteArgs{Mode: devMode, DevPeriodSeconds: 2, IncludePrivateKeys: true},
+		},
+		{
+			name:          "dev mode with a peering strategy",
+			params:        &ModuleAPIExecuteArgs{Mode: devMode, PeeringStrategy: staticNodesPeeringStrategy},
+			isErrExpected: true,
+		},
+		{
+			name:          "dev mode with a static files artifact and a signer count",
+			params:        &ModuleAPIExecuteArgs{Mode: devMode, StaticFilesArtifactUUID: "static-files", SignerCount: 2},
+			isErrExpected: true,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := applyDefaultsAndValidateParams(test.params)
+			if test.isErrExpected {
+				require.Error(t, err)
+			} else {
+				require.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestGetUnexpectedParamNames(t *testing.T) {
+	params := &ModuleAPIExecuteArgs{
+		Action:             AddNodesAction,
+		NodeCount:          1,
+		PeeringStrategy:    discoveryPeeringStrategy,
+		IncludePrivateKeys: true,
+		ResourceDefaults:   &ModuleAPIResourceDefaults{},
+	}
+	require.Equal(t, []string{"peering_strategy", "resource_defaults"}, getUnexpectedParamNames(params, paramNamesByAction[AddNodesAction]))
+}
@@ -0,0 +1,679 @@
+package impl
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/p2p/enode"
+	"github.com/kurtosis-tech/kurtosis-sdk/api/golang/core/kurtosis_core_rpc_api_bindings"
+	"github.com/kurtosis-tech/kurtosis-sdk/api/golang/core/lib/services"
+	"github.com/kurtosis-tech/stacktrace"
+	"google.golang.org/grpc"
+	"google.golang.org/protobuf/types/known/emptypb"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+const (
+	fakeEnclaveIpAddrPrefix       = "10.0.0."
+	fakeEnclavePublicIpAddr       = "127.0.0.1"
+	fakeFilesArtifactUuidPrefix   = "fake-files-artifact-"
+	fakeGethClientVersion         = "Geth/v1.10.8-stable/linux-amd64/go1.16.7"
+	fakeGethMethodNotFoundErrCode = -32601
+
+	fakeCommandFailedExitCode   = 1
+	fakeCommandNotFoundExitCode = 127
+
+	fakeUnsupportedCallErrFormat = "API container call '%v' isn't supported by the fake enclave"
+)
+
+// The peering mechanisms of Geth that the fake enclave simulates, which are what its peerings get recorded with
+type fakePeeringMechanism string
+
+const (
+	fakeAddPeerMechanism     fakePeeringMechanism = "admin_addPeer"
+	fakeStaticNodesMechanism fakePeeringMechanism = "static_nodes"
+	fakeDiscoveryMechanism   fakePeeringMechanism = "discovery"
+)
+
+// A peer list in the rendered Geth config, like "StaticNodes = ["enode://..."]"
+var fakeGethConfigPeerListRegex = regexp.MustCompile(`(?m)^(BootstrapNodes|StaticNodes) = (\[.*\])$`)
+
+// An in-memory enclave whose services answer like Geth nodes, so that starting, peering and verifying nodes can run without
+// Docker: the nodes answer the RPC calls sent through it as the transport, and the "geth attach", "cat" and "printenv" commands
+// executed on them
+// Like Geth, a node peers with the nodes added with admin_addPeer, dials the static nodes in its config, and discovers the
+// network through the bootnodes in its config, peering with a bootnode that's a Geth node and with the other nodes that
+// discover through the same bootnode; trusted nodes are never dialed, and DNS discovery isn't simulated
+type fakeEnclave struct {
+	mutex *sync.Mutex
+
+	fakeServices       map[services.ServiceID]*fakeService
+	serviceIdsByIpAddr map[string]services.ServiceID
+	// File contents by file name, for the files artifacts uploaded to the enclave
+	filesArtifacts map[services.FilesArtifactUUID]map[string]string
+
+	// Services that never answer, as if waiting for them to become available timed out
+	unavailableServiceIds map[services.ServiceID]bool
+	// Services whose container is paused, which don't answer until they're unpaused
+	pausedServiceIds map[services.ServiceID]bool
+	// Numbers of the head blocks of the nodes that moved past the genesis block
+	headBlockNumbers map[services.ServiceID]uint64
+}
+
+// The API container client behind the service contexts of a fake enclave, of which only command execution is implemented, as
+// it's the only call that a service context makes; the other calls return an error
+type fakeApiContainerClient struct {
+	enclave *fakeEnclave
+}
+
+type fakeService struct {
+	serviceCtx      *services.ServiceContext
+	containerConfig *services.ContainerConfig
+	// Only Geth nodes have peers, unlike the discovery-only bootnodes, the Clefs and the DNS server
+	isGethNode bool
+	// The mechanisms that each peer got connected through
+	peerMechanisms map[services.ServiceID]map[fakePeeringMechanism]bool
+	// The bootnodes that the node discovers the network through
+	bootnodeServiceIds map[services.ServiceID]bool
+}
+
+func newFakeEnclave() *fakeEnclave {
+	return &fakeEnclave{
+		mutex:                 &sync.Mutex{},
+		fakeServices:          map[services.ServiceID]*fakeService{},
+		serviceIdsByIpAddr:    map[string]services.ServiceID{},
+		filesArtifacts:        map[services.FilesArtifactUUID]map[string]string{},
+		unavailableServiceIds: map[services.ServiceID]bool{},
+		pausedServiceIds:      map[services.ServiceID]bool{},
+		headBlockNumbers:      map[services.ServiceID]uint64{},
+	}
+}
+
+// Makes the given service, which may not have been added yet, never become available
+func (enclave *fakeEnclave) setUnavailable(serviceId services.ServiceID) {
+	enclave.mutex.Lock()
+	defer enclave.mutex.Unlock()
+	enclave.unavailableServiceIds[serviceId] = true
+}
+
+// Returns the service IDs of the peers of the given node, in order
+// Sets the head block of the given node, whose hash only depends on its number, so that nodes with the same head agree
+func (enclave *fakeEnclave) setHeadBlockNumber(serviceId services.ServiceID, headBlockNumber uint64) {
+	enclave.mutex.Lock()
+	defer enclave.mutex.Unlock()
+	enclave.headBlockNumbers[serviceId] = headBlockNumber
+}
+
+func (enclave *fakeEnclave) getHeadBlock(serviceId services.ServiceID) *EthAPIBlock {
+	enclave.mutex.Lock()
+	defer enclave.mutex.Unlock()
+	headBlockNumber := enclave.headBlockNumbers[serviceId]
+	return &EthAPIBlock{
+		Hash:      crypto.Keccak256Hash([]byte(strconv.FormatUint(headBlockNumber, 10))).Hex(),
+		Number:    hexutil.EncodeUint64(headBlockNumber),
+		Timestamp: hexutil.EncodeUint64(headBlockNumber),
+	}
+}
+
+func (enclave *fakeEnclave) getPeerServiceIds(serviceId services.ServiceID) []services.ServiceID {
+	enclave.mutex.Lock()
+	defer enclave.mutex.Unlock()
+	result := []services.ServiceID{}
+	if service, found := enclave.fakeServices[serviceId]; found {
+		for peerServiceId := range service.peerMechanisms {
+			result = append(result, peerServiceId)
+		}
+	}
+	sortServiceIds(result)
+	return result
+}
+
+// Returns the mechanisms that the given nodes got peered through, which is none if they aren't peered
+func (enclave *fakeEnclave) getPeeringMechanisms(serviceId services.ServiceID, peerServiceId services.ServiceID) map[fakePeeringMechanism]bool {
+	enclave.mutex.Lock()
+	defer enclave.mutex.Unlock()
+	result := map[fakePeeringMechanism]bool{}
+	if service, found := enclave.fakeServices[serviceId]; found {
+		for mechanism := range service.peerMechanisms[peerServiceId] {
+			result[mechanism] = true
+		}
+	}
+	return result
+}
+
+// Returns the contents of the given file of the given files artifact
+func (enclave *fakeEnclave) getUploadedFile(filesArtifactUuid services.FilesArtifactUUID, filename string) (string, bool) {
+	enclave.mutex.Lock()
+	defer enclave.mutex.Unlock()
+	contents, found := enclave.filesArtifacts[filesArtifactUuid][filename]
+	return contents, found
+}
+
+// Returns the names of the files of the given files artifact
+func (enclave *fakeEnclave) getUploadedFilenames(filesArtifactUuid services.FilesArtifactUUID) []string {
+	enclave.mutex.Lock()
+	defer enclave.mutex.Unlock()
+	result := []string{}
+	for filename := range enclave.filesArtifacts[filesArtifactUuid] {
+		result = append(result, filename)
+	}
+	return result
+}
+
+// Returns how many files artifacts were uploaded to the enclave
+func (enclave *fakeEnclave) getFilesArtifactCount() int {
+	enclave.mutex.Lock()
+	defer enclave.mutex.Unlock()
+	return len(enclave.filesArtifacts)
+}
+
+func (enclave *fakeEnclave) AddService(serviceId services.ServiceID, containerConfig *services.ContainerConfig) (*services.ServiceContext, error) {
+	enclave.mutex.Lock()
+	defer enclave.mutex.Unlock()
+	if _, found := enclave.fakeServices[serviceId]; found {
+		return nil, stacktrace.NewError("Service '%v' already exists in the enclave", serviceId)
+	}
+	for filesArtifactUuid := range containerConfig.GetFilesArtifactMountpoints() {
+		if _, found := enclave.filesArtifacts[filesArtifactUuid]; !found {
+			return nil, stacktrace.NewError("Files artifact '%v' of service '%v' was never uploaded to the enclave", filesArtifactUuid, serviceId)
+		}
+	}
+	ipAddr := fakeEnclaveIpAddrPrefix + strconv.Itoa(len(enclave.fakeServices)+1)
+	serviceCtx := services.NewServiceContext(
+		&fakeApiContainerClient{enclave: enclave},
+		serviceId,
+		ipAddr,
+		containerConfig.GetUsedPorts(),
+		fakeEnclavePublicIpAddr,
+		containerConfig.GetUsedPorts(),
+	)
+	_, isGethNode := containerConfig.GetUsedPorts()[tcpDiscoveryPortId]
+	service := &fakeService{
+		serviceCtx:         serviceCtx,
+		containerConfig:    containerConfig,
+		isGethNode:         isGethNode,
+		peerMechanisms:     map[services.ServiceID]map[fakePeeringMechanism]bool{},
+		bootnodeServiceIds: map[services.ServiceID]bool{},
+	}
+	enclave.fakeServices[serviceId] = service
+	enclave.serviceIdsByIpAddr[ipAddr] = serviceId
+	if isGethNode {
+		if err := enclave.connectStartupPeers(serviceId, service); err != nil {
+			return nil, stacktrace.Propagate(err, "An error occurred connecting node '%v' to the nodes it was started with", serviceId)
+		}
+	}
+	return serviceCtx, nil
+}
+
+// Connects a node that was just added to its static nodes and to the nodes it discovers through its bootnodes, which are in
+// the Geth config in its files artifacts; the caller must hold the mutex
+func (enclave *fakeEnclave) connectStartupPeers(serviceId services.ServiceID, service *fakeService) error {
+	for filesArtifactUuid := range service.containerConfig.GetFilesArtifactMountpoints() {
+		gethConfig, found := enclave.filesArtifacts[filesArtifactUuid][gethConfigFilename]
+		if !found {
+			continue
+		}
+		for _, match := range fakeGethConfigPeerListRegex.FindAllStringSubmatch(gethConfig, -1) {
+			configKey, serializedNodeRecords := match[1], match[2]
+			// The rendered lists of quoted strings are valid JSON
+			nodeRecordStrs := []string{}
+			if err := json.Unmarshal([]byte(serializedNodeRecords), &nodeRecordStrs); err != nil {
+				return stacktrace.Propagate(err, "An error occurred parsing the '%v' of node '%v'", configKey, serviceId)
+			}
+			for _, nodeRecordStr := range nodeRecordStrs {
+				nodeRecord, err := enode.Parse(enode.ValidSchemes, nodeRecordStr)
+				if err != nil {
+					return stacktrace.Propagate(err, "An error occurred parsing node record '%v'", nodeRecordStr)
+				}
+				peerServiceId, found := enclave.serviceIdsByIpAddr[nodeRecord.IP().String()]
+				if !found {
+					continue
+				}
+				if configKey == staticNodesConfigKey {
+					enclave.connectLocked(serviceId, peerServiceId, fakeStaticNodesMechanism)
+					continue
+				}
+				service.bootnodeServiceIds[peerServiceId] = true
+				enclave.connectLocked(serviceId, peerServiceId, fakeDiscoveryMechanism)
+				for otherServiceId, otherService := range enclave.fakeServices {
+					if otherService.bootnodeServiceIds[peerServiceId] {
+						enclave.connectLocked(serviceId, otherServiceId, fakeDiscoveryMechanism)
+					}
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// Peers the two Geth nodes in both directions through the given mechanism, unless one of them never became available; the
+// caller must hold the mutex
+func (enclave *fakeEnclave) connectLocked(serviceId services.ServiceID, peerServiceId services.ServiceID, mechanism fakePeeringMechanism) {
+	service, peerService := enclave.fakeServices[serviceId], enclave.fakeServices[peerServiceId]
+	if serviceId == peerServiceId || !service.isGethNode || !peerService.isGethNode {
+		return
+	}
+	if enclave.unavailableServiceIds[serviceId] || enclave.unavailableServiceIds[peerServiceId] {
+		return
+	}
+	if service.peerMechanisms[peerServiceId] == nil {
+		service.peerMechanisms[peerServiceId] = map[fakePeeringMechanism]bool{}
+		peerService.peerMechanisms[serviceId] = map[fakePeeringMechanism]bool{}
+	}
+	service.peerMechanisms[peerServiceId][mechanism] = true
+	peerService.peerMechanisms[serviceId][mechanism] = true
+}
+
+func (enclave *fakeEnclave) UploadFiles(pathToUpload string) (services.FilesArtifactUUID, error) {
+	fileInfos, err := ioutil.ReadDir(pathToUpload)
+	if err != nil {
+		return "", stacktrace.Propagate(err, "An error occurred listing the files at '%v'", pathToUpload)
+	}
+	fileContents := map[string]string{}
+	for _, fileInfo := range fileInfos {
+		filepath := path.Join(pathToUpload, fileInfo.Name())
+		contents, err := ioutil.ReadFile(filepath)
+		if err != nil {
+			return "", stacktrace.Propagate(err, "An error occurred reading file '%v'", filepath)
+		}
+		fileContents[fileInfo.Name()] = string(contents)
+	}
+
+	enclave.mutex.Lock()
+	defer enclave.mutex.Unlock()
+	filesArtifactUuid := services.FilesArtifactUUID(fakeFilesArtifactUuidPrefix + strconv.Itoa(len(enclave.filesArtifacts)+1))
+	enclave.filesArtifacts[filesArtifactUuid] = fileContents
+	return filesArtifactUuid, nil
+}
+
+func (enclave *fakeEnclave) GetServiceContext(serviceId services.ServiceID) (*services.ServiceContext, error) {
+	enclave.mutex.Lock()
+	defer enclave.mutex.Unlock()
+	service, found := enclave.fakeServices[serviceId]
+	if !found {
+		return nil, stacktrace.NewError("No service '%v' exists in the enclave", serviceId)
+	}
+	return service.serviceCtx, nil
+}
+
+func (enclave *fakeEnclave) GetServices() (map[services.ServiceID]bool, error) {
+	enclave.mutex.Lock()
+	defer enclave.mutex.Unlock()
+	result := map[services.ServiceID]bool{}
+	for serviceId := range enclave.fakeServices {
+		result[serviceId] = true
+	}
+	return result, nil
+}
+
+// Removes the service along with its peerings, as the other nodes drop a peer whose container is gone
+func (enclave *fakeEnclave) RemoveService(serviceId services.ServiceID, containerStopTimeoutSeconds uint64) error {
+	enclave.mutex.Lock()
+	defer enclave.mutex.Unlock()
+	service, found := enclave.fakeServices[serviceId]
+	if !found {
+		return stacktrace.NewError("No service '%v' exists in the enclave", serviceId)
+	}
+	for peerServiceId := range service.peerMechanisms {
+		delete(enclave.fakeServices[peerServiceId].peerMechanisms, serviceId)
+	}
+	delete(enclave.serviceIdsByIpAddr, service.serviceCtx.GetPrivateIPAddress())
+	delete(enclave.fakeServices, serviceId)
+	delete(enclave.pausedServiceIds, serviceId)
+	return nil
+}
+
+func (enclave *fakeEnclave) PauseService(serviceId services.ServiceID) error {
+	enclave.mutex.Lock()
+	defer enclave.mutex.Unlock()
+	if _, found := enclave.fakeServices[serviceId]; !found {
+		return stacktrace.NewError("No service '%v' exists in the enclave", serviceId)
+	}
+	enclave.pausedServiceIds[serviceId] = true
+	return nil
+}
+
+func (enclave *fakeEnclave) UnpauseService(serviceId services.ServiceID) error {
+	enclave.mutex.Lock()
+	defer enclave.mutex.Unlock()
+	if _, found := enclave.fakeServices[serviceId]; !found {
+		return stacktrace.NewError("No service '%v' exists in the enclave", serviceId)
+	}
+	delete(enclave.pausedServiceIds, serviceId)
+	return nil
+}
+
+func (enclave *fakeEnclave) WaitForHttpPostEndpointAvailability(
+	serviceId services.ServiceID,
+	port uint32,
+	path string,
+	requestBody string,
+	initialDelayMilliseconds uint32,
+	retries uint32,
+	retriesDelayMilliseconds uint32,
+	bodyText string,
+) error {
+	enclave.mutex.Lock()
+	defer enclave.mutex.Unlock()
+	if _, found := enclave.fakeServices[serviceId]; !found {
+		return stacktrace.NewError("No service '%v' exists in the enclave", serviceId)
+	}
+	if enclave.unavailableServiceIds[serviceId] {
+		return stacktrace.NewError(
+			"The HTTP endpoint on port %v of service '%v' didn't become available, even after %v retries with %vms between retries",
+			port,
+			serviceId,
+			retries,
+			retriesDelayMilliseconds,
+		)
+	}
+	return nil
+}
+
+func (client *fakeApiContainerClient) ExecCommand(
+	ctx context.Context,
+	args *kurtosis_core_rpc_api_bindings.ExecCommandArgs,
+	opts ...grpc.CallOption,
+) (*kurtosis_core_rpc_api_bindings.ExecCommandResponse, error) {
+	enclave := client.enclave
+	serviceId := services.ServiceID(args.GetServiceId())
+	enclave.mutex.Lock()
+	service, found := enclave.fakeServices[serviceId]
+	enclave.mutex.Unlock()
+	if !found {
+		return nil, stacktrace.NewError("No service '%v' exists in the enclave", serviceId)
+	}
+
+	exitCode, logOutput := enclave.execFakeCommand(serviceId, service, args.GetCommandArgs())
+	return &kurtosis_core_rpc_api_bindings.ExecCommandResponse{
+		ExitCode:  exitCode,
+		LogOutput: logOutput,
+	}, nil
+}
+
+// The enclave calls go through the fake enclave itself rather than its client, so the client only supports command execution
+
+func (client *fakeApiContainerClient) LoadModule(ctx context.Context, args *kurtosis_core_rpc_api_bindings.LoadModuleArgs, opts ...grpc.CallOption) (*kurtosis_core_rpc_api_bindings.LoadModuleResponse, error) {
+	return nil, stacktrace.NewError(fakeUnsupportedCallErrFormat, "LoadModule")
+}
+
+func (client *fakeApiContainerClient) GetModules(ctx context.Context, args *kurtosis_core_rpc_api_bindings.GetModulesArgs, opts ...grpc.CallOption) (*kurtosis_core_rpc_api_bindings.GetModulesResponse, error) {
+	return nil, stacktrace.NewError(fakeUnsupportedCallErrFormat, "GetModules")
+}
+
+func (client *fakeApiContainerClient) UnloadModule(ctx context.Context, args *kurtosis_core_rpc_api_bindings.UnloadModuleArgs, opts ...grpc.CallOption) (*kurtosis_core_rpc_api_bindings.UnloadModuleResponse, error) {
+	return nil, stacktrace.NewError(fakeUnsupportedCallErrFormat, "UnloadModule")
+}
+
+func (client *fakeApiContainerClient) ExecuteModule(ctx context.Context, args *kurtosis_core_rpc_api_bindings.ExecuteModuleArgs, opts ...grpc.CallOption) (*kurtosis_core_rpc_api_bindings.ExecuteModuleResponse, error) {
+	return nil, stacktrace.NewError(fakeUnsupportedCallErrFormat, "ExecuteModule")
+}
+
+func (client *fakeApiContainerClient) StartServices(ctx context.Context, args *kurtosis_core_rpc_api_bindings.StartServicesArgs, opts ...grpc.CallOption) (*kurtosis_core_rpc_api_bindings.StartServicesResponse, error) {
+	return nil, stacktrace.NewError(fakeUnsupportedCallErrFormat, "StartServices")
+}
+
+func (client *fakeApiContainerClient) GetServices(ctx context.Context, args *kurtosis_core_rpc_api_bindings.GetServicesArgs, opts ...grpc.CallOption) (*kurtosis_core_rpc_api_bindings.GetServicesResponse, error) {
+	return nil, stacktrace.NewError(fakeUnsupportedCallErrFormat, "GetServices")
+}
+
+func (client *fakeApiContainerClient) RemoveService(ctx context.Context, args *kurtosis_core_rpc_api_bindings.RemoveServiceArgs, opts ...grpc.CallOption) (*kurtosis_core_rpc_api_bindings.RemoveServiceResponse, error) {
+	return nil, stacktrace.NewError(fakeUnsupportedCallErrFormat, "RemoveService")
+}
+
+func (client *fakeApiContainerClient) Repartition(ctx context.Context, args *kurtosis_core_rpc_api_bindings.RepartitionArgs, opts ...grpc.CallOption) (*emptypb.Empty, error) {
+	return nil, stacktrace.NewError(fakeUnsupportedCallErrFormat, "Repartition")
+}
+
+func (client *fakeApiContainerClient) PauseService(ctx context.Context, args *kurtosis_core_rpc_api_bindings.PauseServiceArgs, opts ...grpc.CallOption) (*emptypb.Empty, error) {
+	return nil, stacktrace.NewError(fakeUnsupportedCallErrFormat, "PauseService")
+}
+
+func (client *fakeApiContainerClient) UnpauseService(ctx context.Context, args *kurtosis_core_rpc_api_bindings.UnpauseServiceArgs, opts ...grpc.CallOption) (*emptypb.Empty, error) {
+	return nil, stacktrace.NewError(fakeUnsupportedCallErrFormat, "UnpauseService")
+}
+
+func (client *fakeApiContainerClient) WaitForHttpGetEndpointAvailability(ctx context.Context, args *kurtosis_core_rpc_api_bindings.WaitForHttpGetEndpointAvailabilityArgs, opts ...grpc.CallOption) (*emptypb.Empty, error) {
+	return nil, stacktrace.NewError(fakeUnsupportedCallErrFormat, "WaitForHttpGetEndpointAvailability")
+}
+
+func (client *fakeApiContainerClient) WaitForHttpPostEndpointAvailability(ctx context.Context, args *kurtosis_core_rpc_api_bindings.WaitForHttpPostEndpointAvailabilityArgs, opts ...grpc.CallOption) (*emptypb.Empty, error) {
+	return nil, stacktrace.NewError(fakeUnsupportedCallErrFormat, "WaitForHttpPostEndpointAvailability")
+}
+
+func (client *fakeApiContainerClient) UploadFilesArtifact(ctx context.Context, args *kurtosis_core_rpc_api_bindings.UploadFilesArtifactArgs, opts ...grpc.CallOption) (*kurtosis_core_rpc_api_bindings.UploadFilesArtifactResponse, error) {
+	return nil, stacktrace.NewError(fakeUnsupportedCallErrFormat, "UploadFilesArtifact")
+}
+
+func (client *fakeApiContainerClient) DownloadFilesArtifact(ctx context.Context, args *kurtosis_core_rpc_api_bindings.DownloadFilesArtifactArgs, opts ...grpc.CallOption) (*kurtosis_core_rpc_api_bindings.DownloadFilesArtifactResponse, error) {
+	return nil, stacktrace.NewError(fakeUnsupportedCallErrFormat, "DownloadFilesArtifact")
+}
+
+func (client *fakeApiContainerClient) StoreWebFilesArtifact(ctx context.Context, args *kurtosis_core_rpc_api_bindings.StoreWebFilesArtifactArgs, opts ...grpc.CallOption) (*kurtosis_core_rpc_api_bindings.StoreWebFilesArtifactResponse, error) {
+	return nil, stacktrace.NewError(fakeUnsupportedCallErrFormat, "StoreWebFilesArtifact")
+}
+
+func (client *fakeApiContainerClient) StoreFilesArtifactFromService(ctx context.Context, args *kurtosis_core_rpc_api_bindings.StoreFilesArtifactFromServiceArgs, opts ...grpc.CallOption) (*kurtosis_core_rpc_api_bindings.StoreFilesArtifactFromServiceResponse, error) {
+	return nil, stacktrace.NewError(fakeUnsupportedCallErrFormat, "StoreFilesArtifactFromService")
+}
+
+func (client *fakeApiContainerClient) RenderTemplatesToFilesArtifact(ctx context.Context, args *kurtosis_core_rpc_api_bindings.RenderTemplatesToFilesArtifactArgs, opts ...grpc.CallOption) (*kurtosis_core_rpc_api_bindings.RenderTemplatesToFilesArtifactResponse, error) {
+	return nil, stacktrace.NewError(fakeUnsupportedCallErrFormat, "RenderTemplatesToFilesArtifact")
+}
+
+func (enclave *fakeEnclave) execFakeCommand(serviceId services.ServiceID, service *fakeService, command []string) (int32, string) {
+	if len(command) == 2 && command[0] == "cat" {
+		for filesArtifactUuid, mountpoint := range service.containerConfig.GetFilesArtifactMountpoints() {
+			if path.Dir(command[1]) != mountpoint {
+				continue
+			}
+			if contents, found := enclave.getUploadedFile(filesArtifactUuid, path.Base(command[1])); found {
+				return execCommandSuccessExitCode, contents
+			}
+		}
+		return fakeCommandFailedExitCode, fmt.Sprintf("cat: can't open '%v': No such file or directory\n", command[1])
+	}
+	// Lists the files at the root of a mounted files artifact, the way files artifacts are read
+	if len(command) > 2 && command[0] == "find" {
+		for filesArtifactUuid, mountpoint := range service.containerConfig.GetFilesArtifactMountpoints() {
+			if command[1] != mountpoint {
+				continue
+			}
+			listedFilepaths := ""
+			for _, filename := range enclave.getUploadedFilenames(filesArtifactUuid) {
+				listedFilepaths += path.Join(mountpoint, filename) + listedFilepathTerminator
+			}
+			return execCommandSuccessExitCode, listedFilepaths
+		}
+		return fakeCommandFailedExitCode, fmt.Sprintf("find: %v: No such file or directory\n", command[1])
+	}
+	if len(command) == 2 && command[0] == "printenv" {
+		if value, found := service.containerConfig.GetEnvironmentVariableOverrides()[command[1]]; found {
+			return execCommandSuccessExitCode, value + "\n"
+		}
+		return fakeCommandFailedExitCode, ""
+	}
+
+	commandStr := strings.Join(command, " ")
+	if !strings.Contains(commandStr, "geth attach") {
+		return fakeCommandNotFoundExitCode, fmt.Sprintf("/bin/sh: %v: not found\n", command[0])
+	}
+	if strings.Contains(commandStr, "admin.peers") {
+		return execCommandSuccessExitCode, enclave.getConsolePeers(serviceId)
+	}
+	// The RPC calls sent over IPC are the JSON in the script, whose response the console prints as a quoted string
+	rpcStartIdx := strings.Index(commandStr, "{")
+	rpcEndIdx := strings.LastIndex(commandStr, "}")
+	if rpcStartIdx < 0 || rpcEndIdx < rpcStartIdx {
+		return fakeCommandFailedExitCode, fmt.Sprintf("Fake Geth console can't run command '%v'\n", commandStr)
+	}
+	response, err := enclave.answerRpcCall(serviceId, []byte(commandStr[rpcStartIdx:rpcEndIdx+1]))
+	if err != nil {
+		return fakeCommandFailedExitCode, err.Error() + "\n"
+	}
+	return execCommandSuccessExitCode, strconv.Quote(string(response)) + "\n"
+}
+
+// Prints the peers of the given node the way the Geth console prints "admin.peers"
+func (enclave *fakeEnclave) getConsolePeers(serviceId services.ServiceID) string {
+	peerEntries := []string{}
+	for _, peerServiceId := range enclave.getPeerServiceIds(serviceId) {
+		peerEnode, err := enclave.getEnode(peerServiceId)
+		if err != nil {
+			continue
+		}
+		peerEntries = append(peerEntries, fmt.Sprintf(
+			"{\n    caps: [\"eth/66\"],\n    enode: %q,\n    name: %q,\n    protocols: {\n      eth: {\n        version: 66\n      }\n    }\n}",
+			peerEnode,
+			fakeGethClientVersion,
+		))
+	}
+	return "[" + strings.Join(peerEntries, ", ") + "]\n"
+}
+
+func (enclave *fakeEnclave) Do(request *http.Request) (*http.Response, error) {
+	ipAddr := request.URL.Hostname()
+	enclave.mutex.Lock()
+	serviceId, found := enclave.serviceIdsByIpAddr[ipAddr]
+	isUnavailable := enclave.unavailableServiceIds[serviceId] || enclave.pausedServiceIds[serviceId]
+	enclave.mutex.Unlock()
+	if !found || isUnavailable {
+		return nil, stacktrace.NewError("Dialing '%v' failed: connection refused", request.URL.Host)
+	}
+
+	requestBody, err := ioutil.ReadAll(request.Body)
+	if err != nil {
+		return nil, stacktrace.Propagate(err, "An error occurred reading the body of the request to '%v'", request.URL)
+	}
+	response, err := enclave.answerRpcCall(serviceId, requestBody)
+	if err != nil {
+		return &http.Response{
+			StatusCode: http.StatusBadRequest,
+			Body:       ioutil.NopCloser(strings.NewReader(err.Error())),
+		}, nil
+	}
+	return &http.Response{
+		StatusCode: http.StatusOK,
+		Header:     http.Header{"Content-Type": []string{jsonContentType}},
+		Body:       ioutil.NopCloser(bytes.NewReader(response)),
+	}, nil
+}
+
+// Answers an RPC call to the given node the way Geth does, for the calls that the module sends
+func (enclave *fakeEnclave) answerRpcCall(serviceId services.ServiceID, rpcJson []byte) ([]byte, error) {
+	rpcRequest := &struct {
+		Id     json.RawMessage   `json:"id"`
+		Method string            `json:"method"`
+		Params []json.RawMessage `json:"params"`
+	}{}
+	if err := json.Unmarshal(rpcJson, rpcRequest); err != nil {
+		return nil, stacktrace.Propagate(err, "An error occurred parsing RPC call '%v'", string(rpcJson))
+	}
+
+	response := map[string]interface{}{
+		"jsonrpc": "2.0",
+		"id":      rpcRequest.Id,
+	}
+	switch rpcRequest.Method {
+	case "admin_nodeInfo":
+		nodeEnode, err := enclave.getEnode(serviceId)
+		if err != nil {
+			return nil, stacktrace.Propagate(err, "An error occurred getting the enode of node '%v'", serviceId)
+		}
+		response["result"] = &EthAPINodeInfo{Enode: nodeEnode}
+	case "admin_peers":
+		peers := []*EthAPIPeer{}
+		for _, peerServiceId := range enclave.getPeerServiceIds(serviceId) {
+			peerEnode, err := enclave.getEnode(peerServiceId)
+			if err != nil {
+				return nil, stacktrace.Propagate(err, "An error occurred getting the enode of peer '%v'", peerServiceId)
+			}
+			peerRecord, err := enode.ParseV4(peerEnode)
+			if err != nil {
+				return nil, stacktrace.Propagate(err, "An error occurred parsing the enode of peer '%v'", peerServiceId)
+			}
+			peers = append(peers, &EthAPIPeer{
+				Enode:   peerEnode,
+				Name:    fakeGethClientVersion,
+				Network: EthAPIPeerNetwork{RemoteAddress: fmt.Sprintf("%v:%v", peerRecord.IP(), peerRecord.TCP())},
+			})
+		}
+		response["result"] = peers
+	case "admin_addPeer":
+		peerEnode := ""
+		if len(rpcRequest.Params) == 1 {
+			if err := json.Unmarshal(rpcRequest.Params[0], &peerEnode); err != nil {
+				return nil, stacktrace.Propagate(err, "An error occurred parsing the enode to add as a peer of node '%v'", serviceId)
+			}
+		}
+		response["result"] = enclave.addPeer(serviceId, peerEnode)
+	case "eth_blockNumber":
+		response["result"] = enclave.getHeadBlock(serviceId).Number
+	case "eth_getBlockByNumber":
+		response["result"] = enclave.getHeadBlock(serviceId)
+	case "eth_syncing":
+		response["result"] = false
+	case "txpool_status":
+		response["result"] = &EthAPITxpoolStatus{Pending: "0x0", Queued: "0x0"}
+	case "web3_clientVersion":
+		response["result"] = fakeGethClientVersion
+	default:
+		response["error"] = &EthAPIError{
+			Code:    fakeGethMethodNotFoundErrCode,
+			Message: fmt.Sprintf("the method %v does not exist/is not available", rpcRequest.Method),
+		}
+	}
+	serializedResponse, err := json.Marshal(response)
+	if err != nil {
+		return nil, stacktrace.Propagate(err, "An error occurred serializing the response to RPC call '%v'", rpcRequest.Method)
+	}
+	return serializedResponse, nil
+}
+
+// Connects the given node to the node of the given enode in both directions, returning false like Geth for an enode that
+// can't be parsed; an enode of no node in the enclave is accepted, but never becomes a peer, as Geth would keep dialing it
+func (enclave *fakeEnclave) addPeer(serviceId services.ServiceID, peerEnode string) bool {
+	peerRecord, err := enode.ParseV4(peerEnode)
+	if err != nil {
+		return false
+	}
+	enclave.mutex.Lock()
+	defer enclave.mutex.Unlock()
+	if peerServiceId, found := enclave.serviceIdsByIpAddr[peerRecord.IP().String()]; found {
+		enclave.connectLocked(serviceId, peerServiceId, fakeAddPeerMechanism)
+	}
+	return true
+}
+
+// Returns the enode of the given node from the node key in its files, which Geth's --nodekey flag reads
+func (enclave *fakeEnclave) getEnode(serviceId services.ServiceID) (string, error) {
+	enclave.mutex.Lock()
+	defer enclave.mutex.Unlock()
+	service, found := enclave.fakeServices[serviceId]
+	if !found {
+		return "", stacktrace.NewError("No service '%v' exists in the enclave", serviceId)
+	}
+	for filesArtifactUuid := range service.containerConfig.GetFilesArtifactMountpoints() {
+		hexNodeKey, found := enclave.filesArtifacts[filesArtifactUuid][nodeKeyFilename]
+		if !found {
+			continue
+		}
+		nodeKey, err := crypto.HexToECDSA(hexNodeKey)
+		if err != nil {
+			return "", stacktrace.Propagate(err, "An error occurred parsing the node key of node '%v'", serviceId)
+		}
+		ipAddr := net.ParseIP(service.serviceCtx.GetPrivateIPAddress())
+		return enode.NewV4(&nodeKey.PublicKey, ipAddr, int(discoveryPortNum), int(discoveryPortNum)).URLv4(), nil
+	}
+	return "", stacktrace.NewError("Node '%v' has no node key in its files", serviceId)
+}
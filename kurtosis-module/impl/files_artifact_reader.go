@@ -0,0 +1,73 @@
+package impl
+
+import (
+	"github.com/kurtosis-tech/kurtosis-sdk/api/golang/core/lib/services"
+	"github.com/kurtosis-tech/stacktrace"
+	"github.com/sirupsen/logrus"
+	"path"
+	"sort"
+	"strings"
+)
+
+const (
+	filesArtifactReaderServiceIdPrefix = "files-artifact-reader-"
+	filesArtifactReaderMountpoint      = "/files-artifact"
+	// Keeps the reader running until it's removed, so that its files can be read through exec commands
+	filesArtifactReaderCommand = "tail -f /dev/null"
+
+	// What "find -print0" ends each listed path with, as a file name can hold any other character, newlines and spaces included
+	listedFilepathTerminator = "\x00"
+)
+
+// Returns the contents of the files at the root of the given files artifact by their name
+// The enclave can't send the contents of a files artifact back to the module, so they're read through a short-lived service
+// that mounts the files artifact
+func readFilesArtifact(enclaveCtx moduleEnclave, filesArtifactUuid services.FilesArtifactUUID) (map[string]string, error) {
+	serviceId := services.ServiceID(filesArtifactReaderServiceIdPrefix + string(filesArtifactUuid))
+	containerConfig := services.NewContainerConfigBuilder(
+		ethereumDockerImageName,
+	).WithEntrypointOverride(
+		getShellEntrypointArgs(filesArtifactReaderCommand),
+	).WithFiles(map[services.FilesArtifactUUID]string{
+		filesArtifactUuid: filesArtifactReaderMountpoint,
+	}).Build()
+	serviceCtx, err := enclaveCtx.AddService(serviceId, containerConfig)
+	if err != nil {
+		return nil, stacktrace.Propagate(err, "An error occurred adding the service that reads files artifact '%v'", filesArtifactUuid)
+	}
+	defer func() {
+		if err := enclaveCtx.RemoveService(serviceId, containerStopTimeoutSeconds); err != nil {
+			logrus.Warnf("An error occurred removing the service '%v' that read files artifact '%v':\n%v", serviceId, filesArtifactUuid, err)
+		}
+	}()
+
+	listFilesCmd := []string{"find", filesArtifactReaderMountpoint, "-mindepth", "1", "-maxdepth", "1", "-type", "f", "-print0"}
+	exitCode, listedFilepaths, err := serviceCtx.ExecCommand(listFilesCmd)
+	if err != nil {
+		return nil, stacktrace.Propagate(err, "An error occurred executing command '%+v' to list the files of files artifact '%v'", listFilesCmd, filesArtifactUuid)
+	}
+	if exitCode != execCommandSuccessExitCode {
+		return nil, stacktrace.NewError("Command '%+v' to list the files of files artifact '%v' exited with non-successful exit code '%v'", listFilesCmd, filesArtifactUuid, exitCode)
+	}
+	filepaths := []string{}
+	for _, filepath := range strings.Split(listedFilepaths, listedFilepathTerminator) {
+		if filepath != "" {
+			filepaths = append(filepaths, filepath)
+		}
+	}
+	sort.Strings(filepaths)
+
+	result := map[string]string{}
+	for _, filepath := range filepaths {
+		catFileCmd := []string{"cat", filepath}
+		exitCode, fileContents, err := serviceCtx.ExecCommand(catFileCmd)
+		if err != nil {
+			return nil, stacktrace.Propagate(err, "An error occurred executing command '%+v' to read file '%v' of files artifact '%v'", catFileCmd, filepath, filesArtifactUuid)
+		}
+		if exitCode != execCommandSuccessExitCode {
+			return nil, stacktrace.NewError("Command '%+v' to read file '%v' of files artifact '%v' exited with non-successful exit code '%v'", catFileCmd, filepath, filesArtifactUuid, exitCode)
+		}
+		result[path.Base(filepath)] = fileContents
+	}
+	return result, nil
+}
@@ -0,0 +1,219 @@
+package impl
+
+import (
+	"github.com/kurtosis-tech/stacktrace"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+const (
+	gethBinaryName     = "geth"
+	bootnodeBinaryName = "bootnode"
+	clefBinaryName     = "clef"
+
+	// Geth's tools take their flags with a single dash, and Geth itself with two
+	bootnodeFlagPrefix = "-"
+	gethFlagPrefix     = "--"
+	clefFlagPrefix     = "--"
+
+	shellCommandSeparator = " && "
+
+	// Docker image tags of the tools images are the Geth version with this prefix
+	gethToolsImageTagPrefix = "alltools-"
+)
+
+// Values made only of these characters mean the same to the shell quoted or not, so they're left unquoted to keep the
+// commands readable
+var shellSafeValueRegex = regexp.MustCompile(`^[A-Za-z0-9_./:,=@+-]+$`)
+
+// The module's allow-list of the flags it passes to each Geth version that it runs, rather than the full flag list of the
+// version, so that a flag the module wasn't checked to pass to a version fails before a container is started
+var supportedGethFlagsByVersion = map[string]map[string]bool{
+	"v1.10.8": newFlagSet(
+		"config",
+		"datadir",
+		"dev",
+		"dev.period",
+		"exec",
+		"http",
+		"http.addr",
+		"http.api",
+		"http.corsdomain",
+		"http.port",
+		"http.vhosts",
+		"keystore",
+		"mine",
+		"nat",
+		"nodekey",
+		"password",
+		"port",
+		"unlock",
+	),
+	"v1.10.26": newFlagSet(
+		"config",
+		"datadir",
+		"dev",
+		"dev.period",
+		"exec",
+		"http",
+		"http.addr",
+		"http.api",
+		"http.corsdomain",
+		"http.port",
+		"http.vhosts",
+		"keystore",
+		"mine",
+		"nat",
+		"nodekey",
+		"password",
+		"port",
+		"unlock",
+	),
+}
+
+var supportedBootnodeFlags = newFlagSet("addr", "nat", "nodekey")
+
+var supportedClefFlags = newFlagSet(
+	"chainid",
+	"configdir",
+	"http",
+	"http.addr",
+	"http.port",
+	"ipcdisable",
+	"keystore",
+	"nousb",
+	"rules",
+	"suppress-bootwarn",
+)
+
+// Builds the command line of one of the binaries in the Geth images, quoting every value for the shell
+// A flag the binary doesn't support is recorded and reported by build, so that calls can be chained
+type commandBuilder struct {
+	binaryName     string
+	flagPrefix     string
+	supportedFlags map[string]bool
+	args           []string
+	err            error
+}
+
+// Returns a builder for a command of the Geth in the given Docker image, optionally of one of its subcommands (e.g. "init")
+func newGethCommandBuilder(dockerImage string, subcommands ...string) *commandBuilder {
+	builder := &commandBuilder{
+		binaryName: gethBinaryName,
+		flagPrefix: gethFlagPrefix,
+		args:       append([]string{}, subcommands...),
+	}
+	gethVersion := getGethVersion(dockerImage)
+	supportedFlags, found := supportedGethFlagsByVersion[gethVersion]
+	if !found {
+		builder.err = stacktrace.NewError("No flags are known for Geth version '%v' of Docker image '%v'", gethVersion, dockerImage)
+		return builder
+	}
+	builder.supportedFlags = supportedFlags
+	return builder
+}
+
+func newBootnodeCommandBuilder() *commandBuilder {
+	return &commandBuilder{
+		binaryName:     bootnodeBinaryName,
+		flagPrefix:     bootnodeFlagPrefix,
+		supportedFlags: supportedBootnodeFlags,
+		args:           []string{},
+	}
+}
+
+// Clef only reads its global flags before the subcommand, so the subcommand is added with withArg after them
+func newClefCommandBuilder() *commandBuilder {
+	return &commandBuilder{
+		binaryName:     clefBinaryName,
+		flagPrefix:     clefFlagPrefix,
+		supportedFlags: supportedClefFlags,
+		args:           []string{},
+	}
+}
+
+func (builder *commandBuilder) withFlag(name string, value string) *commandBuilder {
+	if builder.checkFlag(name) {
+		builder.args = append(builder.args, builder.flagPrefix+name, quoteShellValue(value))
+	}
+	return builder
+}
+
+func (builder *commandBuilder) withBoolFlag(name string) *commandBuilder {
+	if builder.checkFlag(name) {
+		builder.args = append(builder.args, builder.flagPrefix+name)
+	}
+	return builder
+}
+
+func (builder *commandBuilder) withArg(value string) *commandBuilder {
+	builder.args = append(builder.args, quoteShellValue(value))
+	return builder
+}
+
+func (builder *commandBuilder) build() (string, error) {
+	if builder.err != nil {
+		return "", stacktrace.Propagate(builder.err, "An error occurred building the '%v' command", builder.binaryName)
+	}
+	return strings.Join(append([]string{builder.binaryName}, builder.args...), " "), nil
+}
+
+// Returns false if the flag isn't supported, keeping the first such error for build
+func (builder *commandBuilder) checkFlag(name string) bool {
+	if builder.err != nil {
+		return false
+	}
+	if !builder.supportedFlags[name] {
+		builder.err = stacktrace.NewError("Flag '%v' isn't supported by '%v'; supported flags are %v", name, builder.binaryName, getSortedFlags(builder.supportedFlags))
+		return false
+	}
+	return true
+}
+
+// Returns the Geth version in the tag of the given Docker image, e.g. "v1.10.8" for "ethereum/client-go:alltools-v1.10.8"
+func getGethVersion(dockerImage string) string {
+	tag := dockerImage[strings.LastIndex(dockerImage, ":")+1:]
+	return strings.TrimPrefix(tag, gethToolsImageTagPrefix)
+}
+
+// Quotes the value in single quotes unless it's made only of characters the shell doesn't interpret
+func quoteShellValue(value string) string {
+	if shellSafeValueRegex.MatchString(value) {
+		return value
+	}
+	return "'" + strings.ReplaceAll(value, "'", `'\''`) + "'"
+}
+
+// Returns the entrypoint that runs the given commands one after the other, stopping at the first that fails
+func getShellEntrypointArgs(commands ...string) []string {
+	nonEmptyCommands := []string{}
+	for _, command := range commands {
+		if command != "" {
+			nonEmptyCommands = append(nonEmptyCommands, command)
+		}
+	}
+	return []string{"/bin/sh", "-c", strings.Join(nonEmptyCommands, shellCommandSeparator)}
+}
+
+// Makes the given command read its stdin from the given file, for the commands that only take secrets from a prompt
+func withStdinFile(command string, filepath string) string {
+	return command + " < " + quoteShellValue(filepath)
+}
+
+func newFlagSet(flags ...string) map[string]bool {
+	result := map[string]bool{}
+	for _, flag := range flags {
+		result[flag] = true
+	}
+	return result
+}
+
+func getSortedFlags(flagSet map[string]bool) []string {
+	result := []string{}
+	for flag := range flagSet {
+		result = append(result, flag)
+	}
+	sort.Strings(result)
+	return result
+}
@@ -0,0 +1,159 @@
+package impl
+
+import (
+	"flag"
+	"io/ioutil"
+	"path"
+	"testing"
+
+	static_files_consts "github.com/kurtosis-tech/ethereum-kurtosis-module/kurtosis-module/static-files-consts"
+	"github.com/kurtosis-tech/kurtosis-sdk/api/golang/core/lib/services"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	gethCommandGoldenFilesDirpath = "testdata/geth-commands"
+	goldenFileExtension           = ".golden"
+	goldenFilePerms               = 0644
+
+	testNodeFilesArtifactUuid    = "node-files"
+	testAccountFilesArtifactUuid = "account-files"
+	testNetworkFilesArtifactUuid = "network-files"
+	testDevPeriodSeconds         = 5
+)
+
+var updateGoldenFiles = flag.Bool("update", false, "Rewrite the golden files with the rendered output")
+
+// The shell command that each node role is started with, which the golden file of the role records
+func TestNodeEntrypointCommands(t *testing.T) {
+	tests := []struct {
+		role             string
+		getEntrypointCmd func(t *testing.T) string
+	}{
+		{
+			role: "bootnode",
+			getEntrypointCmd: func(t *testing.T) string {
+				launchConfig := newTestLaunchConfig(t, signerBootnodeMode, 0)
+				containerConfig, err := getBootnodeContainerConfig(launchConfig, testNodeFilesArtifactUuid, "")
+				require.NoError(t, err)
+				return getEntrypointCommand(t, containerConfig)
+			},
+		},
+		{
+			role: "child",
+			getEntrypointCmd: func(t *testing.T) string {
+				launchConfig := newTestLaunchConfig(t, signerBootnodeMode, 0)
+				nodeSpec := &ModuleAPINodeSpec{ServiceID: getChildEthNodeServiceId(1)}
+				containerConfig, err := getEthNodeContainerConfig(launchConfig, nodeSpec, testNodeFilesArtifactUuid, "")
+				require.NoError(t, err)
+				return getEntrypointCommand(t, containerConfig)
+			},
+		},
+		{
+			role: "dev",
+			getEntrypointCmd: func(t *testing.T) string {
+				containerConfig, err := getDevNodeContainerConfig(testStaticFilesArtifactUuid, testNodeFilesArtifactUuid, testDevPeriodSeconds)
+				require.NoError(t, err)
+				return getEntrypointCommand(t, containerConfig)
+			},
+		},
+		{
+			// The second signer imports the account that the module derives for it, unlike the first one
+			role: "signer",
+			getEntrypointCmd: func(t *testing.T) string {
+				launchConfig := newTestLaunchConfig(t, discoveryOnlyBootnodeMode, 2)
+				nodeSpec := &ModuleAPINodeSpec{ServiceID: getSignerNodeServiceId(firstSignerNodeIdx + 1)}
+				containerConfig, err := getEthNodeContainerConfig(launchConfig, nodeSpec, testNodeFilesArtifactUuid, testAccountFilesArtifactUuid)
+				require.NoError(t, err)
+				return getEntrypointCommand(t, containerConfig)
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.role, func(t *testing.T) {
+			entrypointCmd := test.getEntrypointCmd(t)
+			goldenFilepath := path.Join(gethCommandGoldenFilesDirpath, test.role+goldenFileExtension)
+			if *updateGoldenFiles {
+				require.NoError(t, ioutil.WriteFile(goldenFilepath, []byte(entrypointCmd+"\n"), goldenFilePerms))
+			}
+			expectedEntrypointCmd, err := ioutil.ReadFile(goldenFilepath)
+			require.NoError(t, err, "Golden file '%v' couldn't be read; run the test with -update to write it", goldenFilepath)
+			require.Equal(t, string(expectedEntrypointCmd), entrypointCmd+"\n")
+		})
+	}
+}
+
+func TestNewGethCommandBuilder_FlagsByVersion(t *testing.T) {
+	tests := []struct {
+		name          string
+		dockerImage   string
+		flagName      string
+		isErrExpected bool
+	}{
+		{
+			name:        "common flag on v1.10.8",
+			dockerImage: ethereumDockerImageName,
+			flagName:    "datadir",
+		},
+		{
+			name:        "common flag on v1.10.26",
+			dockerImage: authRpcEthereumDockerImageName,
+			flagName:    "datadir",
+		},
+		{
+			// The authenticated RPC server is configured through the config file rather than its flags
+			name:          "flag of v1.10.26 that the module doesn't pass",
+			dockerImage:   authRpcEthereumDockerImageName,
+			flagName:      "authrpc.port",
+			isErrExpected: true,
+		},
+		{
+			name:          "unknown version",
+			dockerImage:   "ethereum/client-go:v1.9.0",
+			flagName:      "datadir",
+			isErrExpected: true,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := newGethCommandBuilder(test.dockerImage).withFlag(test.flagName, "value").build()
+			if test.isErrExpected {
+				require.Error(t, err)
+			} else {
+				require.NoError(t, err)
+			}
+		})
+	}
+}
+
+// Returns the launch config of a network started with the static files built into the module and the given bootnode mode
+func newTestLaunchConfig(t *testing.T, bootnodeMode string, signerCount uint32) *nodeLaunchConfig {
+	fileContents, err := getEmbeddedStaticFileContents()
+	require.NoError(t, err)
+	staticFiles, err := newStaticFiles(testStaticFilesArtifactUuid, fileContents)
+	require.NoError(t, err)
+	resourceDefaults, err := applyDefaultsAndValidateResourceDefaults(nil)
+	require.NoError(t, err)
+
+	launchConfig := &nodeLaunchConfig{
+		dockerImage:      ethereumDockerImageName,
+		genesisFilepath:  getMountedPathOnNodeContainer(static_files_consts.GenesisStaticFileName),
+		staticFiles:      staticFiles,
+		peeringStrategy:  adminAddPeerPeeringStrategy,
+		bootnodeMode:     bootnodeMode,
+		signerCount:      signerCount,
+		resourceDefaults: resourceDefaults,
+		signerBackend:    keystoreSignerBackend,
+	}
+	if isGenesisRendered(launchConfig) {
+		launchConfig.networkFilesArtifactUuid = testNetworkFilesArtifactUuid
+		launchConfig.genesisFilepath = path.Join(networkFilesMountpointOnNodes, static_files_consts.GenesisStaticFileName)
+	}
+	return launchConfig
+}
+
+func getEntrypointCommand(t *testing.T, containerConfig *services.ContainerConfig) string {
+	entrypointArgs := containerConfig.GetEntrypointOverrideArgs()
+	require.Len(t, entrypointArgs, 3)
+	return entrypointArgs[2]
+}
@@ -0,0 +1,176 @@
+package impl
+
+import (
+	"fmt"
+	"github.com/kurtosis-tech/kurtosis-sdk/api/golang/core/lib/services"
+	"github.com/kurtosis-tech/stacktrace"
+	"path"
+	"strconv"
+	"strings"
+)
+
+const (
+	gethConfigFilename = "config.toml"
+
+	gethDataDirpath = "data"
+
+	staticNodesConfigKey  = "StaticNodes"
+	trustedNodesConfigKey = "TrustedNodes"
+)
+
+var httpApiModules = []string{"admin", "eth", "net", "web3", "miner", "personal", "txpool", "debug"}
+
+// A table of Geth's TOML config, whose entries are written in the order they were added
+type gethConfigTable struct {
+	name    string
+	entries []*gethConfigEntry
+}
+
+type gethConfigEntry struct {
+	key string
+	// One of string, []string, bool, int or uint64
+	value interface{}
+}
+
+func (table *gethConfigTable) set(key string, value interface{}) {
+	table.entries = append(table.entries, &gethConfigEntry{key: key, value: value})
+}
+
+// Renders the config that the given Geth node starts with through --config; the node spec is nil for the bootnode, which runs
+// with Geth's defaults
+func renderGethConfig(
+	serviceId services.ServiceID,
+	launchConfig *nodeLaunchConfig,
+	nodeSpec *ModuleAPINodeSpec,
+	bootnodeEnrs []string,
+	peerEnodes []string,
+) (string, error) {
+	ethTable := &gethConfigTable{name: "Eth"}
+	ethTable.set("NetworkId", uint64(ethNetworkId))
+	if nodeSpec != nil {
+		ethTable.set("SyncMode", nodeSpec.SyncMode)
+		ethTable.set("NoPruning", nodeSpec.GcMode == archiveGcMode)
+	}
+
+	isSigner := serviceId == bootnodeServiceID || isSignerNodeServiceId(serviceId)
+	nodeTable := &gethConfigTable{name: "Node"}
+	nodeTable.set("DataDir", gethDataDirpath)
+	// The nodes of the "hardened" RPC profile only serve the JWT-authenticated endpoint
+	hardenedRpc := launchConfig.hardenedRpc
+	if hardenedRpc == nil {
+		nodeTable.set("HTTPHost", "0.0.0.0")
+		nodeTable.set("HTTPPort", int(rpcPortNum))
+		nodeTable.set("HTTPCors", []string{"*"})
+		nodeTable.set("HTTPVirtualHosts", []string{"*"})
+		nodeTable.set("HTTPModules", httpApiModules)
+	}
+	if isAuthRpcEnabled(launchConfig) {
+		authVirtualHosts := []string{"*"}
+		if hardenedRpc != nil {
+			authVirtualHosts = hardenedRpc.VirtualHosts
+		}
+		nodeTable.set("AuthAddr", "0.0.0.0")
+		nodeTable.set("AuthPort", int(authRpcPortNum))
+		nodeTable.set("AuthVirtualHosts", authVirtualHosts)
+		nodeTable.set("JWTSecret", path.Join(engineApiJwtSecretMountpointOnNodes, engineApiJwtSecretFilename))
+	}
+
+	p2pTable := &gethConfigTable{name: "Node.P2P"}
+	p2pTable.set("ListenAddr", fmt.Sprintf(":%v", discoveryPortNum))
+	// An empty list keeps Geth from falling back to the mainnet bootnodes
+	p2pTable.set("BootstrapNodes", bootnodeEnrs)
+	// The bootnode is what the others discover the network through, so it doesn't look for the tree itself
+	if launchConfig.nodeDiscovery == dnsNodeDiscovery && serviceId != bootnodeServiceID {
+		treeUrl, err := getDnsTreeUrl(launchConfig.keySeed)
+		if err != nil {
+			return "", stacktrace.Propagate(err, "An error occurred getting the URL of the DNS discovery tree")
+		}
+		ethTable.set("EthDiscoveryURLs", []string{treeUrl})
+	}
+	if peerListConfigKey := getPeerListConfigKey(launchConfig.peeringStrategy); peerListConfigKey != "" {
+		p2pTable.set(peerListConfigKey, peerEnodes)
+	}
+
+	tables := []*gethConfigTable{ethTable}
+	if isSigner {
+		signerAccountAddr, err := getSignerAccountAddress(launchConfig, serviceId)
+		if err != nil {
+			return "", stacktrace.Propagate(err, "An error occurred getting the account address of signer '%v'", serviceId)
+		}
+		if isAccountUnlockedOnNode(launchConfig, serviceId) {
+			// Only the signers whose account is in the static files read it from there, and the others import it into the datadir
+			if usesStaticSignerAccount(launchConfig, serviceId) {
+				nodeTable.set("KeyStoreDir", getMountedPathOnNodeContainer(""))
+			}
+			nodeTable.set("InsecureUnlockAllowed", true)
+		} else {
+			clefUrl, err := getClefUrl(launchConfig, serviceId)
+			if err != nil {
+				return "", stacktrace.Propagate(err, "An error occurred getting the URL of the Clef of signer '%v'", serviceId)
+			}
+			nodeTable.set("ExternalSigner", clefUrl)
+		}
+
+		minerTable := &gethConfigTable{name: "Eth.Miner"}
+		minerTable.set("Etherbase", signerAccountAddr)
+		tables = append(tables, minerTable)
+	}
+	tables = append(tables, nodeTable, p2pTable)
+
+	renderedTables := []string{}
+	for _, table := range tables {
+		renderedTable, err := renderGethConfigTable(table)
+		if err != nil {
+			return "", stacktrace.Propagate(err, "An error occurred rendering table '%v' of the config of node '%v'", table.name, serviceId)
+		}
+		renderedTables = append(renderedTables, renderedTable)
+	}
+	return strings.Join(renderedTables, "\n"), nil
+}
+
+func renderGethConfigTable(table *gethConfigTable) (string, error) {
+	lines := []string{fmt.Sprintf("[%v]", table.name)}
+	for _, entry := range table.entries {
+		renderedValue, err := renderGethConfigValue(entry.value)
+		if err != nil {
+			return "", stacktrace.Propagate(err, "An error occurred rendering the value of '%v'", entry.key)
+		}
+		lines = append(lines, fmt.Sprintf("%v = %v", entry.key, renderedValue))
+	}
+	return strings.Join(lines, "\n") + "\n", nil
+}
+
+func renderGethConfigValue(value interface{}) (string, error) {
+	switch typedValue := value.(type) {
+	case string:
+		// The values are plain ASCII, for which Go's quoting is the same as TOML's
+		return strconv.Quote(typedValue), nil
+	case []string:
+		quotedElems := []string{}
+		for _, elem := range typedValue {
+			quotedElems = append(quotedElems, strconv.Quote(elem))
+		}
+		return "[" + strings.Join(quotedElems, ", ") + "]", nil
+	case bool:
+		return strconv.FormatBool(typedValue), nil
+	case int:
+		return strconv.Itoa(typedValue), nil
+	case uint64:
+		return strconv.FormatUint(typedValue, 10), nil
+	default:
+		return "", stacktrace.NewError("Config value '%v' has unsupported type %T; this is a bug with this module", value, value)
+	}
+}
+
+// Returns the P2P config field that holds the peer list of the peering strategy, or an empty string if the peering
+// strategy doesn't use a peer list
+func getPeerListConfigKey(peeringStrategy string) string {
+	switch peeringStrategy {
+	case staticNodesPeeringStrategy:
+		return staticNodesConfigKey
+	case trustedNodesPeeringStrategy:
+		return trustedNodesConfigKey
+	default:
+		return ""
+	}
+}
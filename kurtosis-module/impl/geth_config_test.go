@@ -0,0 +1,177 @@
+package impl
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/kurtosis-tech/kurtosis-sdk/api/golang/core/lib/services"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	testBootnodeEnr = "enr:-test-bootnode-record"
+	testPeerEnode   = "enode://0000@10.0.0.3:30303"
+	testClefIpAddr  = "10.0.0.4"
+)
+
+var (
+	gethConfigTableHeaderRegex = regexp.MustCompile(`^\[([A-Za-z0-9]+(\.[A-Za-z0-9]+)*)\]$`)
+	gethConfigEntryRegex       = regexp.MustCompile(`^([A-Za-z]+) = (.+)$`)
+)
+
+// The rendered config must be TOML that Geth can read, with the values that the node is meant to start with
+func TestRenderGethConfig(t *testing.T) {
+	tests := []struct {
+		name               string
+		serviceId          services.ServiceID
+		configureLaunch    func(launchConfig *nodeLaunchConfig)
+		nodeSpec           *ModuleAPINodeSpec
+		expectedEntries    map[string]map[string]interface{}
+		notExpectedEntries map[string][]string
+	}{
+		{
+			name:      "signer bootnode",
+			serviceId: bootnodeServiceID,
+			expectedEntries: map[string]map[string]interface{}{
+				"Eth":      {"NetworkId": int64(ethNetworkId)},
+				"Node":     {"HTTPPort": int64(rpcPortNum), "InsecureUnlockAllowed": true},
+				"Node.P2P": {"BootstrapNodes": []string{testBootnodeEnr}},
+			},
+			notExpectedEntries: map[string][]string{
+				"Eth": {"SyncMode", "EthDiscoveryURLs"},
+			},
+		},
+		{
+			name:      "archive child node with static nodes",
+			serviceId: getChildEthNodeServiceId(1),
+			configureLaunch: func(launchConfig *nodeLaunchConfig) {
+				launchConfig.peeringStrategy = staticNodesPeeringStrategy
+			},
+			nodeSpec: &ModuleAPINodeSpec{GcMode: archiveGcMode, SyncMode: fullSyncMode},
+			expectedEntries: map[string]map[string]interface{}{
+				"Eth":      {"SyncMode": fullSyncMode, "NoPruning": true},
+				"Node.P2P": {"StaticNodes": []string{testPeerEnode}},
+			},
+			notExpectedEntries: map[string][]string{
+				"Eth.Miner": nil,
+				"Node.P2P":  {trustedNodesConfigKey},
+			},
+		},
+		{
+			name:      "child node with DNS discovery",
+			serviceId: getChildEthNodeServiceId(1),
+			configureLaunch: func(launchConfig *nodeLaunchConfig) {
+				launchConfig.nodeDiscovery = dnsNodeDiscovery
+				launchConfig.keySeed = testKeySeed
+			},
+			nodeSpec: &ModuleAPINodeSpec{GcMode: archiveGcMode, SyncMode: fullSyncMode},
+			notExpectedEntries: map[string][]string{
+				"Node.P2P": {staticNodesConfigKey, trustedNodesConfigKey},
+			},
+		},
+		{
+			name:      "hardened signer with a Clef",
+			serviceId: getSignerNodeServiceId(1),
+			configureLaunch: func(launchConfig *nodeLaunchConfig) {
+				launchConfig.bootnodeMode = discoveryOnlyBootnodeMode
+				launchConfig.signerCount = 1
+				launchConfig.signerBackend = clefSignerBackend
+				launchConfig.hardenedRpc = &ModuleAPIHardenedRpcArgs{VirtualHosts: defaultHardenedRpcVirtualHosts}
+				launchConfig.clefIpAddrs = map[services.ServiceID]string{getSignerNodeServiceId(1): testClefIpAddr}
+			},
+			nodeSpec: &ModuleAPINodeSpec{GcMode: archiveGcMode, SyncMode: fullSyncMode},
+			expectedEntries: map[string]map[string]interface{}{
+				"Node": {
+					"AuthPort":         int64(authRpcPortNum),
+					"AuthVirtualHosts": defaultHardenedRpcVirtualHosts,
+					"ExternalSigner":   fmt.Sprintf("http://%v:%v", testClefIpAddr, clefHttpPortNum),
+				},
+			},
+			notExpectedEntries: map[string][]string{
+				"Node": {"HTTPHost", "HTTPPort", "InsecureUnlockAllowed"},
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			launchConfig := newTestLaunchConfig(t, signerBootnodeMode, 0)
+			if test.configureLaunch != nil {
+				test.configureLaunch(launchConfig)
+			}
+			config, err := renderGethConfig(test.serviceId, launchConfig, test.nodeSpec, []string{testBootnodeEnr}, []string{testPeerEnode})
+			require.NoError(t, err)
+			tables := parseTestGethConfig(t, config)
+
+			for tableName, expectedTable := range test.expectedEntries {
+				require.Contains(t, tables, tableName)
+				for key, expectedValue := range expectedTable {
+					require.Equal(t, expectedValue, tables[tableName][key], "Entry '%v' of table '%v' has the wrong value", key, tableName)
+				}
+			}
+			for tableName, keys := range test.notExpectedEntries {
+				if keys == nil {
+					require.NotContains(t, tables, tableName)
+					continue
+				}
+				for _, key := range keys {
+					require.NotContains(t, tables[tableName], key, "Table '%v' has entry '%v'", tableName, key)
+				}
+			}
+			if launchConfig.nodeDiscovery == dnsNodeDiscovery {
+				treeUrl, err := getDnsTreeUrl(launchConfig.keySeed)
+				require.NoError(t, err)
+				require.Equal(t, []string{treeUrl}, tables["Eth"]["EthDiscoveryURLs"])
+			}
+		})
+	}
+}
+
+// Parses the subset of TOML that the config is rendered in, being tables of keys whose values are strings, string arrays,
+// booleans or integers, failing the test on anything else, including a table or key that's defined twice
+func parseTestGethConfig(t *testing.T, config string) map[string]map[string]interface{} {
+	tables := map[string]map[string]interface{}{}
+	var currentTable map[string]interface{}
+	for lineIdx, line := range strings.Split(config, "\n") {
+		if line == "" {
+			continue
+		}
+		if headerMatches := gethConfigTableHeaderRegex.FindStringSubmatch(line); headerMatches != nil {
+			tableName := headerMatches[1]
+			require.NotContains(t, tables, tableName, "Table '%v' is defined twice", tableName)
+			currentTable = map[string]interface{}{}
+			tables[tableName] = currentTable
+			continue
+		}
+		entryMatches := gethConfigEntryRegex.FindStringSubmatch(line)
+		require.NotNil(t, entryMatches, "Line %v of the config isn't a table header or an entry: %v", lineIdx+1, line)
+		require.NotNil(t, currentTable, "Line %v of the config is an entry outside of a table", lineIdx+1)
+		key := entryMatches[1]
+		require.NotContains(t, currentTable, key, "Key '%v' is defined twice in the same table", key)
+		currentTable[key] = parseTestGethConfigValue(t, entryMatches[2])
+	}
+	return tables
+}
+
+func parseTestGethConfigValue(t *testing.T, renderedValue string) interface{} {
+	switch {
+	case strings.HasPrefix(renderedValue, `"`):
+		value, err := strconv.Unquote(renderedValue)
+		require.NoError(t, err, "Value '%v' isn't a valid string", renderedValue)
+		return value
+	case strings.HasPrefix(renderedValue, "["):
+		// An array of basic strings in TOML is also a JSON array of strings
+		value := []string{}
+		require.NoError(t, json.Unmarshal([]byte(renderedValue), &value), "Value '%v' isn't a valid string array", renderedValue)
+		return value
+	case renderedValue == "true" || renderedValue == "false":
+		return renderedValue == "true"
+	default:
+		value, err := strconv.ParseInt(renderedValue, 10, 64)
+		require.NoError(t, err, "Value '%v' isn't a string, string array, boolean or integer", renderedValue)
+		return value
+	}
+}
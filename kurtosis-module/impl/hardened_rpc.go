@@ -0,0 +1,230 @@
+package impl
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"github.com/kurtosis-tech/kurtosis-sdk/api/golang/core/lib/services"
+	"github.com/kurtosis-tech/stacktrace"
+	"github.com/sirupsen/logrus"
+	"path"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	hardenedRpcEnvVar = "ETHEREUM_KURTOSIS_MODULE_HARDENED_RPC"
+
+	gethIpcFilename = "geth.ipc"
+
+	// Calls the IPC socket of the node as a raw JSON-RPC request, printing the response as a quoted string
+	ipcRpcCallScriptFormat = "JSON.stringify(web3.currentProvider.send(%v))"
+)
+
+// Geth's own default, which only lets through the requests for a host name of the machine
+var defaultHardenedRpcVirtualHosts = []string{"localhost"}
+
+// The namespaces that Geth serves on the JWT-authenticated endpoint, which is the only endpoint in the "hardened" RPC profile
+var authRpcNamespaces = []string{"eth", "engine"}
+
+func applyDefaultsAndValidateRpcParams(params *ModuleAPIExecuteArgs) error {
+	switch params.RpcProfile {
+	case "":
+		params.RpcProfile = openRpcProfile
+	case openRpcProfile, hardenedRpcProfile:
+	default:
+		return stacktrace.NewError(
+			"Unrecognized RPC profile '%v'; valid values are '%v' and '%v'",
+			params.RpcProfile,
+			openRpcProfile,
+			hardenedRpcProfile,
+		)
+	}
+
+	if params.RpcProfile != hardenedRpcProfile {
+		if params.HardenedRpc != nil {
+			return stacktrace.NewError("Hardened RPC settings were provided, but the RPC profile is '%v'", params.RpcProfile)
+		}
+		return nil
+	}
+
+	// The block driver holds the JWT secret of the Engine API, which a network started by a later instance of the module
+	// couldn't tell apart from the secret of the hardened RPC profile
+	if params.BlockProduction != cliqueBlockProduction {
+		return stacktrace.NewError("RPC profile '%v' is only supported with '%v' block production", hardenedRpcProfile, cliqueBlockProduction)
+	}
+	if params.HardenedRpc == nil {
+		params.HardenedRpc = &ModuleAPIHardenedRpcArgs{}
+	}
+	if params.HardenedRpc.VirtualHosts == nil {
+		params.HardenedRpc.VirtualHosts = append([]string{}, defaultHardenedRpcVirtualHosts...)
+	}
+	return nil
+}
+
+func isAuthRpcEnabled(launchConfig *nodeLaunchConfig) bool {
+	return launchConfig.isEngineApiEnabled || launchConfig.hardenedRpc != nil
+}
+
+func getRpcProfile(launchConfig *nodeLaunchConfig) string {
+	if launchConfig.hardenedRpc != nil {
+		return hardenedRpcProfile
+	}
+	return openRpcProfile
+}
+
+func isHardenedRpcMatching(launchConfig *nodeLaunchConfig, params *ModuleAPIExecuteArgs) bool {
+	return getRpcProfile(launchConfig) == params.RpcProfile && reflect.DeepEqual(launchConfig.hardenedRpc, params.HardenedRpc)
+}
+
+// Records the hardened RPC settings in the given environment variables of a node, if the network has any
+func setHardenedRpcEnvVar(envVars map[string]string, launchConfig *nodeLaunchConfig) error {
+	if launchConfig.hardenedRpc == nil {
+		return nil
+	}
+	serializedHardenedRpc, err := json.Marshal(launchConfig.hardenedRpc)
+	if err != nil {
+		return stacktrace.Propagate(err, "An error occurred serializing the hardened RPC settings")
+	}
+	envVars[hardenedRpcEnvVar] = string(serializedHardenedRpc)
+	return nil
+}
+
+// Reads the hardened RPC settings that the given node was started with, or nil if it was started with the "open" RPC profile
+func getHardenedRpcArgs(serviceCtx *services.ServiceContext) (*ModuleAPIHardenedRpcArgs, error) {
+	exitCode, serializedHardenedRpc, err := serviceCtx.ExecCommand([]string{"printenv", hardenedRpcEnvVar})
+	if err != nil {
+		return nil, stacktrace.Propagate(err, "An error occurred reading the hardened RPC settings of node '%v'", serviceCtx.GetServiceID())
+	}
+	if exitCode != execCommandSuccessExitCode {
+		return nil, nil
+	}
+	hardenedRpc := &ModuleAPIHardenedRpcArgs{}
+	if err := json.Unmarshal([]byte(serializedHardenedRpc), hardenedRpc); err != nil {
+		return nil, stacktrace.Propagate(err, "An error occurred deserializing hardened RPC settings '%v'", serializedHardenedRpc)
+	}
+	return hardenedRpc, nil
+}
+
+// Reads the JWT secret mounted on the given Geth node
+func getRpcJwtSecret(serviceCtx *services.ServiceContext) ([]byte, error) {
+	jwtSecretFilepath := path.Join(engineApiJwtSecretMountpointOnNodes, engineApiJwtSecretFilename)
+	catJwtSecretCmd := []string{"cat", jwtSecretFilepath}
+	exitCode, hexJwtSecret, err := serviceCtx.ExecCommand(catJwtSecretCmd)
+	if err != nil {
+		return nil, stacktrace.Propagate(err, "An error occurred executing command '%+v' to read the JWT secret of node '%v'", catJwtSecretCmd, serviceCtx.GetServiceID())
+	}
+	if exitCode != execCommandSuccessExitCode {
+		return nil, stacktrace.NewError("Command '%+v' to read the JWT secret of node '%v' exited with non-successful exit code '%v'", catJwtSecretCmd, serviceCtx.GetServiceID(), exitCode)
+	}
+	jwtSecret, err := hex.DecodeString(strings.TrimPrefix(strings.TrimSpace(hexJwtSecret), hexPrefix))
+	if err != nil {
+		return nil, stacktrace.Propagate(err, "An error occurred decoding the JWT secret of node '%v'", serviceCtx.GetServiceID())
+	}
+	registerSecret(hex.EncodeToString(jwtSecret))
+	return jwtSecret, nil
+}
+
+// Returns the secret that the module signs its calls to the given node with, or nil if the node serves its RPC API openly
+func getNodeRpcJwtSecret(serviceCtx *services.ServiceContext) ([]byte, error) {
+	hardenedRpc, err := getHardenedRpcArgs(serviceCtx)
+	if err != nil {
+		return nil, stacktrace.Propagate(err, "An error occurred getting the hardened RPC settings of node '%v'", serviceCtx.GetServiceID())
+	}
+	if hardenedRpc == nil {
+		return nil, nil
+	}
+	jwtSecret, err := getRpcJwtSecret(serviceCtx)
+	if err != nil {
+		return nil, stacktrace.Propagate(err, "An error occurred getting the JWT secret of node '%v'", serviceCtx.GetServiceID())
+	}
+	return jwtSecret, nil
+}
+
+// Sends an RPC call to the given node, through its open HTTP endpoint if no JWT secret is given, and otherwise through the
+// JWT-authenticated endpoint or, for the namespaces it doesn't serve, the IPC socket of the node
+func sendNodeRpcCall(transport rpcTransport, serviceCtx *services.ServiceContext, rpcJwtSecret []byte, rpcJsonString string, targetStruct interface{}) error {
+	if rpcJwtSecret == nil {
+		return sendRpcCall(transport, serviceCtx.GetPrivateIPAddress(), rpcJsonString, targetStruct)
+	}
+	rpcRequest := new(EthAPIRequest)
+	if err := json.Unmarshal([]byte(rpcJsonString), rpcRequest); err != nil {
+		return stacktrace.Propagate(err, "An error occurred parsing RPC call '%v'", rpcJsonString)
+	}
+	namespace := strings.SplitN(rpcRequest.Method, "_", 2)[0]
+	for _, authRpcNamespace := range authRpcNamespaces {
+		if namespace == authRpcNamespace {
+			return sendAuthRpcCall(transport, serviceCtx.GetPrivateIPAddress(), rpcJwtSecret, rpcJsonString, targetStruct)
+		}
+	}
+	return sendIpcRpcCall(serviceCtx, rpcJsonString, targetStruct)
+}
+
+func sendIpcRpcCall(serviceCtx commandExecutor, rpcJsonString string, targetStruct interface{}) error {
+	attachCommand, err := newGethCommandBuilder(
+		authRpcEthereumDockerImageName,
+		"attach",
+	).withFlag(
+		"exec", fmt.Sprintf(ipcRpcCallScriptFormat, rpcJsonString),
+	).withArg(
+		path.Join(gethDataDirpath, gethIpcFilename),
+	).build()
+	if err != nil {
+		return stacktrace.Propagate(err, "An error occurred building the command that sends RPC call '%v' over IPC", rpcJsonString)
+	}
+
+	logrus.Debugf("Sending RPC call to node '%v' over IPC with JSON body '%v'...", serviceCtx.GetServiceID(), rpcJsonString)
+
+	exitCode, logOutput, err := serviceCtx.ExecCommand(getShellEntrypointArgs(attachCommand))
+	if err != nil {
+		return stacktrace.Propagate(err, "Executing command '%v' on node '%v' returned an error", attachCommand, serviceCtx.GetServiceID())
+	}
+	if exitCode != execCommandSuccessExitCode {
+		return stacktrace.NewError(
+			"Executing command '%v' on node '%v' returned non-%v exit code '%v' with the following logs:\n%v",
+			attachCommand,
+			serviceCtx.GetServiceID(),
+			execCommandSuccessExitCode,
+			exitCode,
+			logOutput,
+		)
+	}
+
+	// The console prints the response last, as a string in Go's quoting
+	outputLines := strings.Split(strings.TrimSpace(logOutput), "\n")
+	responseJson, err := strconv.Unquote(strings.TrimSpace(outputLines[len(outputLines)-1]))
+	if err != nil {
+		return stacktrace.Propagate(err, "An error occurred reading the response to IPC RPC call '%v' from output:\n%v", rpcJsonString, logOutput)
+	}
+	logrus.Tracef("Response for IPC RPC call %v: %v", rpcJsonString, responseJson)
+	if err := json.Unmarshal([]byte(responseJson), targetStruct); err != nil {
+		return stacktrace.Propagate(err, "Error parsing geth node IPC response into target struct.")
+	}
+	return nil
+}
+
+// Waits for the JWT-authenticated endpoint of the given node to answer, the way the open endpoint is waited on otherwise
+func waitForAuthRpcAvailability(enclaveCtx serviceWaiter, transport rpcTransport, serviceId services.ServiceID, rpcJwtSecret []byte) error {
+	serviceCtx, err := enclaveCtx.GetServiceContext(serviceId)
+	if err != nil {
+		return stacktrace.Propagate(err, "An error occurred getting the service context of node '%v'", serviceId)
+	}
+	time.Sleep(waitEndpointInitialDelayMilliseconds * time.Millisecond)
+	var callErr error
+	for i := 0; i < waitEndpointRetries; i++ {
+		blockNumberResponse := new(EthAPIBlockNumberResponse)
+		if callErr = sendAuthRpcCall(transport, serviceCtx.GetPrivateIPAddress(), rpcJwtSecret, blockNumberRpcCall, blockNumberResponse); callErr == nil {
+			return nil
+		}
+		time.Sleep(waitEndpointRetriesDelayMilliseconds * time.Millisecond)
+	}
+	return stacktrace.Propagate(
+		callErr,
+		"The JWT-authenticated endpoint of node '%v' didn't answer, even after %v retries with %vms between retries",
+		serviceId,
+		waitEndpointRetries,
+		waitEndpointRetriesDelayMilliseconds,
+	)
+}
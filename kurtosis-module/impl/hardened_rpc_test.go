@@ -0,0 +1,86 @@
+package impl
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestApplyDefaultsAndValidateParams_HardenedRpc(t *testing.T) {
+	tests := []struct {
+		name                  string
+		params                *ModuleAPIExecuteArgs
+		expectedSignerBackend string
+		isErrExpected         bool
+	}{
+		{
+			name:                  "hardened without a signer backend",
+			params:                &ModuleAPIExecuteArgs{RpcProfile: hardenedRpcProfile},
+			expectedSignerBackend: clefSignerBackend,
+		},
+		{
+			name:                  "hardened with clef signers",
+			params:                &ModuleAPIExecuteArgs{RpcProfile: hardenedRpcProfile, SignerBackend: clefSignerBackend},
+			expectedSignerBackend: clefSignerBackend,
+		},
+		{
+			name:          "hardened with keystore signers",
+			params:        &ModuleAPIExecuteArgs{RpcProfile: hardenedRpcProfile, SignerBackend: keystoreSignerBackend},
+			isErrExpected: true,
+		},
+		{
+			name:          "hardened with Engine API block production",
+			params:        &ModuleAPIExecuteArgs{RpcProfile: hardenedRpcProfile, BlockProduction: engineApiDriverBlockProduction},
+			isErrExpected: true,
+		},
+		{
+			name:          "open with hardened settings",
+			params:        &ModuleAPIExecuteArgs{HardenedRpc: &ModuleAPIHardenedRpcArgs{}},
+			isErrExpected: true,
+		},
+		{
+			name:                  "open without a signer backend",
+			params:                &ModuleAPIExecuteArgs{},
+			expectedSignerBackend: keystoreSignerBackend,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := applyDefaultsAndValidateParams(test.params)
+			if test.isErrExpected {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, test.expectedSignerBackend, test.params.SignerBackend)
+		})
+	}
+}
+
+func TestGetUsedPorts_RpcProfiles(t *testing.T) {
+	tests := []struct {
+		name            string
+		hardenedRpc     *ModuleAPIHardenedRpcArgs
+		expectedPortIds []string
+	}{
+		{
+			name:            "open",
+			expectedPortIds: []string{rpcPortId, wsPortId, tcpDiscoveryPortId, udpDiscoveryPortId},
+		},
+		{
+			name:            "hardened",
+			hardenedRpc:     &ModuleAPIHardenedRpcArgs{VirtualHosts: defaultHardenedRpcVirtualHosts},
+			expectedPortIds: []string{authRpcPortId, tcpDiscoveryPortId, udpDiscoveryPortId},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			launchConfig := &nodeLaunchConfig{hardenedRpc: test.hardenedRpc}
+			portIds := []string{}
+			for portId := range getUsedPorts(launchConfig) {
+				portIds = append(portIds, portId)
+			}
+			require.ElementsMatch(t, test.expectedPortIds, portIds)
+		})
+	}
+}
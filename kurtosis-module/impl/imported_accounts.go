@@ -0,0 +1,217 @@
+package impl
+
+import (
+	"crypto/ecdsa"
+	"encoding/hex"
+	"encoding/json"
+	"github.com/ethereum/go-ethereum/accounts/keystore"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/kurtosis-tech/kurtosis-sdk/api/golang/core/lib/services"
+	"github.com/kurtosis-tech/stacktrace"
+	"math/big"
+	"path"
+	"sort"
+	"strings"
+)
+
+const (
+	// 1000 ether
+	defaultGenesisBalanceWei = "1000000000000000000000"
+
+	// Written to the account files of the bootnode, so a later instance of the module can start nodes with the same accounts
+	importedAccountsFilename = "imported-accounts.json"
+
+	genesisAllocField        = "alloc"
+	genesisAllocBalanceField = "balance"
+)
+
+// Checks what can be checked of the imported accounts before reading the keystores, which happens once the enclave is
+// reached
+func applyDefaultsAndValidateImportedAccounts(params *ModuleAPIExecuteArgs) error {
+	importedAccounts := params.ImportedAccounts
+	if importedAccounts == nil {
+		return nil
+	}
+	// Once blocks come from the Engine API, the signers don't seal with their accounts
+	if params.BlockProduction != cliqueBlockProduction {
+		return stacktrace.NewError("Accounts can only be imported with '%v' block production", cliqueBlockProduction)
+	}
+	if len(importedAccounts.PrivateKeys) == 0 && importedAccounts.KeystoresFilesArtifactUUID == "" {
+		return stacktrace.NewError("No private keys or keystores files artifact were provided to import accounts from")
+	}
+	if importedAccounts.KeystoresFilesArtifactUUID == "" && (importedAccounts.KeystorePassword != "" || len(importedAccounts.KeystorePasswords) != 0) {
+		return stacktrace.NewError("Keystore passwords were provided, but no keystores files artifact")
+	}
+	for i, privateKey := range importedAccounts.PrivateKeys {
+		if _, err := parseImportedPrivateKey(privateKey); err != nil {
+			return stacktrace.Propagate(err, "Imported private key #%v was invalid", i+1)
+		}
+	}
+	if importedAccounts.GenesisBalanceWei == "" {
+		importedAccounts.GenesisBalanceWei = defaultGenesisBalanceWei
+	}
+	if balance, isValid := new(big.Int).SetString(importedAccounts.GenesisBalanceWei, 10); !isValid || balance.Sign() < 0 {
+		return stacktrace.NewError("Genesis balance '%v' isn't a non-negative decimal number of wei", importedAccounts.GenesisBalanceWei)
+	}
+	return nil
+}
+
+// Returns the keys of the imported accounts, the raw private keys first, followed by the accounts of the keystores in the
+// order of their file names, checking that there's one for every signer of the network
+func getImportedAccountKeys(
+	enclaveCtx moduleEnclave,
+	importedAccounts *ModuleAPIImportedAccountsArgs,
+	numSigners int,
+) ([]*ecdsa.PrivateKey, error) {
+	result := []*ecdsa.PrivateKey{}
+	for i, privateKey := range importedAccounts.PrivateKeys {
+		accountKey, err := parseImportedPrivateKey(privateKey)
+		if err != nil {
+			return nil, stacktrace.Propagate(err, "Imported private key #%v was invalid", i+1)
+		}
+		result = append(result, accountKey)
+	}
+
+	if importedAccounts.KeystoresFilesArtifactUUID != "" {
+		for _, password := range importedAccounts.KeystorePasswords {
+			registerSecret(password)
+		}
+		registerSecret(importedAccounts.KeystorePassword)
+		keystoreContents, err := readFilesArtifact(enclaveCtx, importedAccounts.KeystoresFilesArtifactUUID)
+		if err != nil {
+			return nil, stacktrace.Propagate(err, "An error occurred reading the keystores files artifact '%v'", importedAccounts.KeystoresFilesArtifactUUID)
+		}
+		if len(keystoreContents) == 0 {
+			return nil, stacktrace.NewError("Keystores files artifact '%v' has no files", importedAccounts.KeystoresFilesArtifactUUID)
+		}
+		keystoreFilenames := []string{}
+		for filename := range keystoreContents {
+			keystoreFilenames = append(keystoreFilenames, filename)
+		}
+		sort.Strings(keystoreFilenames)
+		for _, filename := range keystoreFilenames {
+			password, found := importedAccounts.KeystorePasswords[filename]
+			if !found {
+				password = importedAccounts.KeystorePassword
+			}
+			key, err := keystore.DecryptKey([]byte(keystoreContents[filename]), password)
+			if err != nil {
+				return nil, stacktrace.Propagate(err, "An error occurred decrypting keystore '%v' of files artifact '%v'", filename, importedAccounts.KeystoresFilesArtifactUUID)
+			}
+			registerSecret(hex.EncodeToString(crypto.FromECDSA(key.PrivateKey)))
+			result = append(result, key.PrivateKey)
+		}
+	}
+
+	seenAddrs := map[string]bool{}
+	for _, accountKey := range result {
+		accountAddr := getAccountAddress(accountKey)
+		if seenAddrs[accountAddr] {
+			return nil, stacktrace.NewError("Account '%v' was imported more than once", accountAddr)
+		}
+		seenAddrs[accountAddr] = true
+	}
+	if len(result) < numSigners {
+		return nil, stacktrace.NewError("The network has %v signers, which each seal with an imported account, but only %v accounts were imported", numSigners, len(result))
+	}
+	return result, nil
+}
+
+func parseImportedPrivateKey(privateKey string) (*ecdsa.PrivateKey, error) {
+	trimmedPrivateKey := strings.TrimPrefix(strings.TrimSpace(privateKey), "0x")
+	registerSecret(trimmedPrivateKey)
+	accountKey, err := crypto.HexToECDSA(trimmedPrivateKey)
+	if err != nil {
+		return nil, stacktrace.Propagate(err, "The private key isn't a hex-encoded secp256k1 key")
+	}
+	return accountKey, nil
+}
+
+func getAccountAddress(accountKey *ecdsa.PrivateKey) string {
+	return strings.ToLower(crypto.PubkeyToAddress(accountKey.PublicKey).Hex())
+}
+
+// Funds every imported account at genesis in place of the account of the static files, which isn't used
+func setImportedAccountsAlloc(genesis map[string]interface{}, launchConfig *nodeLaunchConfig) error {
+	alloc, found := genesis[genesisAllocField].(map[string]interface{})
+	if !found {
+		return stacktrace.NewError("The genesis has no '%v' object", genesisAllocField)
+	}
+	for allocAddr := range alloc {
+		if strings.EqualFold(strings.TrimPrefix(allocAddr, "0x"), strings.TrimPrefix(getAccountAddress(launchConfig.staticFiles.signerAccountKey), "0x")) {
+			delete(alloc, allocAddr)
+		}
+	}
+	for _, accountKey := range launchConfig.importedAccountKeys {
+		alloc[strings.TrimPrefix(getAccountAddress(accountKey), "0x")] = map[string]interface{}{
+			genesisAllocBalanceField: launchConfig.genesisBalanceWei,
+		}
+	}
+	return nil
+}
+
+// Returns the imported accounts in the shape of the execute params, with every account as a raw private key, which is how
+// they're recorded in the account files of the bootnode
+func serializeImportedAccounts(launchConfig *nodeLaunchConfig) ([]byte, error) {
+	importedAccounts := &ModuleAPIImportedAccountsArgs{
+		PrivateKeys:       []string{},
+		GenesisBalanceWei: launchConfig.genesisBalanceWei,
+	}
+	for _, accountKey := range launchConfig.importedAccountKeys {
+		importedAccounts.PrivateKeys = append(importedAccounts.PrivateKeys, hex.EncodeToString(crypto.FromECDSA(accountKey)))
+	}
+	serializedImportedAccounts, err := json.Marshal(importedAccounts)
+	if err != nil {
+		return nil, stacktrace.Propagate(err, "An error occurred serializing the imported accounts")
+	}
+	return serializedImportedAccounts, nil
+}
+
+// Reads the imported accounts that the network was started with from the account files of the given bootnode, returning no
+// accounts for networks that use the account of the static files
+func getImportedAccounts(bootnodeServiceCtx *services.ServiceContext) ([]*ecdsa.PrivateKey, string, error) {
+	catImportedAccountsCmd := []string{"cat", path.Join(accountFilesMountpointOnNodes, importedAccountsFilename)}
+	exitCode, serializedImportedAccounts, err := bootnodeServiceCtx.ExecCommand(catImportedAccountsCmd)
+	if err != nil {
+		return nil, "", stacktrace.Propagate(err, "An error occurred reading the imported accounts of bootnode '%v'", bootnodeServiceCtx.GetServiceID())
+	}
+	if exitCode != execCommandSuccessExitCode {
+		return nil, "", nil
+	}
+	importedAccounts := &ModuleAPIImportedAccountsArgs{}
+	if err := json.Unmarshal([]byte(serializedImportedAccounts), importedAccounts); err != nil {
+		return nil, "", stacktrace.Propagate(err, "An error occurred deserializing the imported accounts of bootnode '%v'", bootnodeServiceCtx.GetServiceID())
+	}
+	result := []*ecdsa.PrivateKey{}
+	for i, privateKey := range importedAccounts.PrivateKeys {
+		accountKey, err := parseImportedPrivateKey(privateKey)
+		if err != nil {
+			return nil, "", stacktrace.Propagate(err, "Imported private key #%v of bootnode '%v' was invalid", i+1, bootnodeServiceCtx.GetServiceID())
+		}
+		result = append(result, accountKey)
+	}
+	return result, importedAccounts.GenesisBalanceWei, nil
+}
+
+// The accounts are listed in the genesis, so they can only match if they're the same accounts in the same order, with the
+// same balance
+func isImportedAccountsMatching(launchConfig *nodeLaunchConfig, importedAccountKeys []*ecdsa.PrivateKey, genesisBalanceWei string) bool {
+	if len(importedAccountKeys) != len(launchConfig.importedAccountKeys) {
+		return false
+	}
+	if len(importedAccountKeys) == 0 {
+		return true
+	}
+	for i, accountKey := range importedAccountKeys {
+		if getAccountAddress(accountKey) != getAccountAddress(launchConfig.importedAccountKeys[i]) {
+			return false
+		}
+	}
+	return genesisBalanceWei == launchConfig.genesisBalanceWei
+}
+
+// The genesis of the static files is only used as it is when the bootnode is the only signer and seals with the account of
+// the static files
+func isGenesisRendered(launchConfig *nodeLaunchConfig) bool {
+	return launchConfig.bootnodeMode == discoveryOnlyBootnodeMode || len(launchConfig.importedAccountKeys) != 0
+}
@@ -9,21 +9,338 @@ type ModuleInitArgs struct {
 }
 
 // Struct representing the params that the executable module will accept when being executed
-type ModuleAPIExecuteArgs struct{}
+type ModuleAPIExecuteArgs struct {
+	// What to do; one of "create" (the default), which starts a new network, "reconcile", which makes the enclave match the
+	// requested network by only changing what differs, or one of the following actions on a network that this module
+	// started earlier in the same enclave:
+	//  - "add_nodes", which adds child nodes that get peered with the rest of the network
+	//  - "stop_node", which pauses a node so that it stops taking part in the network
+	//  - "restart_node", which replaces a node with a fresh one under the same service ID, that resyncs from its peers
+	//  - "remove_node", which removes a child node from the network
+	//  - "status", which starts nothing and reports the health of every node that the module started
+	Action string `json:"action"`
+
+	// The child nodes of the network, only used by the "create" and "reconcile" actions in "network" mode
+	// Defaults to two child nodes with the default spec
+	Nodes []*ModuleAPINodeSpec `json:"nodes"`
+
+	// Number of child nodes to add, only used by the "add_nodes" action (defaults to 1)
+	NodeCount uint32 `json:"node_count"`
+
+	// Service ID of the node to act on, only used by the "stop_node", "restart_node" and "remove_node" actions
+	NodeServiceID services.ServiceID `json:"node_service_id"`
+
+	// What to start; one of "network" (the default), for a bootnode with child nodes, or "dev", for a single Geth node in dev mode
+	Mode string `json:"mode"`
+
+	// Seconds between the blocks sealed by the dev node, where 0 (the default) seals a block as soon as a transaction arrives
+	// Only used in "dev" mode
+	DevPeriodSeconds uint32 `json:"dev_period_seconds"`
+
+	// How the network produces blocks; one of "clique" (the default) or "engine_api_driver"
+	BlockProduction string `json:"block_production"`
+
+	// Settings for the built-in Engine API block driver, only used when block production is "engine_api_driver"
+	EngineApiDriver *ModuleAPIEngineApiDriverArgs `json:"engine_api_driver"`
+
+	// How the nodes find each other; one of:
+	//  - "admin_add_peer" (the default), where the module connects every pair of nodes through the admin_addPeer RPC method
+	//  - "static_nodes", where each node's config lists the nodes started before it as static nodes, which it dials
+	//  - "trusted_nodes", where each node's config lists the nodes started before it as trusted nodes, which only
+	//    lifts the peer limit for them, so the nodes still find each other through discovery
+	//  - "discovery", where the nodes only find each other through discovery from the bootnode
+	// Only used by the "create" and "reconcile" actions in "network" mode
+	PeeringStrategy string `json:"peering_strategy"`
+
+	// What the bootnode does; one of:
+	//  - "signer" (the default), where the bootnode is a Geth node that is also the only Clique signer
+	//  - "discovery_only", where the bootnode only runs discovery with Geth's bootnode binary, and the blocks are sealed by
+	//    separate signer nodes
+	// Only used by the "create" and "reconcile" actions in "network" mode, with "clique" block production
+	BootnodeMode string `json:"bootnode_mode"`
+
+	// Number of signer nodes, only used when the bootnode mode is "discovery_only" (defaults to 1)
+	SignerCount uint32 `json:"signer_count"`
+
+	// Number of bootnodes, whose ENRs are all given to every node (defaults to 1)
+	// More than one bootnode can only be started when the bootnode mode is "discovery_only"
+	BootnodeCount uint32 `json:"bootnode_count"`
+
+	// How the nodes discover the network; one of "bootnodes" (the default), where they're given the ENRs of the bootnodes, or
+	// "dns", where they're given the URL of an EIP-1459 tree holding the records of all the nodes started with the network,
+	// which is served by a DNS server in the enclave
+	// Only used by the "create" and "reconcile" actions in "network" mode, with bootnode mode "signer"
+	NodeDiscovery string `json:"node_discovery"`
+
+	// Default CPU and memory limits of each role of node, which the limits in a node's spec take precedence over
+	// Only used by the "create" and "reconcile" actions in "network" mode
+	ResourceDefaults *ModuleAPIResourceDefaults `json:"resource_defaults"`
+
+	// How the nodes serve their RPC API; one of:
+	//  - "open" (the default), where every node serves every namespace over HTTP to any origin and host
+	//  - "hardened", where every node only serves the JWT-authenticated endpoint over HTTP and WS, which checks tokens signed
+	//    with a generated secret and serves Geth's "eth" and "engine" namespaces; the signers need the "clef" signer backend
+	// Only used by the "create" and "reconcile" actions in "network" mode, with "clique" block production
+	RpcProfile string `json:"rpc_profile"`
+
+	// Settings of the "hardened" RPC profile, only used when the RPC profile is "hardened"
+	HardenedRpc *ModuleAPIHardenedRpcArgs `json:"hardened_rpc"`
+
+	// Where the signers keep the account they seal with; one of:
+	//  - "keystore" (the default, except in the "hardened" RPC profile), where each signer unlocks the account from its own keystore with the password file
+	//  - "clef", where each signer gets its own Clef service holding the account, under rules that only approve signing
+	//    Clique headers, and the signer reaches it as its external signer without ever getting the account or its password
+	// Only used by the "create" and "reconcile" actions in "network" mode, with "clique" block production
+	SignerBackend string `json:"signer_backend"`
+
+	// Accounts to use in place of the one whose keystore is in the static files, which isn't used or returned then; the
+	// signers seal with them in order, starting with the bootnode in "signer" bootnode mode, and they're all funded at genesis
+	// Only used by the "create" and "reconcile" actions in "network" mode, with "clique" block production
+	ImportedAccounts *ModuleAPIImportedAccountsArgs `json:"imported_accounts"`
+
+	// UUID of a files artifact to use as the static files of the nodes in place of the ones built into the module; it must
+	// have the genesis, the signer account password and exactly one keystore that the password decrypts, along with the
+	// post-merge genesis for "engine_api_driver" block production, and everything is checked before any node gets started
+	// Only used by the "create" and "reconcile" actions
+	StaticFilesArtifactUUID services.FilesArtifactUUID `json:"static_files_artifact_uuid"`
+
+	// Where the secrets of the result go; one of "inline" (the default), where they're in the result, or "files_artifact",
+	// where they're written to a files artifact referenced by the result, and left out of the result itself
+	// Not used by the "status" action, whose result has no secrets
+	SecretsOutput string `json:"secrets_output"`
+
+	// Whether the accounts of the result include their hex-encoded private keys (defaults to false)
+	// Not used by the "status" action, whose result has no accounts
+	IncludePrivateKeys bool `json:"include_private_keys"`
+
+	// Secret mixed into the node keys, the account keys of the extra signers and the key that signs the DNS discovery tree,
+	// of at least 16 characters; without it, those keys only depend on the service IDs, so anyone can derive them
+	// Only used by the "create" and "reconcile" actions in "network" mode
+	KeySeed string `json:"key_seed"`
+
+	// Maximum number of nodes that get started, queried or peered at once (defaults to 8)
+	MaxConcurrency uint32 `json:"max_concurrency"`
+
+	// Debug flag that keeps the services created by a failed execution, which are otherwise removed so the execution can be
+	// retried in the same enclave
+	KeepOnFailure bool `json:"keep_on_failure"`
+}
+
+type ModuleAPINodeSpec struct {
+	// Must start with "ethereum-node-"
+	ServiceID services.ServiceID `json:"service_id"`
+
+	// Geth's --gcmode; one of "archive" (the default) or "full"
+	GcMode string `json:"gc_mode"`
+
+	// Geth's --syncmode; one of "full" (the default) or "snap"
+	SyncMode string `json:"sync_mode"`
+
+	// CPU and memory limits of the node's container, which default to those of its role; 0 leaves the resource unlimited
+	CpuMillicpus    uint64 `json:"cpu_millicpus"`
+	MemoryMegabytes uint64 `json:"memory_megabytes"`
+}
+
+type ModuleAPIResourceDefaults struct {
+	// Applies to every bootnode, including one that also signs blocks
+	Bootnode *ModuleAPIResourceLimits `json:"bootnode"`
+	// Applies to the signer nodes started in bootnode mode "discovery_only"
+	Signer *ModuleAPIResourceLimits `json:"signer"`
+	// Apply to the other nodes, according to their GC mode
+	ArchiveNode *ModuleAPIResourceLimits `json:"archive_node"`
+	FullNode    *ModuleAPIResourceLimits `json:"full_node"`
+}
+
+type ModuleAPIResourceLimits struct {
+	// Thousandths of a CPU that the container can use, where 0 leaves it unlimited
+	CpuMillicpus uint64 `json:"cpu_millicpus"`
+	// Megabytes of memory that the container can use, where 0 leaves it unlimited
+	MemoryMegabytes uint64 `json:"memory_megabytes"`
+}
+
+type ModuleAPIEngineApiDriverArgs struct {
+	// Address that will receive the fees of the blocks produced by the driver
+	FeeRecipient string `json:"fee_recipient"`
+
+	// Seconds between two consecutive blocks produced by the driver
+	SlotTimeSeconds uint32 `json:"slot_time_seconds"`
+}
+
+type ModuleAPIImportedAccountsArgs struct {
+	// Hex-encoded private keys, whose accounts come first
+	PrivateKeys []string `json:"private_keys"`
+
+	// Files artifact holding Geth keystore files at its root, whose accounts come after those of the private keys, in the
+	// order of the file names
+	KeystoresFilesArtifactUUID services.FilesArtifactUUID `json:"keystores_files_artifact_uuid"`
+
+	// Password of each keystore by its file name, where the keystores that aren't listed use the keystore password
+	KeystorePasswords map[string]string `json:"keystore_passwords"`
+	KeystorePassword  string            `json:"keystore_password"`
+
+	// Wei that every account is funded with at genesis, as a decimal string (defaults to 1000 ether)
+	GenesisBalanceWei string `json:"genesis_balance_wei"`
+}
+
+type ModuleAPIHardenedRpcArgs struct {
+	// Host names that the JWT-authenticated endpoint accepts requests for, besides IP addresses (defaults to "localhost")
+	VirtualHosts []string `json:"virtual_hosts"`
+}
 
 // Struct representing the result that will be returned to the user on execute
 type ModuleAPIExecuteResult struct {
-	BootnodeServiceID     services.ServiceID                                `json:"bootnode_service_id"`
+	BootnodeServiceIDs    []services.ServiceID                              `json:"bootnode_service_ids"`
 	NodeInfo              map[services.ServiceID]*ModuleAPIEthereumNodeInfo `json:"node_info"`
 	SignerKeystoreContent string                                            `json:"signer_keystore_content"`
 	SignerAccountPassword string                                            `json:"signer_account_password"`
+	Action                string                                            `json:"action"`
+	Mode                  string                                            `json:"mode"`
+	BlockProduction       string                                            `json:"block_production,omitempty"`
+	PeeringStrategy       string                                            `json:"peering_strategy,omitempty"`
+	BootnodeMode          string                                            `json:"bootnode_mode,omitempty"`
+	// The bootnodes are reported here even when they're also nodes of the node info, so their records are found in one place
+	BootnodeInfo map[services.ServiceID]*ModuleAPIBootnodeInfo `json:"bootnode_info,omitempty"`
+	// Nodes of the node info that seal the blocks
+	SignerServiceIDs []services.ServiceID `json:"signer_service_ids,omitempty"`
+	NodeDiscovery    string               `json:"node_discovery,omitempty"`
+	// Only set when the node discovery is "dns"
+	DnsDiscovery *ModuleAPIDnsDiscoveryInfo `json:"dns_discovery,omitempty"`
+	// Hex-encoded secret used to authenticate against the Engine API, only set when block production is "engine_api_driver"
+	EngineApiJwtSecret string `json:"engine_api_jwt_secret,omitempty"`
+	RpcProfile         string `json:"rpc_profile,omitempty"`
+	// Hex-encoded secret that the JWT-authenticated endpoint of every node checks tokens against, only set when the RPC
+	// profile is "hardened"
+	RpcJwtSecret  string `json:"rpc_jwt_secret,omitempty"`
+	SignerBackend string `json:"signer_backend,omitempty"`
+	// The Clef of each signer by the service ID of the signer, only set when the signer backend is "clef"
+	ClefSigners map[services.ServiceID]*ModuleAPIClefSignerInfo `json:"clef_signers,omitempty"`
+	// Every account that the module manages, decrypted from the keystore or derived like the signers' accounts, starting
+	// with the account whose keystore is in the result
+	Accounts []*ModuleAPIAccountInfo `json:"accounts"`
+	// Only set when the secrets output is "files_artifact", for the files artifact holding the secrets in place of the result,
+	// each in its own file: the signer keystore under its original name, "password.txt", "engine-api-jwt-secret",
+	// "rpc-jwt-secret", "dns-tree-signing-key" and "<address>-private-key" for each account, for those that the result
+	// would have
+	SecretsFilesArtifactUUID services.FilesArtifactUUID `json:"secrets_files_artifact_uuid,omitempty"`
+	// What the "reconcile" action changed to make the enclave match the requested network
+	ReconcileDiff *ModuleAPIReconcileDiff `json:"reconcile_diff,omitempty"`
+	// How long each phase of the network startup took, in the order the phases ran
+	StartupPhases []*ModuleAPIStartupPhase `json:"startup_phases,omitempty"`
+}
+
+// The address and keys of an account are all 0x-prefixed hex, which is also what the imported private keys can be given in
+type ModuleAPIAccountInfo struct {
+	Address string `json:"address"`
+	// Uncompressed public key
+	PublicKey string `json:"public_key"`
+	// Only set when the execute params include the private keys
+	PrivateKey string `json:"private_key,omitempty"`
+	// The signer that seals with the account, if any
+	SignerServiceID services.ServiceID `json:"signer_service_id,omitempty"`
+}
+
+type ModuleAPIStartupPhase struct {
+	Name           string `json:"name"`
+	DurationMillis int64  `json:"duration_millis"`
+}
+
+type ModuleAPIReconcileDiff struct {
+	// True if no network existed yet, so it was created from scratch
+	IsNetworkCreated bool `json:"is_network_created"`
+
+	AddedServiceIDs   []services.ServiceID `json:"added_service_ids"`
+	RemovedServiceIDs []services.ServiceID `json:"removed_service_ids"`
+	// Nodes whose spec changed, or which were stopped, are replaced by a fresh node started with the requested spec
+	RecreatedServiceIDs []services.ServiceID `json:"recreated_service_ids"`
+	UnchangedServiceIDs []services.ServiceID `json:"unchanged_service_ids"`
 }
 
 type ModuleAPIEthereumNodeInfo struct {
 	IPAddrInsideNetwork string `json:"ip_addr_inside_network"`
 	IPAddrOnHostMachine string `json:"ip_addr_on_host_machine"`
-	RpcPortId           string `json:"rpc_port_id"`
-	WsPortId            string `json:"ws_port_id"`
-	TcpDiscoveryPortId  string `json:"tcp_discovery_port_id"`
-	UdpDiscoveryPortId  string `json:"udp_discovery_port_id"`
+	// Empty for the nodes of the "hardened" RPC profile, which serve no open endpoint
+	RpcPortId          string `json:"rpc_port_id,omitempty"`
+	WsPortId           string `json:"ws_port_id,omitempty"`
+	TcpDiscoveryPortId string `json:"tcp_discovery_port_id"`
+	UdpDiscoveryPortId string `json:"udp_discovery_port_id"`
+	// Only set when the nodes expose the JWT-authenticated endpoint, i.e. with the Engine API or the "hardened" RPC profile
+	AuthRpcPortId string `json:"auth_rpc_port_id,omitempty"`
+	// True if the node was paused by the "stop_node" action and hasn't been restarted since
+	IsStopped bool `json:"is_stopped,omitempty"`
+	// Computed from the node key that the module derives from the service ID, so the node ID in them is the same on every run
+	Enode string `json:"enode"`
+	Enr   string `json:"enr"`
+	// Files artifact holding the config.toml that the node runs Geth with, along with its node key, from which the node can
+	// be run again outside of Kurtosis; only set for the nodes started by this instance of the module
+	NodeFilesArtifactUUID services.FilesArtifactUUID `json:"node_files_artifact_uuid,omitempty"`
+	// URL of the RPC API that the node serves on a loopback port of the test process; only set for the nodes of a simulated
+	// network, which have no ports in an enclave for the port IDs to refer to
+	RpcUrl string `json:"rpc_url,omitempty"`
+}
+
+type ModuleAPIBootnodeInfo struct {
+	ServiceID           services.ServiceID `json:"service_id"`
+	IPAddrInsideNetwork string             `json:"ip_addr_inside_network"`
+	IPAddrOnHostMachine string             `json:"ip_addr_on_host_machine"`
+	UdpDiscoveryPortId  string             `json:"udp_discovery_port_id"`
+	// True if the bootnode only runs discovery, in which case it has no RPC port and isn't part of the node info
+	IsDiscoveryOnly bool `json:"is_discovery_only"`
+	// True if the bootnode was paused by the "stop_node" action and hasn't been restarted since
+	IsStopped bool   `json:"is_stopped,omitempty"`
+	Enode     string `json:"enode"`
+	Enr       string `json:"enr"`
+}
+
+type ModuleAPIDnsDiscoveryInfo struct {
+	// Service ID of the DNS server that serves the tree
+	ServiceID           services.ServiceID `json:"service_id"`
+	IPAddrInsideNetwork string             `json:"ip_addr_inside_network"`
+	// The enrtree:// URL given to Geth's --discovery.dns flag
+	TreeURL string `json:"tree_url"`
+	// Hex-encoded private key that signed the tree
+	SigningKey string `json:"signing_key"`
+}
+
+type ModuleAPIClefSignerInfo struct {
+	ServiceID           services.ServiceID `json:"service_id"`
+	IPAddrInsideNetwork string             `json:"ip_addr_inside_network"`
+	// Port of Clef's external API, which is only reachable inside the enclave
+	HttpPortId string `json:"http_port_id"`
+	// Address of the account that the Clef signs with
+	AccountAddress string `json:"account_address"`
+}
+
+// Struct representing the result of the "status" action
+type ModuleAPIStatusResult struct {
+	Action     string                                      `json:"action"`
+	NodeStatus map[services.ServiceID]*ModuleAPINodeStatus `json:"node_status"`
+	// True if every node was reachable and all of them have the same head block
+	HeadsAgree bool `json:"heads_agree"`
+}
+
+type ModuleAPINodeStatus struct {
+	// Stopped nodes aren't queried, so the rest of their status is left empty
+	IsStopped bool `json:"is_stopped,omitempty"`
+	// Set if the node couldn't be queried, in which case the rest of its status may be incomplete
+	Error string `json:"error,omitempty"`
+
+	ClientVersion   string `json:"client_version"`
+	HeadBlockNumber uint64 `json:"head_block_number"`
+	HeadBlockHash   string `json:"head_block_hash"`
+
+	IsSyncing bool `json:"is_syncing"`
+	// Only set while the node is syncing
+	SyncCurrentBlock uint64 `json:"sync_current_block,omitempty"`
+	SyncHighestBlock uint64 `json:"sync_highest_block,omitempty"`
+
+	Peers []*ModuleAPIPeerInfo `json:"peers"`
+
+	TxpoolPending uint64 `json:"txpool_pending"`
+	TxpoolQueued  uint64 `json:"txpool_queued"`
+}
+
+type ModuleAPIPeerInfo struct {
+	Enode         string `json:"enode"`
+	Name          string `json:"name"`
+	RemoteAddress string `json:"remote_address"`
 }
@@ -0,0 +1,79 @@
+package impl
+
+import (
+	"github.com/kurtosis-tech/kurtosis-sdk/api/golang/core/lib/services"
+	"github.com/kurtosis-tech/stacktrace"
+	"github.com/sirupsen/logrus"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Runs the task for each of the given nodes, with at most maxConcurrency tasks running at once, returning all the failures
+func runForEachNode(serviceIds []services.ServiceID, maxConcurrency uint32, task func(serviceId services.ServiceID) error) error {
+	workerSlots := make(chan bool, maxConcurrency)
+	waitGroup := &sync.WaitGroup{}
+	errorsMutex := &sync.Mutex{}
+	taskErrors := map[services.ServiceID]error{}
+	for _, serviceId := range serviceIds {
+		workerSlots <- true
+		waitGroup.Add(1)
+		go func(serviceId services.ServiceID) {
+			defer func() {
+				<-workerSlots
+				waitGroup.Done()
+			}()
+			if err := task(serviceId); err != nil {
+				errorsMutex.Lock()
+				defer errorsMutex.Unlock()
+				taskErrors[serviceId] = err
+			}
+		}(serviceId)
+	}
+	waitGroup.Wait()
+
+	if len(taskErrors) == 0 {
+		return nil
+	}
+	failedServiceIds := []services.ServiceID{}
+	for serviceId := range taskErrors {
+		failedServiceIds = append(failedServiceIds, serviceId)
+	}
+	sortServiceIds(failedServiceIds)
+	errorStrs := []string{}
+	for _, serviceId := range failedServiceIds {
+		errorStrs = append(errorStrs, "Node '"+string(serviceId)+"':\n"+taskErrors[serviceId].Error())
+	}
+	return stacktrace.NewError(
+		"%v of %v nodes failed:\n%v",
+		len(taskErrors),
+		len(serviceIds),
+		strings.Join(errorStrs, "\n\n"),
+	)
+}
+
+// Appends the phase that started at the given time and ends now to the startup phases
+func recordStartupPhase(phases []*ModuleAPIStartupPhase, name string, startTime time.Time) []*ModuleAPIStartupPhase {
+	duration := time.Since(startTime)
+	logrus.Infof("Startup phase '%v' took %v", name, duration)
+	return append(phases, &ModuleAPIStartupPhase{
+		Name:           name,
+		DurationMillis: duration.Milliseconds(),
+	})
+}
+
+func getSortedServiceIds(serviceCtxs map[services.ServiceID]*services.ServiceContext) []services.ServiceID {
+	serviceIds := []services.ServiceID{}
+	for serviceId := range serviceCtxs {
+		serviceIds = append(serviceIds, serviceId)
+	}
+	sortServiceIds(serviceIds)
+	return serviceIds
+}
+
+func sortServiceIds(serviceIds []services.ServiceID) {
+	sort.Slice(serviceIds, func(i, j int) bool {
+		return serviceIds[i] < serviceIds[j]
+	})
+}
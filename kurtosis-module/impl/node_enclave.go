@@ -0,0 +1,56 @@
+package impl
+
+import (
+	"github.com/kurtosis-tech/kurtosis-sdk/api/golang/core/lib/services"
+	"net/http"
+)
+
+// The parts of the enclave that starting nodes uses, which the enclave context implements; the node startup only takes these,
+// so that it can run against an in-memory enclave
+type serviceAdder interface {
+	AddService(serviceId services.ServiceID, containerConfig *services.ContainerConfig) (*services.ServiceContext, error)
+}
+
+type filesUploader interface {
+	UploadFiles(pathToUpload string) (services.FilesArtifactUUID, error)
+}
+
+type serviceWaiter interface {
+	GetServiceContext(serviceId services.ServiceID) (*services.ServiceContext, error)
+	WaitForHttpPostEndpointAvailability(
+		serviceId services.ServiceID,
+		port uint32,
+		path string,
+		requestBody string,
+		initialDelayMilliseconds uint32,
+		retries uint32,
+		retriesDelayMilliseconds uint32,
+		bodyText string,
+	) error
+}
+
+type nodeEnclave interface {
+	serviceAdder
+	filesUploader
+	serviceWaiter
+}
+
+// The parts of the enclave that executing the actions uses, through which the services that an execution adds get recorded
+type moduleEnclave interface {
+	nodeEnclave
+	GetServices() (map[services.ServiceID]bool, error)
+	RemoveService(serviceId services.ServiceID, containerStopTimeoutSeconds uint64) error
+	PauseService(serviceId services.ServiceID) error
+	UnpauseService(serviceId services.ServiceID) error
+}
+
+// A service that commands can be executed on, like the service context of a node
+type commandExecutor interface {
+	GetServiceID() services.ServiceID
+	ExecCommand(command []string) (int32, string, error)
+}
+
+// Sends the HTTP requests of the RPC calls to the nodes, which an HTTP client does
+type rpcTransport interface {
+	Do(request *http.Request) (*http.Response, error)
+}
@@ -0,0 +1,199 @@
+package impl
+
+import (
+	"crypto/ecdsa"
+	"encoding/hex"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/p2p/enode"
+	"github.com/ethereum/go-ethereum/p2p/enr"
+	"github.com/kurtosis-tech/kurtosis-sdk/api/golang/core/lib/services"
+	"github.com/kurtosis-tech/stacktrace"
+	"io/ioutil"
+	"net"
+	"os"
+	"path"
+	"strings"
+)
+
+const (
+	// Mixed into every node key, so the keys of this module don't collide with keys derived from service IDs elsewhere
+	nodeKeySeed = "ethereum-kurtosis-module-node-key"
+
+	minKeySeedLength = 16
+	keySeedEnvVar    = "ETHEREUM_KURTOSIS_MODULE_KEY_SEED"
+
+	nodeFilesTempDirPattern    = "node-files-"
+	nodeKeyFilename            = "nodekey"
+	nodeFilePerms              = 0600
+	nodeFilesMountpointOnNodes = "/node-files"
+
+	accountFilesTempDirPattern    = "account-files-"
+	accountFilesMountpointOnNodes = "/account-files"
+)
+
+// Derives a key from the given constant seed and ID, mixed with the key seed of the network, if any
+// Without a key seed, the key is the same for everyone who knows the ID, so it only fits networks that hold nothing of value
+func deriveKey(constantSeed string, keySeed string, id string) (*ecdsa.PrivateKey, error) {
+	keyParts := [][]byte{[]byte(constantSeed)}
+	// The hash of the key seed has a fixed length, so no two pairs of key seed and ID end up with the same key
+	if keySeed != "" {
+		keyParts = append(keyParts, crypto.Keccak256([]byte(keySeed)))
+	}
+	keyParts = append(keyParts, []byte(id))
+	key, err := crypto.ToECDSA(crypto.Keccak256(keyParts...))
+	if err != nil {
+		return nil, stacktrace.Propagate(err, "An error occurred converting the hash of the seeds to a key")
+	}
+	registerSecret(hex.EncodeToString(crypto.FromECDSA(key)))
+	return key, nil
+}
+
+// Reads the key seed that the network was started with from the given bootnode, which is empty if it was started without one
+func getKeySeed(bootnodeServiceCtx *services.ServiceContext) (string, error) {
+	exitCode, keySeed, err := bootnodeServiceCtx.ExecCommand([]string{"printenv", keySeedEnvVar})
+	if err != nil {
+		return "", stacktrace.Propagate(err, "An error occurred reading the key seed of bootnode '%v'", bootnodeServiceCtx.GetServiceID())
+	}
+	if exitCode != execCommandSuccessExitCode {
+		return "", nil
+	}
+	keySeed = strings.TrimSuffix(keySeed, "\n")
+	registerSecret(keySeed)
+	return keySeed, nil
+}
+
+// Derives the node key of a node from its service ID and the key seed, so that its enode ID is the same on every run
+func getNodeKey(keySeed string, serviceId services.ServiceID) (*ecdsa.PrivateKey, error) {
+	nodeKey, err := deriveKey(nodeKeySeed, keySeed, string(serviceId))
+	if err != nil {
+		return nil, stacktrace.Propagate(err, "An error occurred deriving the node key of node '%v'", serviceId)
+	}
+	return nodeKey, nil
+}
+
+// Computes the record that the node with the given service ID advertises once it listens on the given IP, without asking
+// the node, so that the record is known before the node is up
+func getNodeRecord(keySeed string, serviceId services.ServiceID, privateIpAddr string) (*enode.Node, error) {
+	nodeKey, err := getNodeKey(keySeed, serviceId)
+	if err != nil {
+		return nil, stacktrace.Propagate(err, "An error occurred getting the node key of node '%v'", serviceId)
+	}
+	ipAddr := net.ParseIP(privateIpAddr)
+	if ipAddr == nil {
+		return nil, stacktrace.NewError("Node '%v' has invalid IP address '%v'", serviceId, privateIpAddr)
+	}
+
+	record := &enr.Record{}
+	record.Set(enr.IP(ipAddr))
+	record.Set(enr.TCP(discoveryPortNum))
+	record.Set(enr.UDP(discoveryPortNum))
+	if err := enode.SignV4(record, nodeKey); err != nil {
+		return nil, stacktrace.Propagate(err, "An error occurred signing the record of node '%v'", serviceId)
+	}
+	node, err := enode.New(enode.ValidSchemes, record)
+	if err != nil {
+		return nil, stacktrace.Propagate(err, "An error occurred creating the record of node '%v'", serviceId)
+	}
+	return node, nil
+}
+
+func getNodeEnr(keySeed string, serviceCtx *services.ServiceContext) (string, error) {
+	node, err := getNodeRecord(keySeed, serviceCtx.GetServiceID(), serviceCtx.GetPrivateIPAddress())
+	if err != nil {
+		return "", stacktrace.Propagate(err, "An error occurred computing the record of node '%v'", serviceCtx.GetServiceID())
+	}
+	return node.String(), nil
+}
+
+func getEnodeAddress(keySeed string, serviceCtx *services.ServiceContext) (string, error) {
+	node, err := getNodeRecord(keySeed, serviceCtx.GetServiceID(), serviceCtx.GetPrivateIPAddress())
+	if err != nil {
+		return "", stacktrace.Propagate(err, "An error occurred computing the record of node '%v'", serviceCtx.GetServiceID())
+	}
+	return node.URLv4(), nil
+}
+
+// Uploads the files that only the given node gets: its node key, in the hex format that Geth's --nodekey flag expects, and
+// its rendered Geth config (if any)
+func uploadNodeFiles(
+	enclaveCtx filesUploader,
+	keySeed string,
+	serviceId services.ServiceID,
+	gethConfig string,
+) (services.FilesArtifactUUID, error) {
+	nodeKey, err := getNodeKey(keySeed, serviceId)
+	if err != nil {
+		return "", stacktrace.Propagate(err, "An error occurred getting the node key of node '%v'", serviceId)
+	}
+
+	tempDirpath, err := ioutil.TempDir("", nodeFilesTempDirPattern)
+	if err != nil {
+		return "", stacktrace.Propagate(err, "An error occurred creating a temporary directory for the files of node '%v'", serviceId)
+	}
+	defer os.RemoveAll(tempDirpath)
+
+	nodeKeyFilepath := path.Join(tempDirpath, nodeKeyFilename)
+	if err := ioutil.WriteFile(nodeKeyFilepath, []byte(hex.EncodeToString(crypto.FromECDSA(nodeKey))), nodeFilePerms); err != nil {
+		return "", stacktrace.Propagate(err, "An error occurred writing the node key of node '%v' to '%v'", serviceId, nodeKeyFilepath)
+	}
+
+	if gethConfig != "" {
+		gethConfigFilepath := path.Join(tempDirpath, gethConfigFilename)
+		if err := ioutil.WriteFile(gethConfigFilepath, []byte(gethConfig), nodeFilePerms); err != nil {
+			return "", stacktrace.Propagate(err, "An error occurred writing the Geth config of node '%v' to '%v'", serviceId, gethConfigFilepath)
+		}
+	}
+
+	nodeFilesArtifactUuid, err := enclaveCtx.UploadFiles(tempDirpath)
+	if err != nil {
+		return "", stacktrace.Propagate(err, "An error occurred uploading the files of node '%v'", serviceId)
+	}
+	return nodeFilesArtifactUuid, nil
+}
+
+// Uploads the account keys that the given node needs, which are the account key of a signer that imports its account itself
+// and, for the first bootnode, the imported accounts of the network (if any), returning an empty UUID for a node that needs none
+func uploadAccountFiles(
+	enclaveCtx filesUploader,
+	launchConfig *nodeLaunchConfig,
+	serviceId services.ServiceID,
+) (services.FilesArtifactUUID, error) {
+	accountFileContents := map[string][]byte{}
+	if isAccountUnlockedOnNode(launchConfig, serviceId) {
+		signerAccountKey, err := getSignerAccountKey(launchConfig, serviceId)
+		if err != nil {
+			return "", stacktrace.Propagate(err, "An error occurred getting the account key of signer '%v'", serviceId)
+		}
+		if signerAccountKey != nil {
+			accountFileContents[signerAccountKeyFilename] = []byte(hex.EncodeToString(crypto.FromECDSA(signerAccountKey)))
+		}
+	}
+	if serviceId == bootnodeServiceID && len(launchConfig.importedAccountKeys) != 0 {
+		serializedImportedAccounts, err := serializeImportedAccounts(launchConfig)
+		if err != nil {
+			return "", stacktrace.Propagate(err, "An error occurred serializing the imported accounts for bootnode '%v'", serviceId)
+		}
+		accountFileContents[importedAccountsFilename] = serializedImportedAccounts
+	}
+	if len(accountFileContents) == 0 {
+		return "", nil
+	}
+
+	tempDirpath, err := ioutil.TempDir("", accountFilesTempDirPattern)
+	if err != nil {
+		return "", stacktrace.Propagate(err, "An error occurred creating a temporary directory for the account files of node '%v'", serviceId)
+	}
+	defer os.RemoveAll(tempDirpath)
+	for filename, contents := range accountFileContents {
+		filepath := path.Join(tempDirpath, filename)
+		if err := ioutil.WriteFile(filepath, contents, nodeFilePerms); err != nil {
+			return "", stacktrace.Propagate(err, "An error occurred writing account file '%v' of node '%v'", filepath, serviceId)
+		}
+	}
+
+	accountFilesArtifactUuid, err := enclaveCtx.UploadFiles(tempDirpath)
+	if err != nil {
+		return "", stacktrace.Propagate(err, "An error occurred uploading the account files of node '%v'", serviceId)
+	}
+	return accountFilesArtifactUuid, nil
+}
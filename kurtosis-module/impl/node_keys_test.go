@@ -0,0 +1,142 @@
+package impl
+
+import (
+	"net"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/p2p/enode"
+	"github.com/kurtosis-tech/kurtosis-sdk/api/golang/core/lib/services"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	testKeySeed      = "test-key-seed-of-the-network"
+	testOtherKeySeed = "other-key-seed-of-the-network"
+	testNodeIpAddr   = "10.0.0.3"
+)
+
+// The computed record of a node must be the one that Geth advertises once it runs with the node key and listens on the IP
+func TestGetNodeRecord(t *testing.T) {
+	tests := []struct {
+		name          string
+		keySeed       string
+		serviceId     services.ServiceID
+		ipAddr        string
+		isErrExpected bool
+	}{
+		{
+			name:      "bootnode",
+			serviceId: bootnodeServiceID,
+			ipAddr:    testNodeIpAddr,
+		},
+		{
+			name:      "child node with a key seed",
+			keySeed:   testKeySeed,
+			serviceId: getChildEthNodeServiceId(1),
+			ipAddr:    testNodeIpAddr,
+		},
+		{
+			name:          "invalid IP address",
+			serviceId:     getChildEthNodeServiceId(1),
+			ipAddr:        "10.0.0",
+			isErrExpected: true,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			nodeRecord, err := getNodeRecord(test.keySeed, test.serviceId, test.ipAddr)
+			if test.isErrExpected {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			nodeKey, err := getNodeKey(test.keySeed, test.serviceId)
+			require.NoError(t, err)
+
+			gethNode := enode.NewV4(&nodeKey.PublicKey, net.ParseIP(test.ipAddr), int(discoveryPortNum), int(discoveryPortNum))
+			require.Equal(t, gethNode.URLv4(), nodeRecord.URLv4())
+			parsedRecord, err := enode.Parse(enode.ValidSchemes, nodeRecord.String())
+			require.NoError(t, err)
+			require.Equal(t, gethNode.ID(), parsedRecord.ID())
+			require.Equal(t, test.ipAddr, parsedRecord.IP().String())
+			require.Equal(t, int(discoveryPortNum), parsedRecord.TCP())
+			require.Equal(t, int(discoveryPortNum), parsedRecord.UDP())
+		})
+	}
+}
+
+func TestExecute_KeySeed(t *testing.T) {
+	tests := []struct {
+		name    string
+		keySeed string
+	}{
+		{
+			name: "no key seed",
+		},
+		{
+			name:    "key seed",
+			keySeed: testKeySeed,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			enclave := newFakeEnclave()
+			resultObj, err := executeOnFakeEnclave(enclave, &ModuleAPIExecuteArgs{
+				BootnodeMode: discoveryOnlyBootnodeMode,
+				SignerCount:  2,
+				KeySeed:      test.keySeed,
+			})
+			require.NoError(t, err)
+			requireNodeEnodesMatch(t, enclave, resultObj)
+			for serviceId, nodeInfo := range resultObj.NodeInfo {
+				unseededRecord, err := getNodeRecord("", serviceId, nodeInfo.IPAddrInsideNetwork)
+				require.NoError(t, err)
+				require.Equal(t, test.keySeed == "", unseededRecord.URLv4() == nodeInfo.Enode, "Node '%v' has an unexpected enode", serviceId)
+			}
+			// The first signer seals with the account of the static files, and the others with derived accounts
+			extraSignerServiceId := getSignerNodeServiceId(firstSignerNodeIdx + 1)
+			unseededAccountKey, err := deriveKey(signerAccountKeySeed, "", string(extraSignerServiceId))
+			require.NoError(t, err)
+			isExtraSignerAccountFound := false
+			for _, account := range resultObj.Accounts {
+				if account.SignerServiceID == extraSignerServiceId {
+					isExtraSignerAccountFound = true
+					require.Equal(t, test.keySeed == "", getAccountAddress(unseededAccountKey) == account.Address)
+				}
+			}
+			require.True(t, isExtraSignerAccountFound)
+
+			// A later instance of the module reads the key seed back from the bootnode
+			addNodesParams := &ModuleAPIExecuteArgs{Action: AddNodesAction}
+			require.NoError(t, applyDefaultsAndValidateParams(addNodesParams))
+			addNodesResultObj, err := newFakeEnclaveModule(enclave).executeValidatedParams(enclave, addNodesParams)
+			require.NoError(t, err)
+			require.Len(t, addNodesResultObj.NodeInfo, len(resultObj.NodeInfo)+1)
+			requireNodeEnodesMatch(t, enclave, addNodesResultObj)
+
+			reconcileParams := &ModuleAPIExecuteArgs{
+				Action:       ReconcileAction,
+				BootnodeMode: discoveryOnlyBootnodeMode,
+				SignerCount:  2,
+				KeySeed:      testOtherKeySeed,
+			}
+			require.NoError(t, applyDefaultsAndValidateParams(reconcileParams))
+			_, err = newFakeEnclaveModule(enclave).executeValidatedParams(enclave, reconcileParams)
+			require.Error(t, err)
+		})
+	}
+}
+
+// Requires the enode that the result reports for every node to be the one of the node key that the node was started with
+func requireNodeEnodesMatch(t *testing.T, enclave *fakeEnclave, resultObj *ModuleAPIExecuteResult) {
+	for serviceId, nodeInfo := range resultObj.NodeInfo {
+		nodeEnode, err := enclave.getEnode(serviceId)
+		require.NoError(t, err)
+		require.Equal(t, nodeEnode, nodeInfo.Enode, "Node '%v' reports another enode than the one of its node key", serviceId)
+	}
+	for serviceId, bootnodeInfo := range resultObj.BootnodeInfo {
+		bootnodeEnode, err := enclave.getEnode(serviceId)
+		require.NoError(t, err)
+		require.Equal(t, bootnodeEnode, bootnodeInfo.Enode, "Bootnode '%v' reports another enode than the one of its node key", serviceId)
+	}
+}
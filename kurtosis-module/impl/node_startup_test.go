@@ -0,0 +1,281 @@
+package impl
+
+import (
+	"testing"
+
+	"github.com/kurtosis-tech/kurtosis-sdk/api/golang/core/lib/services"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	testMaxConcurrency = 3
+)
+
+func TestExecute_Peering(t *testing.T) {
+	discovery := map[fakePeeringMechanism]bool{fakeDiscoveryMechanism: true}
+	tests := []struct {
+		name   string
+		params *ModuleAPIExecuteArgs
+		// The mechanisms that every two nodes are expected to be peered through, and those of the peerings with the bootnode
+		// when it's a Geth node
+		expectedMechanisms         map[fakePeeringMechanism]bool
+		expectedBootnodeMechanisms map[fakePeeringMechanism]bool
+	}{
+		{
+			name:                       "admin add peer",
+			params:                     &ModuleAPIExecuteArgs{PeeringStrategy: adminAddPeerPeeringStrategy},
+			expectedMechanisms:         map[fakePeeringMechanism]bool{fakeAddPeerMechanism: true, fakeDiscoveryMechanism: true},
+			expectedBootnodeMechanisms: discovery,
+		},
+		{
+			name:                       "static nodes",
+			params:                     &ModuleAPIExecuteArgs{PeeringStrategy: staticNodesPeeringStrategy},
+			expectedMechanisms:         map[fakePeeringMechanism]bool{fakeStaticNodesMechanism: true, fakeDiscoveryMechanism: true},
+			expectedBootnodeMechanisms: map[fakePeeringMechanism]bool{fakeStaticNodesMechanism: true, fakeDiscoveryMechanism: true},
+		},
+		{
+			// Geth never dials its trusted nodes, so the nodes only find each other through the bootnode
+			name:                       "trusted nodes",
+			params:                     &ModuleAPIExecuteArgs{PeeringStrategy: trustedNodesPeeringStrategy},
+			expectedMechanisms:         discovery,
+			expectedBootnodeMechanisms: discovery,
+		},
+		{
+			name:                       "discovery",
+			params:                     &ModuleAPIExecuteArgs{PeeringStrategy: discoveryPeeringStrategy},
+			expectedMechanisms:         discovery,
+			expectedBootnodeMechanisms: discovery,
+		},
+		{
+			name: "discovery through a discovery-only bootnode with separate signers",
+			params: &ModuleAPIExecuteArgs{
+				PeeringStrategy: discoveryPeeringStrategy,
+				BootnodeMode:    discoveryOnlyBootnodeMode,
+				SignerCount:     2,
+			},
+			expectedMechanisms: discovery,
+		},
+		{
+			name: "discovery through several discovery-only bootnodes",
+			params: &ModuleAPIExecuteArgs{
+				PeeringStrategy: discoveryPeeringStrategy,
+				BootnodeMode:    discoveryOnlyBootnodeMode,
+				BootnodeCount:   2,
+			},
+			expectedMechanisms: discovery,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			enclave := newFakeEnclave()
+			resultObj, err := executeOnFakeEnclave(enclave, test.params)
+			require.NoError(t, err)
+
+			for serviceId := range resultObj.NodeInfo {
+				for peerServiceId := range resultObj.NodeInfo {
+					if peerServiceId == serviceId {
+						continue
+					}
+					expectedMechanisms := test.expectedMechanisms
+					if serviceId == bootnodeServiceID || peerServiceId == bootnodeServiceID {
+						expectedMechanisms = test.expectedBootnodeMechanisms
+					}
+					require.Equal(
+						t,
+						expectedMechanisms,
+						enclave.getPeeringMechanisms(serviceId, peerServiceId),
+						"Nodes '%v' and '%v' weren't peered through the expected mechanisms",
+						serviceId,
+						peerServiceId,
+					)
+				}
+			}
+		})
+	}
+}
+
+func TestVerifyFullyPeered(t *testing.T) {
+	tests := []struct {
+		name      string
+		nodeCount int
+		peerings  [][2]int
+		// The last nodes are left out of the verification, as if they had just been stopped
+		stoppedNodeCount        int
+		numRecentlyStoppedPeers int
+		isErrExpected           bool
+	}{
+		{
+			name:      "two peered nodes",
+			nodeCount: 2,
+			peerings:  [][2]int{{1, 2}},
+		},
+		{
+			name:          "two unpeered nodes",
+			nodeCount:     2,
+			isErrExpected: true,
+		},
+		{
+			name:          "three nodes with one missing a peer",
+			nodeCount:     3,
+			peerings:      [][2]int{{1, 2}, {2, 3}},
+			isErrExpected: true,
+		},
+		{
+			name:      "three fully peered nodes",
+			nodeCount: 3,
+			peerings:  [][2]int{{1, 2}, {2, 3}, {1, 3}},
+		},
+		{
+			name:                    "two nodes still peered with a recently stopped node",
+			nodeCount:               3,
+			peerings:                [][2]int{{1, 2}, {2, 3}, {1, 3}},
+			stoppedNodeCount:        1,
+			numRecentlyStoppedPeers: 1,
+		},
+		{
+			name:             "two nodes peered with an unexpected node",
+			nodeCount:        3,
+			peerings:         [][2]int{{1, 2}, {2, 3}, {1, 3}},
+			stoppedNodeCount: 1,
+			isErrExpected:    true,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			enclave := newFakeEnclave()
+			serviceCtxs := map[services.ServiceID]*services.ServiceContext{}
+			for i := 1; i <= test.nodeCount; i++ {
+				serviceId := getChildEthNodeServiceId(i)
+				nodeFilesArtifactUuid, err := uploadNodeFiles(enclave, "", serviceId, "")
+				require.NoError(t, err)
+				containerConfig := services.NewContainerConfigBuilder(ethereumDockerImageName).WithUsedPorts(usedPorts).WithFiles(map[services.FilesArtifactUUID]string{
+					nodeFilesArtifactUuid: nodeFilesMountpointOnNodes,
+				}).Build()
+				serviceCtx, err := enclave.AddService(serviceId, containerConfig)
+				require.NoError(t, err)
+				serviceCtxs[serviceId] = serviceCtx
+			}
+			for _, peering := range test.peerings {
+				peerEnode, err := enclave.getEnode(getChildEthNodeServiceId(peering[1]))
+				require.NoError(t, err)
+				require.True(t, enclave.addPeer(getChildEthNodeServiceId(peering[0]), peerEnode))
+			}
+
+			verifiedServiceCtxs := map[services.ServiceID]*services.ServiceContext{}
+			for i := 1; i <= test.nodeCount-test.stoppedNodeCount; i++ {
+				serviceId := getChildEthNodeServiceId(i)
+				verifiedServiceCtxs[serviceId] = serviceCtxs[serviceId]
+			}
+			launchConfig := &nodeLaunchConfig{
+				dockerImage:     ethereumDockerImageName,
+				peeringStrategy: adminAddPeerPeeringStrategy,
+				rpcTransport:    enclave,
+			}
+			err := verifyFullyPeered(verifiedServiceCtxs, launchConfig, testMaxConcurrency, test.numRecentlyStoppedPeers)
+			if test.isErrExpected {
+				require.Error(t, err)
+			} else {
+				require.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestExecute_DuplicateServiceIds(t *testing.T) {
+	tests := []struct {
+		name string
+		// A service that's already in the enclave before the network gets started
+		preexistingServiceId services.ServiceID
+		nodes                []*ModuleAPINodeSpec
+	}{
+		{
+			name:                 "child node ID taken in the enclave",
+			preexistingServiceId: getChildEthNodeServiceId(1),
+		},
+		{
+			name:                 "bootnode ID taken in the enclave",
+			preexistingServiceId: bootnodeServiceID,
+		},
+		{
+			name: "two node specs with the same ID",
+			nodes: []*ModuleAPINodeSpec{
+				{ServiceID: getChildEthNodeServiceId(1)},
+				{ServiceID: getChildEthNodeServiceId(1)},
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			enclave := newFakeEnclave()
+			if test.preexistingServiceId != "" {
+				_, err := enclave.AddService(test.preexistingServiceId, services.NewContainerConfigBuilder(ethereumDockerImageName).Build())
+				require.NoError(t, err)
+			}
+
+			_, err := executeOnFakeEnclave(enclave, &ModuleAPIExecuteArgs{Nodes: test.nodes})
+			require.Error(t, err)
+
+			// The services that the execution added are rolled back, leaving only the one that was there before
+			serviceIds, err := enclave.GetServices()
+			require.NoError(t, err)
+			expectedServiceIds := map[services.ServiceID]bool{}
+			if test.preexistingServiceId != "" {
+				expectedServiceIds[test.preexistingServiceId] = true
+			}
+			require.Equal(t, expectedServiceIds, serviceIds)
+		})
+	}
+}
+
+func TestExecute_NodeAvailabilityTimeouts(t *testing.T) {
+	tests := []struct {
+		name                 string
+		unavailableServiceId services.ServiceID
+		params               *ModuleAPIExecuteArgs
+	}{
+		{
+			name:                 "bootnode",
+			unavailableServiceId: bootnodeServiceID,
+			params:               &ModuleAPIExecuteArgs{},
+		},
+		{
+			name:                 "child node",
+			unavailableServiceId: getChildEthNodeServiceId(2),
+			params:               &ModuleAPIExecuteArgs{},
+		},
+		{
+			name:                 "signer node",
+			unavailableServiceId: getSignerNodeServiceId(firstSignerNodeIdx),
+			params:               &ModuleAPIExecuteArgs{BootnodeMode: discoveryOnlyBootnodeMode, SignerCount: 2},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			enclave := newFakeEnclave()
+			enclave.setUnavailable(test.unavailableServiceId)
+
+			_, err := executeOnFakeEnclave(enclave, test.params)
+			require.Error(t, err)
+			require.Contains(t, err.Error(), string(test.unavailableServiceId))
+
+			serviceIds, err := enclave.GetServices()
+			require.NoError(t, err)
+			require.Empty(t, serviceIds)
+		})
+	}
+}
+
+// Returns a new instance of the module whose RPC calls to the nodes get sent to the given fake enclave
+func newFakeEnclaveModule(enclave *fakeEnclave) *EthereumKurtosisModule {
+	module := NewEthereumKurtosisModule()
+	module.rpcTransport = enclave
+	return module
+}
+
+// Creates a network in the given fake enclave with a new instance of the module, the way Execute does with the given params
+func executeOnFakeEnclave(enclave *fakeEnclave, params *ModuleAPIExecuteArgs) (*ModuleAPIExecuteResult, error) {
+	if err := applyDefaultsAndValidateParams(params); err != nil {
+		return nil, err
+	}
+	return newFakeEnclaveModule(enclave).executeValidatedParams(enclave, params)
+}
@@ -0,0 +1,106 @@
+package impl
+
+import (
+	"encoding/json"
+	"github.com/kurtosis-tech/kurtosis-sdk/api/golang/core/lib/services"
+	"github.com/kurtosis-tech/stacktrace"
+	"strconv"
+)
+
+const (
+	resourceDefaultsEnvVar = "ETHEREUM_KURTOSIS_MODULE_RESOURCE_DEFAULTS"
+
+	// Docker refuses to start a container with a smaller memory limit
+	minMemoryMegabytes = 6
+)
+
+// Fills in the roles that have no defaults with limits of 0, which leave the resources unlimited
+func applyDefaultsAndValidateResourceDefaults(resourceDefaults *ModuleAPIResourceDefaults) (*ModuleAPIResourceDefaults, error) {
+	if resourceDefaults == nil {
+		resourceDefaults = &ModuleAPIResourceDefaults{}
+	}
+	roles := []string{"bootnode", "signer", "archive_node", "full_node"}
+	roleLimits := []**ModuleAPIResourceLimits{
+		&resourceDefaults.Bootnode,
+		&resourceDefaults.Signer,
+		&resourceDefaults.ArchiveNode,
+		&resourceDefaults.FullNode,
+	}
+	for i, limits := range roleLimits {
+		if *limits == nil {
+			*limits = &ModuleAPIResourceLimits{}
+		}
+		if err := validateMemoryLimit((*limits).MemoryMegabytes); err != nil {
+			return nil, stacktrace.Propagate(err, "The default resource limits of role '%v' were invalid", roles[i])
+		}
+	}
+	return resourceDefaults, nil
+}
+
+func validateMemoryLimit(memoryMegabytes uint64) error {
+	if memoryMegabytes != 0 && memoryMegabytes < minMemoryMegabytes {
+		return stacktrace.NewError("A memory limit of %vMB was requested, but the smallest one that Docker accepts is %vMB", memoryMegabytes, minMemoryMegabytes)
+	}
+	return nil
+}
+
+// Gives the node the default limits of its role for the resources that its spec doesn't limit itself
+// Signers have the signer role whatever their GC mode is, and the other nodes have the role of their GC mode
+func applyResourceDefaults(nodeSpec *ModuleAPINodeSpec, resourceDefaults *ModuleAPIResourceDefaults) {
+	roleLimits := resourceDefaults.FullNode
+	if isSignerNodeServiceId(nodeSpec.ServiceID) {
+		roleLimits = resourceDefaults.Signer
+	} else if nodeSpec.GcMode == archiveGcMode {
+		roleLimits = resourceDefaults.ArchiveNode
+	}
+	if nodeSpec.CpuMillicpus == 0 {
+		nodeSpec.CpuMillicpus = roleLimits.CpuMillicpus
+	}
+	if nodeSpec.MemoryMegabytes == 0 {
+		nodeSpec.MemoryMegabytes = roleLimits.MemoryMegabytes
+	}
+}
+
+// Reads the resource defaults that the network was started with from the given bootnode, falling back to no limits for
+// networks started before resource limits existed
+func getResourceDefaults(bootnodeServiceCtx *services.ServiceContext) (*ModuleAPIResourceDefaults, error) {
+	resourceDefaults := &ModuleAPIResourceDefaults{}
+	exitCode, serializedResourceDefaults, err := bootnodeServiceCtx.ExecCommand([]string{"printenv", resourceDefaultsEnvVar})
+	if err != nil {
+		return nil, stacktrace.Propagate(err, "An error occurred reading the resource defaults of bootnode '%v'", bootnodeServiceCtx.GetServiceID())
+	}
+	if exitCode == execCommandSuccessExitCode {
+		if err := json.Unmarshal([]byte(serializedResourceDefaults), resourceDefaults); err != nil {
+			return nil, stacktrace.Propagate(err, "An error occurred deserializing resource defaults '%v'", serializedResourceDefaults)
+		}
+	}
+	validatedResourceDefaults, err := applyDefaultsAndValidateResourceDefaults(resourceDefaults)
+	if err != nil {
+		return nil, stacktrace.Propagate(err, "Bootnode '%v' was started with invalid resource defaults", bootnodeServiceCtx.GetServiceID())
+	}
+	return validatedResourceDefaults, nil
+}
+
+// Returns the environment variables of a bootnode container, which record the settings of the network for later instances
+// of the module
+func getBootnodeEnvVars(launchConfig *nodeLaunchConfig) (map[string]string, error) {
+	serializedResourceDefaults, err := json.Marshal(launchConfig.resourceDefaults)
+	if err != nil {
+		return nil, stacktrace.Propagate(err, "An error occurred serializing the resource defaults")
+	}
+	envVars := map[string]string{
+		peeringStrategyEnvVar:  launchConfig.peeringStrategy,
+		resourceDefaultsEnvVar: string(serializedResourceDefaults),
+	}
+	if err := setHardenedRpcEnvVar(envVars, launchConfig); err != nil {
+		return nil, stacktrace.Propagate(err, "An error occurred recording the hardened RPC settings")
+	}
+	envVars[staticFilesArtifactUuidEnvVar] = string(launchConfig.staticFiles.filesArtifactUuid)
+	if launchConfig.staticFiles.isCallerSupplied {
+		envVars[callerStaticFilesEnvVar] = strconv.FormatBool(true)
+	}
+	if launchConfig.keySeed != "" {
+		envVars[keySeedEnvVar] = launchConfig.keySeed
+	}
+	return envVars, nil
+}
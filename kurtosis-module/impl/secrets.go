@@ -0,0 +1,159 @@
+package impl
+
+import (
+	static_files_consts "github.com/kurtosis-tech/ethereum-kurtosis-module/kurtosis-module/static-files-consts"
+	"github.com/kurtosis-tech/stacktrace"
+	"github.com/sirupsen/logrus"
+	"io/ioutil"
+	"os"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+)
+
+const (
+	redactedSecretPlaceholder = "[REDACTED]"
+
+	// Shorter values would mask ordinary words of the logs, and no secret of the module is that short
+	minRegisteredSecretLength = 8
+
+	// Where the secrets of the result go
+	inlineSecretsOutput        = "inline"
+	filesArtifactSecretsOutput = "files_artifact"
+
+	secretsFilesTempDirPattern       = "secrets-"
+	engineApiJwtSecretOutputFilename = "engine-api-jwt-secret"
+	rpcJwtSecretOutputFilename       = "rpc-jwt-secret"
+	dnsTreeSigningKeyOutputFilename  = "dns-tree-signing-key"
+)
+
+// Values of the JSON fields holding secrets, in the params, the results and the keystores
+var secretJsonFieldRegex = regexp.MustCompile(
+	`("(?:signer_keystore_content|signer_account_password|engine_api_jwt_secret|rpc_jwt_secret|signing_key|key_seed|private_key|keystore_password|password|ciphertext)"\s*:\s*)"(?:[^"\\]|\\.)*"`,
+)
+
+// Arrays and objects of the JSON fields holding secrets in the params, which are the private keys and the passwords of the
+// imported accounts
+var secretJsonCollectionFieldRegex = regexp.MustCompile(
+	`("(?:private_keys|keystore_passwords)"\s*:\s*)(?:\[(?:[^\[\]{}"]|"(?:[^"\\]|\\.)*")*\]|\{(?:[^\[\]{}"]|"(?:[^"\\]|\\.)*")*\})`,
+)
+
+// The tokens that the module signs its calls to the JWT-authenticated endpoints with
+var jwtRegex = regexp.MustCompile(`eyJ[A-Za-z0-9_-]*\.[A-Za-z0-9_-]+\.[A-Za-z0-9_-]+`)
+
+// The secrets that the module made or read so far, which get masked wherever they show up in the logs
+var registeredSecrets = &secretRegistry{
+	secrets: map[string]bool{},
+}
+
+type secretRegistry struct {
+	mutex   sync.RWMutex
+	secrets map[string]bool
+}
+
+// Makes the logs mask the given secret from now on
+func registerSecret(secret string) {
+	secret = strings.TrimSpace(secret)
+	if len(secret) < minRegisteredSecretLength {
+		return
+	}
+	registeredSecrets.mutex.Lock()
+	defer registeredSecrets.mutex.Unlock()
+	registeredSecrets.secrets[secret] = true
+}
+
+// Masks the private keys, passwords, JWT secrets and keystores in the given text
+func RedactSecrets(text string) string {
+	registeredSecrets.mutex.RLock()
+	secrets := []string{}
+	for secret := range registeredSecrets.secrets {
+		secrets = append(secrets, secret)
+	}
+	registeredSecrets.mutex.RUnlock()
+	// A secret can contain another, like a keystore contains the address of its account, so the longest go first
+	sort.Slice(secrets, func(i, j int) bool {
+		return len(secrets[i]) > len(secrets[j])
+	})
+	for _, secret := range secrets {
+		text = strings.ReplaceAll(text, secret, redactedSecretPlaceholder)
+	}
+	text = secretJsonFieldRegex.ReplaceAllString(text, `${1}"`+redactedSecretPlaceholder+`"`)
+	text = secretJsonCollectionFieldRegex.ReplaceAllString(text, `${1}"`+redactedSecretPlaceholder+`"`)
+	return jwtRegex.ReplaceAllString(text, redactedSecretPlaceholder)
+}
+
+// Masks the secrets in everything that the wrapped formatter writes
+type redactingFormatter struct {
+	formatter logrus.Formatter
+}
+
+func newRedactingFormatter(formatter logrus.Formatter) *redactingFormatter {
+	return &redactingFormatter{formatter: formatter}
+}
+
+func (formatter *redactingFormatter) Format(entry *logrus.Entry) ([]byte, error) {
+	// The message is masked before being formatted too, as formatters can escape it in ways that hide the secrets
+	entry.Message = RedactSecrets(entry.Message)
+	formatted, err := formatter.formatter.Format(entry)
+	if err != nil {
+		return nil, err
+	}
+	return []byte(RedactSecrets(string(formatted))), nil
+}
+
+func applyDefaultsAndValidateSecretsOutput(params *ModuleAPIExecuteArgs) error {
+	switch params.SecretsOutput {
+	case "":
+		params.SecretsOutput = inlineSecretsOutput
+	case inlineSecretsOutput, filesArtifactSecretsOutput:
+	default:
+		return stacktrace.NewError(
+			"Unrecognized secrets output '%v'; valid values are '%v' and '%v'",
+			params.SecretsOutput,
+			inlineSecretsOutput,
+			filesArtifactSecretsOutput,
+		)
+	}
+	return nil
+}
+
+// Writes the secrets of the result to a files artifact, which the result references in their place
+func moveSecretsToFilesArtifact(enclaveCtx moduleEnclave, resultObj *ModuleAPIExecuteResult, signerKeystoreFilename string) error {
+	tempDirpath, err := ioutil.TempDir("", secretsFilesTempDirPattern)
+	if err != nil {
+		return stacktrace.Propagate(err, "An error occurred creating a temporary directory for the secrets")
+	}
+	defer os.RemoveAll(tempDirpath)
+
+	secretFileContents := map[string]*string{
+		signerKeystoreFilename: &resultObj.SignerKeystoreContent,
+		static_files_consts.SignerAccountPasswordStaticFileName: &resultObj.SignerAccountPassword,
+		engineApiJwtSecretOutputFilename:                        &resultObj.EngineApiJwtSecret,
+		rpcJwtSecretOutputFilename:                              &resultObj.RpcJwtSecret,
+	}
+	if resultObj.DnsDiscovery != nil {
+		secretFileContents[dnsTreeSigningKeyOutputFilename] = &resultObj.DnsDiscovery.SigningKey
+	}
+	for _, account := range resultObj.Accounts {
+		secretFileContents[account.Address+accountPrivateKeyOutputFilenameSuffix] = &account.PrivateKey
+	}
+	for filename, contents := range secretFileContents {
+		if *contents == "" {
+			continue
+		}
+		filepath := path.Join(tempDirpath, filename)
+		if err := ioutil.WriteFile(filepath, []byte(*contents), nodeFilePerms); err != nil {
+			return stacktrace.Propagate(err, "An error occurred writing secret file '%v'", filepath)
+		}
+		*contents = ""
+	}
+
+	secretsFilesArtifactUuid, err := enclaveCtx.UploadFiles(tempDirpath)
+	if err != nil {
+		return stacktrace.Propagate(err, "An error occurred uploading the secrets")
+	}
+	resultObj.SecretsFilesArtifactUUID = secretsFilesArtifactUuid
+	return nil
+}
@@ -0,0 +1,41 @@
+package impl
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRedactSecrets_ImportedAccountsParams(t *testing.T) {
+	tests := []struct {
+		name     string
+		text     string
+		expected string
+	}{
+		{
+			name:     "private keys",
+			text:     `{"imported_accounts": {"private_keys": ["0x4c0883a69102937d6231471b5dbb6204fe512961708279f9d7d1b3e1c4d9f1a1", "0x8da4ef21b864d2cc526dbdb2a120bd2874c36c9d0a1fb7f8c63d7f7a8b41de8f"]}}`,
+			expected: `{"imported_accounts": {"private_keys": "[REDACTED]"}}`,
+		},
+		{
+			name:     "keystore password",
+			text:     `{"keystore_password": "correct horse battery staple"}`,
+			expected: `{"keystore_password": "[REDACTED]"}`,
+		},
+		{
+			name:     "keystore passwords",
+			text:     `{"keystore_passwords": {"UTC--account-1": "first password", "UTC--account-2": "second \"password\""}, "genesis_balance_wei": "1"}`,
+			expected: `{"keystore_passwords": "[REDACTED]", "genesis_balance_wei": "1"}`,
+		},
+		{
+			name:     "no secrets",
+			text:     `{"action": "create", "node_count": 2}`,
+			expected: `{"action": "create", "node_count": 2}`,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			require.Equal(t, test.expected, RedactSecrets(test.text))
+		})
+	}
+}
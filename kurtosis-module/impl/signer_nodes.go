@@ -0,0 +1,220 @@
+package impl
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"encoding/hex"
+	"encoding/json"
+	"github.com/ethereum/go-ethereum/common"
+	static_files_consts "github.com/kurtosis-tech/ethereum-kurtosis-module/kurtosis-module/static-files-consts"
+	"github.com/kurtosis-tech/kurtosis-sdk/api/golang/core/lib/services"
+	"github.com/kurtosis-tech/stacktrace"
+	"io/ioutil"
+	"os"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	signerNodeServiceIdPrefix = "signer-node-"
+	// The first signer seals with the account whose keystore is in the static files, so that account keeps sealing blocks
+	firstSignerNodeIdx = 1
+
+	// Mixed into the account keys of the extra signers, so they don't collide with the node keys derived from the same service IDs
+	signerAccountKeySeed     = "ethereum-kurtosis-module-signer-account-key"
+	signerAccountKeyFilename = "signer-account-key"
+
+	networkFilesTempDirPattern    = "network-files-"
+	networkFilesMountpointOnNodes = "/network-files"
+
+	genesisExtradataField = "extradata"
+	// Clique's extradata is 32 bytes of vanity, the addresses of the signers and a 65-byte seal
+	cliqueExtradataVanityLength = 32
+	cliqueExtradataSealLength   = 65
+)
+
+// Returns the key of the account that the given signer seals with: the imported account at the position of the signer when
+// accounts were imported, and otherwise a key derived from its service ID and the key seed of the network
+// The signer that uses the account whose keystore is in the static files has no key
+func getSignerAccountKey(launchConfig *nodeLaunchConfig, serviceId services.ServiceID) (*ecdsa.PrivateKey, error) {
+	if len(launchConfig.importedAccountKeys) != 0 {
+		for i, signerServiceId := range getSignerServiceIds(launchConfig) {
+			if signerServiceId != serviceId {
+				continue
+			}
+			if i >= len(launchConfig.importedAccountKeys) {
+				return nil, stacktrace.NewError("Signer '%v' has no imported account to seal with", serviceId)
+			}
+			return launchConfig.importedAccountKeys[i], nil
+		}
+		return nil, stacktrace.NewError("Node '%v' isn't a signer of the network", serviceId)
+	}
+	if usesStaticSignerAccount(launchConfig, serviceId) {
+		return nil, nil
+	}
+	accountKey, err := deriveKey(signerAccountKeySeed, launchConfig.keySeed, string(serviceId))
+	if err != nil {
+		return nil, stacktrace.Propagate(err, "An error occurred deriving the account key of signer '%v'", serviceId)
+	}
+	return accountKey, nil
+}
+
+func getSignerAccountAddress(launchConfig *nodeLaunchConfig, serviceId services.ServiceID) (string, error) {
+	accountKey, err := getSignerAccountKey(launchConfig, serviceId)
+	if err != nil {
+		return "", stacktrace.Propagate(err, "An error occurred getting the account key of signer '%v'", serviceId)
+	}
+	if accountKey == nil {
+		return getAccountAddress(launchConfig.staticFiles.signerAccountKey), nil
+	}
+	return getAccountAddress(accountKey), nil
+}
+
+// Only one of the bootnode and the first signer seals blocks in a network, with the account whose keystore is in the static
+// files, unless accounts were imported in its place
+func usesStaticSignerAccount(launchConfig *nodeLaunchConfig, serviceId services.ServiceID) bool {
+	if len(launchConfig.importedAccountKeys) != 0 {
+		return false
+	}
+	return serviceId == bootnodeServiceID || serviceId == getSignerNodeServiceId(firstSignerNodeIdx)
+}
+
+func getSignerNodeServiceId(signerIdx int) services.ServiceID {
+	return services.ServiceID(signerNodeServiceIdPrefix + strconv.Itoa(signerIdx))
+}
+
+// Returns the service IDs of the nodes that unlock a signer account, starting with the one whose keystore is in the static files
+func getSignerServiceIds(launchConfig *nodeLaunchConfig) []services.ServiceID {
+	if launchConfig.bootnodeMode != discoveryOnlyBootnodeMode {
+		return []services.ServiceID{bootnodeServiceID}
+	}
+	result := []services.ServiceID{}
+	for i := firstSignerNodeIdx; i < firstSignerNodeIdx+int(launchConfig.signerCount); i++ {
+		result = append(result, getSignerNodeServiceId(i))
+	}
+	return result
+}
+
+func isSignerNodeServiceId(serviceId services.ServiceID) bool {
+	return strings.HasPrefix(string(serviceId), signerNodeServiceIdPrefix)
+}
+
+// Returns the specs of the signer nodes, which run with the default spec and the resource defaults of the signer role
+func getSignerNodeSpecs(signerCount uint32, resourceDefaults *ModuleAPIResourceDefaults) ([]*ModuleAPINodeSpec, error) {
+	result := []*ModuleAPINodeSpec{}
+	for i := firstSignerNodeIdx; i < firstSignerNodeIdx+int(signerCount); i++ {
+		nodeSpec := &ModuleAPINodeSpec{ServiceID: getSignerNodeServiceId(i)}
+		if err := applyDefaultsAndValidateNodeSpec(nodeSpec); err != nil {
+			return nil, stacktrace.Propagate(err, "The default spec of signer '%v' was invalid; this is a bug with this module", nodeSpec.ServiceID)
+		}
+		applyResourceDefaults(nodeSpec, resourceDefaults)
+		result = append(result, nodeSpec)
+	}
+	return result, nil
+}
+
+// Uploads the files shared by all the nodes of a network whose genesis is rendered, which is only the rendered genesis
+func uploadNetworkFiles(enclaveCtx moduleEnclave, launchConfig *nodeLaunchConfig) (services.FilesArtifactUUID, error) {
+	serializedGenesis, err := renderNetworkGenesis(launchConfig)
+	if err != nil {
+		return "", stacktrace.Propagate(err, "An error occurred rendering the genesis of the network")
+	}
+
+	tempDirpath, err := ioutil.TempDir("", networkFilesTempDirPattern)
+	if err != nil {
+		return "", stacktrace.Propagate(err, "An error occurred creating a temporary directory for the network files")
+	}
+	defer os.RemoveAll(tempDirpath)
+	genesisFilepath := path.Join(tempDirpath, static_files_consts.GenesisStaticFileName)
+	if err := ioutil.WriteFile(genesisFilepath, serializedGenesis, nodeFilePerms); err != nil {
+		return "", stacktrace.Propagate(err, "An error occurred writing the genesis to '%v'", genesisFilepath)
+	}
+
+	networkFilesArtifactUuid, err := enclaveCtx.UploadFiles(tempDirpath)
+	if err != nil {
+		return "", stacktrace.Propagate(err, "An error occurred uploading the network files")
+	}
+	return networkFilesArtifactUuid, nil
+}
+
+// Renders the genesis of the static files with a Clique extradata that authorizes every signer, funding the imported
+// accounts if any
+func renderNetworkGenesis(launchConfig *nodeLaunchConfig) ([]byte, error) {
+	// Numbers are kept as they were written, so the rest of the genesis isn't changed by the round trip
+	decoder := json.NewDecoder(bytes.NewReader(launchConfig.staticFiles.genesis))
+	decoder.UseNumber()
+	genesis := map[string]interface{}{}
+	if err := decoder.Decode(&genesis); err != nil {
+		return nil, stacktrace.Propagate(err, "An error occurred deserializing the genesis of the static files")
+	}
+
+	signerAddrs := []common.Address{}
+	for _, signerServiceId := range getSignerServiceIds(launchConfig) {
+		addrStr, err := getSignerAccountAddress(launchConfig, signerServiceId)
+		if err != nil {
+			return nil, stacktrace.Propagate(err, "An error occurred getting the account address of signer '%v'", signerServiceId)
+		}
+		signerAddrs = append(signerAddrs, common.HexToAddress(addrStr))
+	}
+	sort.Slice(signerAddrs, func(i, j int) bool {
+		return bytes.Compare(signerAddrs[i].Bytes(), signerAddrs[j].Bytes()) < 0
+	})
+	extradata := make([]byte, cliqueExtradataVanityLength)
+	for _, signerAddr := range signerAddrs {
+		extradata = append(extradata, signerAddr.Bytes()...)
+	}
+	extradata = append(extradata, make([]byte, cliqueExtradataSealLength)...)
+	genesis[genesisExtradataField] = "0x" + hex.EncodeToString(extradata)
+	if len(launchConfig.importedAccountKeys) != 0 {
+		if err := setImportedAccountsAlloc(genesis, launchConfig); err != nil {
+			return nil, stacktrace.Propagate(err, "An error occurred funding the imported accounts in the genesis")
+		}
+	}
+
+	serializedGenesis, err := json.MarshalIndent(genesis, jsonOutputPrefixStr, jsonOutputIndentStr)
+	if err != nil {
+		return nil, stacktrace.Propagate(err, "An error occurred serializing the genesis with %v signers", len(signerAddrs))
+	}
+	return serializedGenesis, nil
+}
+
+// Returns the command that imports the account of the given signer into its keystore, or an empty string for the signer
+// that reads its account from the static files
+// With the "clef" signer backend, the account is imported into the keystore of the signer's Clef instead
+func getSignerImportCommand(launchConfig *nodeLaunchConfig, serviceId services.ServiceID) (string, error) {
+	if usesStaticSignerAccount(launchConfig, serviceId) || !isAccountUnlockedOnNode(launchConfig, serviceId) {
+		return "", nil
+	}
+	return newGethCommandBuilder(
+		launchConfig.dockerImage,
+		"account",
+		"import",
+	).withFlag(
+		"datadir", gethDataDirpath,
+	).withFlag(
+		"password", getMountedPathOnNodeContainer(static_files_consts.SignerAccountPasswordStaticFileName),
+	).withArg(
+		path.Join(accountFilesMountpointOnNodes, signerAccountKeyFilename),
+	).build()
+}
+
+// Adds the flags that unlock the account of the given signer and, if the node is mining, make it seal blocks with it
+func withSignerFlags(builder *commandBuilder, launchConfig *nodeLaunchConfig, serviceId services.ServiceID, isMining bool) (*commandBuilder, error) {
+	if isAccountUnlockedOnNode(launchConfig, serviceId) {
+		accountAddr, err := getSignerAccountAddress(launchConfig, serviceId)
+		if err != nil {
+			return nil, stacktrace.Propagate(err, "An error occurred getting the account address of signer '%v'", serviceId)
+		}
+		builder.withFlag(
+			"unlock", accountAddr,
+		).withFlag(
+			"password", getMountedPathOnNodeContainer(static_files_consts.SignerAccountPasswordStaticFileName),
+		)
+	}
+	if isMining {
+		builder.withBoolFlag("mine")
+	}
+	return builder, nil
+}
@@ -0,0 +1,397 @@
+package impl
+
+import (
+	"crypto/ecdsa"
+	"github.com/ethereum/go-ethereum/p2p/enode"
+	"github.com/kurtosis-tech/kurtosis-sdk/api/golang/core/lib/services"
+	"github.com/kurtosis-tech/stacktrace"
+	"github.com/sirupsen/logrus"
+	"time"
+)
+
+const (
+	// The simulated nodes listen on loopback ports of the process that starts them
+	simulatedNodeIpAddr = "127.0.0.1"
+)
+
+// Starts the nodes of a simulated network in the current process; the module only describes the nodes, so that the
+// in-process Ethereum node doesn't get built into the module itself
+type SimulatedNodeLauncher interface {
+	// Starts a node that only runs discovery, like Geth's bootnode binary
+	StartDiscoveryBootnode(serviceId services.ServiceID, nodeKey *ecdsa.PrivateKey) (SimulatedNode, error)
+	StartGethNode(config *SimulatedGethNodeConfig) (SimulatedNode, error)
+}
+
+// A node started by a simulated node launcher
+type SimulatedNode interface {
+	GetRecord() *enode.Node
+	// Empty for a node that only runs discovery
+	GetRpcUrl() string
+	GetPeerCount() int
+	AddPeer(record *enode.Node)
+	Stop() error
+}
+
+// What a Geth node of a simulated network gets started with, which is what the Geth config of the node in an enclave holds
+type SimulatedGethNodeConfig struct {
+	ServiceID services.ServiceID
+	NodeKey   *ecdsa.PrivateKey
+
+	// Every node parses the genesis for itself, as a node fills in whatever its genesis leaves out
+	Genesis   []byte
+	NetworkId uint64
+	// Geth's sync mode, or empty for Geth's default, along with whether the node keeps every state like an archive node
+	SyncMode  string
+	NoPruning bool
+
+	HttpModules []string
+
+	BootnodeRecords []*enode.Node
+	StaticNodes     []*enode.Node
+	TrustedNodes    []*enode.Node
+
+	// Only set for the signers, which seal blocks with the account of this key
+	SignerAccountKey *ecdsa.PrivateKey
+}
+
+// A network started in the current process by StartSimulatedNetwork, in place of containers in an enclave
+type SimulatedNetwork struct {
+	// In the order they were started, which is the reverse of the order they're stopped in
+	nodes []*simulatedNetworkNode
+}
+
+type simulatedNetworkNode struct {
+	serviceId       services.ServiceID
+	isDiscoveryOnly bool
+	node            SimulatedNode
+}
+
+// Starts the network described by the given execute params through the given launcher, returning it along with the result
+// that executing the module with the same params returns; only the "create" action in "network" mode is supported
+func StartSimulatedNetwork(
+	launcher SimulatedNodeLauncher,
+	params *ModuleAPIExecuteArgs,
+) (resultNetwork *SimulatedNetwork, resultObj *ModuleAPIExecuteResult, resultError error) {
+	if params == nil {
+		params = &ModuleAPIExecuteArgs{}
+	}
+	if err := applyDefaultsAndValidateParams(params); err != nil {
+		return nil, nil, stacktrace.Propagate(err, "The execute params were invalid")
+	}
+	if err := validateSimulatedParams(params); err != nil {
+		return nil, nil, stacktrace.Propagate(err, "The execute params can't be simulated")
+	}
+
+	fileContents, err := getEmbeddedStaticFileContents()
+	if err != nil {
+		return nil, nil, stacktrace.Propagate(err, "An error occurred reading the static files built into the module")
+	}
+	staticFiles, err := newStaticFiles("", fileContents)
+	if err != nil {
+		return nil, nil, stacktrace.Propagate(err, "The static files built into the module were invalid; this is a bug with this module")
+	}
+	launchConfig := &nodeLaunchConfig{
+		staticFiles:      staticFiles,
+		peeringStrategy:  params.PeeringStrategy,
+		bootnodeMode:     params.BootnodeMode,
+		signerCount:      params.SignerCount,
+		bootnodeCount:    params.BootnodeCount,
+		nodeDiscovery:    params.NodeDiscovery,
+		resourceDefaults: params.ResourceDefaults,
+		signerBackend:    params.SignerBackend,
+		keySeed:          params.KeySeed,
+	}
+	if params.ImportedAccounts != nil {
+		// The keystores files artifact was rejected, so there's nothing to read from an enclave
+		importedAccountKeys, err := getImportedAccountKeys(nil, params.ImportedAccounts, len(getSignerServiceIds(launchConfig)))
+		if err != nil {
+			return nil, nil, stacktrace.Propagate(err, "An error occurred getting the keys of the imported accounts")
+		}
+		launchConfig.importedAccountKeys = importedAccountKeys
+		launchConfig.genesisBalanceWei = params.ImportedAccounts.GenesisBalanceWei
+	}
+	genesis := staticFiles.genesis
+	if isGenesisRendered(launchConfig) {
+		genesis, err = renderNetworkGenesis(launchConfig)
+		if err != nil {
+			return nil, nil, stacktrace.Propagate(err, "An error occurred rendering the genesis of the network")
+		}
+	} else if err := verifyStaticSignerInGenesis(staticFiles); err != nil {
+		return nil, nil, stacktrace.Propagate(err, "The signer of the static files can't seal blocks with their genesis")
+	}
+
+	network := &SimulatedNetwork{nodes: []*simulatedNetworkNode{}}
+	defer func() {
+		if resultError == nil {
+			return
+		}
+		if err := network.Stop(); err != nil {
+			logrus.Errorf("Starting the simulated network failed, and stopping the nodes it started failed too:\n%v", err)
+		}
+	}()
+
+	startupPhases := []*ModuleAPIStartupPhase{}
+	phaseStartTime := time.Now()
+	bootnodeRecords := []*enode.Node{}
+	nodeSpecs := []*ModuleAPINodeSpec{}
+	if launchConfig.bootnodeMode == discoveryOnlyBootnodeMode {
+		for _, serviceId := range getBootnodeServiceIds(launchConfig) {
+			nodeKey, err := getNodeKey(launchConfig.keySeed, serviceId)
+			if err != nil {
+				return nil, nil, stacktrace.Propagate(err, "An error occurred getting the node key of bootnode '%v'", serviceId)
+			}
+			bootnode, err := launcher.StartDiscoveryBootnode(serviceId, nodeKey)
+			if err != nil {
+				return nil, nil, stacktrace.Propagate(err, "An error occurred starting simulated bootnode '%v'", serviceId)
+			}
+			network.nodes = append(network.nodes, &simulatedNetworkNode{serviceId: serviceId, isDiscoveryOnly: true, node: bootnode})
+			bootnodeRecords = append(bootnodeRecords, bootnode.GetRecord())
+		}
+		signerNodeSpecs, err := getSignerNodeSpecs(launchConfig.signerCount, launchConfig.resourceDefaults)
+		if err != nil {
+			return nil, nil, stacktrace.Propagate(err, "An error occurred getting the specs of the signer nodes")
+		}
+		nodeSpecs = append(nodeSpecs, signerNodeSpecs...)
+	} else {
+		// The bootnode runs with Geth's defaults, as it does in an enclave
+		nodeSpecs = append(nodeSpecs, &ModuleAPINodeSpec{ServiceID: bootnodeServiceID})
+	}
+	childServiceIds := []services.ServiceID{}
+	childNodeSpecsByServiceId := map[services.ServiceID]*ModuleAPINodeSpec{}
+	for _, nodeSpec := range params.Nodes {
+		childServiceIds = append(childServiceIds, nodeSpec.ServiceID)
+		childNodeSpecsByServiceId[nodeSpec.ServiceID] = nodeSpec
+	}
+	sortServiceIds(childServiceIds)
+	for _, serviceId := range childServiceIds {
+		nodeSpecs = append(nodeSpecs, childNodeSpecsByServiceId[serviceId])
+	}
+
+	// The nodes get started one at a time, so that each one can list the nodes started before it as its peers
+	gethNodes := []*simulatedNetworkNode{}
+	for _, nodeSpec := range nodeSpecs {
+		serviceId := nodeSpec.ServiceID
+		config, err := getSimulatedGethNodeConfig(launchConfig, nodeSpec, genesis, bootnodeRecords, gethNodes)
+		if err != nil {
+			return nil, nil, stacktrace.Propagate(err, "An error occurred getting the config of simulated node '%v'", serviceId)
+		}
+		gethNode, err := launcher.StartGethNode(config)
+		if err != nil {
+			return nil, nil, stacktrace.Propagate(err, "An error occurred starting simulated node '%v'", serviceId)
+		}
+		logrus.Infof("Started simulated Ethereum node '%v' serving RPC at %v", serviceId, gethNode.GetRpcUrl())
+		networkNode := &simulatedNetworkNode{serviceId: serviceId, node: gethNode}
+		network.nodes = append(network.nodes, networkNode)
+		gethNodes = append(gethNodes, networkNode)
+		if serviceId == bootnodeServiceID {
+			bootnodeRecords = append(bootnodeRecords, gethNode.GetRecord())
+		}
+	}
+	startupPhases = recordStartupPhase(startupPhases, nodesStartupPhase, phaseStartTime)
+
+	// Unlike in an enclave, the bootnode gets added too, as it's already up when the others get started
+	if launchConfig.peeringStrategy == adminAddPeerPeeringStrategy {
+		phaseStartTime = time.Now()
+		for i, gethNode := range gethNodes {
+			for _, precedingGethNode := range gethNodes[:i] {
+				gethNode.node.AddPeer(precedingGethNode.node.GetRecord())
+			}
+		}
+		startupPhases = recordStartupPhase(startupPhases, peerConnectionStartupPhase, phaseStartTime)
+	}
+
+	phaseStartTime = time.Now()
+	if err := verifySimulatedNodesFullyPeered(launchConfig, gethNodes); err != nil {
+		return nil, nil, stacktrace.Propagate(err, "An error occurred verifying that the simulated nodes are peered with each other")
+	}
+	startupPhases = recordStartupPhase(startupPhases, peeringVerificationStartupPhase, phaseStartTime)
+
+	allNodeInfo := map[services.ServiceID]*ModuleAPIEthereumNodeInfo{}
+	allBootnodeInfo := map[services.ServiceID]*ModuleAPIBootnodeInfo{}
+	for _, networkNode := range network.nodes {
+		record := networkNode.node.GetRecord()
+		if !networkNode.isDiscoveryOnly {
+			allNodeInfo[networkNode.serviceId] = &ModuleAPIEthereumNodeInfo{
+				IPAddrInsideNetwork: simulatedNodeIpAddr,
+				IPAddrOnHostMachine: simulatedNodeIpAddr,
+				Enode:               record.URLv4(),
+				Enr:                 record.String(),
+				RpcUrl:              networkNode.node.GetRpcUrl(),
+			}
+		}
+		if isBootnodeServiceId(networkNode.serviceId) {
+			allBootnodeInfo[networkNode.serviceId] = &ModuleAPIBootnodeInfo{
+				ServiceID:           networkNode.serviceId,
+				IPAddrInsideNetwork: simulatedNodeIpAddr,
+				IPAddrOnHostMachine: simulatedNodeIpAddr,
+				IsDiscoveryOnly:     networkNode.isDiscoveryOnly,
+				Enode:               record.URLv4(),
+				Enr:                 record.String(),
+			}
+		}
+	}
+	signerServiceIds := getSignerServiceIds(launchConfig)
+	resultObj = &ModuleAPIExecuteResult{
+		BootnodeServiceIDs: getBootnodeServiceIds(launchConfig),
+		NodeInfo:           allNodeInfo,
+		Action:             params.Action,
+		Mode:               params.Mode,
+		BlockProduction:    params.BlockProduction,
+		PeeringStrategy:    params.PeeringStrategy,
+		BootnodeMode:       params.BootnodeMode,
+		BootnodeInfo:       allBootnodeInfo,
+		SignerServiceIDs:   signerServiceIds,
+		NodeDiscovery:      params.NodeDiscovery,
+		StartupPhases:      startupPhases,
+		RpcProfile:         params.RpcProfile,
+		SignerBackend:      params.SignerBackend,
+	}
+	// The account of the static files isn't used by networks with imported accounts, so its keystore isn't returned either
+	if len(launchConfig.importedAccountKeys) == 0 {
+		resultObj.SignerKeystoreContent = staticFiles.signerKeystoreContent
+		resultObj.SignerAccountPassword = staticFiles.signerAccountPassword
+	}
+	accounts, err := getAccountsInfo(launchConfig, signerServiceIds, params.IncludePrivateKeys)
+	if err != nil {
+		return nil, nil, stacktrace.Propagate(err, "An error occurred getting the accounts managed by the module")
+	}
+	resultObj.Accounts = accounts
+
+	return network, resultObj, nil
+}
+
+// Stops every node of the network, which can't be started again
+func (network *SimulatedNetwork) Stop() error {
+	failedServiceIds := []services.ServiceID{}
+	for i := len(network.nodes) - 1; i >= 0; i-- {
+		networkNode := network.nodes[i]
+		if err := networkNode.node.Stop(); err != nil {
+			logrus.Errorf("An error occurred stopping simulated node '%v':\n%v", networkNode.serviceId, err)
+			failedServiceIds = append(failedServiceIds, networkNode.serviceId)
+		}
+	}
+	network.nodes = []*simulatedNetworkNode{}
+	if len(failedServiceIds) != 0 {
+		return stacktrace.NewError("An error occurred stopping simulated nodes %v", failedServiceIds)
+	}
+	return nil
+}
+
+// The simulated nodes are only Geth nodes and discovery bootnodes, so nothing that needs another service, a files artifact
+// or a later Geth than the one the module is built with can be simulated
+func validateSimulatedParams(params *ModuleAPIExecuteArgs) error {
+	if params.Action != CreateAction {
+		return stacktrace.NewError("Only action '%v' can be simulated, but got '%v'", CreateAction, params.Action)
+	}
+	if params.Mode != networkMode {
+		return stacktrace.NewError("Only '%v' mode can be simulated, but got '%v'", networkMode, params.Mode)
+	}
+	if params.BlockProduction != cliqueBlockProduction {
+		return stacktrace.NewError("Only '%v' block production can be simulated, but got '%v'", cliqueBlockProduction, params.BlockProduction)
+	}
+	if params.NodeDiscovery != bootnodesNodeDiscovery {
+		return stacktrace.NewError("Only node discovery '%v' can be simulated, but got '%v'", bootnodesNodeDiscovery, params.NodeDiscovery)
+	}
+	// Geth doesn't dial trusted nodes either, so with both strategies the nodes find each other through discovery, whose table
+	// ignores the nodes that ping it before it's done filling itself; on loopback that's often all of them, so they can take
+	// minutes to find each other
+	if params.PeeringStrategy == discoveryPeeringStrategy || params.PeeringStrategy == trustedNodesPeeringStrategy {
+		return stacktrace.NewError("Peering strategy '%v' can't be simulated, as the nodes can take minutes to discover each other", params.PeeringStrategy)
+	}
+	if params.RpcProfile != openRpcProfile {
+		return stacktrace.NewError("Only RPC profile '%v' can be simulated, but got '%v'", openRpcProfile, params.RpcProfile)
+	}
+	if params.SignerBackend != keystoreSignerBackend {
+		return stacktrace.NewError("Only signer backend '%v' can be simulated, but got '%v'", keystoreSignerBackend, params.SignerBackend)
+	}
+	if params.SecretsOutput != inlineSecretsOutput {
+		return stacktrace.NewError("Only secrets output '%v' can be simulated, but got '%v'", inlineSecretsOutput, params.SecretsOutput)
+	}
+	if params.StaticFilesArtifactUUID != "" {
+		return stacktrace.NewError("A static files artifact can't be simulated, as there's no enclave to read it from")
+	}
+	if params.ImportedAccounts != nil && params.ImportedAccounts.KeystoresFilesArtifactUUID != "" {
+		return stacktrace.NewError("Accounts can't be imported from a keystores files artifact in a simulated network, as there's no enclave to read it from")
+	}
+	return nil
+}
+
+// Returns the config of a simulated Geth node, which lists the nodes started before it as peers when the peering strategy
+// writes peer lists
+// The node spec of the bootnode has no sync or GC mode, so it runs with Geth's defaults
+func getSimulatedGethNodeConfig(
+	launchConfig *nodeLaunchConfig,
+	nodeSpec *ModuleAPINodeSpec,
+	genesis []byte,
+	bootnodeRecords []*enode.Node,
+	precedingGethNodes []*simulatedNetworkNode,
+) (*SimulatedGethNodeConfig, error) {
+	serviceId := nodeSpec.ServiceID
+	nodeKey, err := getNodeKey(launchConfig.keySeed, serviceId)
+	if err != nil {
+		return nil, stacktrace.Propagate(err, "An error occurred getting the node key of node '%v'", serviceId)
+	}
+	config := &SimulatedGethNodeConfig{
+		ServiceID:       serviceId,
+		NodeKey:         nodeKey,
+		Genesis:         genesis,
+		NetworkId:       ethNetworkId,
+		SyncMode:        nodeSpec.SyncMode,
+		NoPruning:       nodeSpec.GcMode == archiveGcMode,
+		HttpModules:     httpApiModules,
+		BootnodeRecords: bootnodeRecords,
+		StaticNodes:     []*enode.Node{},
+		TrustedNodes:    []*enode.Node{},
+	}
+	peerRecords := []*enode.Node{}
+	for _, precedingGethNode := range precedingGethNodes {
+		peerRecords = append(peerRecords, precedingGethNode.node.GetRecord())
+	}
+	switch getPeerListConfigKey(launchConfig.peeringStrategy) {
+	case staticNodesConfigKey:
+		config.StaticNodes = peerRecords
+	case trustedNodesConfigKey:
+		config.TrustedNodes = peerRecords
+	}
+
+	if serviceId == bootnodeServiceID || isSignerNodeServiceId(serviceId) {
+		signerAccountKey, err := getSignerAccountKey(launchConfig, serviceId)
+		if err != nil {
+			return nil, stacktrace.Propagate(err, "An error occurred getting the account key of signer '%v'", serviceId)
+		}
+		if signerAccountKey == nil {
+			signerAccountKey = launchConfig.staticFiles.signerAccountKey
+		}
+		config.SignerAccountKey = signerAccountKey
+	}
+	return config, nil
+}
+
+// Waits until each of the given nodes is peered with all the others, the same way as the nodes of an enclave
+func verifySimulatedNodesFullyPeered(launchConfig *nodeLaunchConfig, gethNodes []*simulatedNetworkNode) error {
+	numExpectedPeersPerNode := len(gethNodes) - 1
+	numValidationAttempts := maxNumPeerCountValidationAttempts
+	if launchConfig.peeringStrategy != adminAddPeerPeeringStrategy {
+		numValidationAttempts = maxNumSelfPeeringPeerCountValidationAttempts
+	}
+	for _, gethNode := range gethNodes {
+		isFullyPeered := false
+		for i := 0; i < numValidationAttempts; i++ {
+			if gethNode.node.GetPeerCount() >= numExpectedPeersPerNode {
+				isFullyPeered = true
+				break
+			}
+			time.Sleep(timeBetweenPeerCountValidationAttempts)
+		}
+		if !isFullyPeered {
+			return stacktrace.NewError(
+				"Simulated node '%v' didn't reach expected number of peers '%v', even after %v attempts with %v between attempts",
+				gethNode.serviceId,
+				numExpectedPeersPerNode,
+				numValidationAttempts,
+				timeBetweenPeerCountValidationAttempts,
+			)
+		}
+	}
+	return nil
+}
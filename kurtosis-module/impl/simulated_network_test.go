@@ -0,0 +1,114 @@
+package impl
+
+import (
+	"crypto/ecdsa"
+	"net"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/p2p/enode"
+	"github.com/kurtosis-tech/kurtosis-sdk/api/golang/core/lib/services"
+	"github.com/kurtosis-tech/stacktrace"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	fakeSimulatedNodePort = 30303
+)
+
+// Starts fake nodes that record whether they were stopped, failing to start the node with the given service ID
+type fakeSimulatedNodeLauncher struct {
+	failingServiceId services.ServiceID
+	startedNodes     map[services.ServiceID]*fakeSimulatedNode
+}
+
+type fakeSimulatedNode struct {
+	record    *enode.Node
+	isStopped bool
+}
+
+func (launcher *fakeSimulatedNodeLauncher) StartDiscoveryBootnode(serviceId services.ServiceID, nodeKey *ecdsa.PrivateKey) (SimulatedNode, error) {
+	return launcher.startNode(serviceId, nodeKey)
+}
+
+func (launcher *fakeSimulatedNodeLauncher) StartGethNode(config *SimulatedGethNodeConfig) (SimulatedNode, error) {
+	return launcher.startNode(config.ServiceID, config.NodeKey)
+}
+
+func (launcher *fakeSimulatedNodeLauncher) startNode(serviceId services.ServiceID, nodeKey *ecdsa.PrivateKey) (SimulatedNode, error) {
+	if serviceId == launcher.failingServiceId {
+		return nil, stacktrace.NewError("Fake launcher failed to start node '%v'", serviceId)
+	}
+	node := &fakeSimulatedNode{
+		record: enode.NewV4(&nodeKey.PublicKey, net.ParseIP(simulatedNodeIpAddr), fakeSimulatedNodePort, fakeSimulatedNodePort),
+	}
+	launcher.startedNodes[serviceId] = node
+	return node, nil
+}
+
+func (node *fakeSimulatedNode) GetRecord() *enode.Node {
+	return node.record
+}
+
+func (node *fakeSimulatedNode) GetRpcUrl() string {
+	return ""
+}
+
+func (node *fakeSimulatedNode) GetPeerCount() int {
+	return 0
+}
+
+func (node *fakeSimulatedNode) AddPeer(record *enode.Node) {}
+
+func (node *fakeSimulatedNode) Stop() error {
+	node.isStopped = true
+	return nil
+}
+
+func TestStartSimulatedNetwork_LauncherError(t *testing.T) {
+	tests := []struct {
+		name                      string
+		params                    *ModuleAPIExecuteArgs
+		failingServiceId          services.ServiceID
+		expectedStartedServiceIds []services.ServiceID
+	}{
+		{
+			name:                      "bootnode",
+			params:                    &ModuleAPIExecuteArgs{},
+			failingServiceId:          bootnodeServiceID,
+			expectedStartedServiceIds: []services.ServiceID{},
+		},
+		{
+			name:                      "child node after the bootnode",
+			params:                    &ModuleAPIExecuteArgs{},
+			failingServiceId:          getChildEthNodeServiceId(2),
+			expectedStartedServiceIds: []services.ServiceID{bootnodeServiceID, getChildEthNodeServiceId(1)},
+		},
+		{
+			name:                      "signer after a discovery-only bootnode",
+			params:                    &ModuleAPIExecuteArgs{BootnodeMode: discoveryOnlyBootnodeMode, SignerCount: 2},
+			failingServiceId:          getSignerNodeServiceId(firstSignerNodeIdx + 1),
+			expectedStartedServiceIds: []services.ServiceID{bootnodeServiceID, getSignerNodeServiceId(firstSignerNodeIdx)},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			launcher := &fakeSimulatedNodeLauncher{
+				failingServiceId: test.failingServiceId,
+				startedNodes:     map[services.ServiceID]*fakeSimulatedNode{},
+			}
+			network, resultObj, err := StartSimulatedNetwork(launcher, test.params)
+			require.Error(t, err)
+			require.Contains(t, err.Error(), string(test.failingServiceId))
+			require.Nil(t, network)
+			require.Nil(t, resultObj)
+
+			// Every node that was started before the failure gets stopped again
+			startedServiceIds := []services.ServiceID{}
+			for serviceId, node := range launcher.startedNodes {
+				require.True(t, node.isStopped, "Node '%v' wasn't stopped after the network failed to start", serviceId)
+				startedServiceIds = append(startedServiceIds, serviceId)
+			}
+			require.ElementsMatch(t, test.expectedStartedServiceIds, startedServiceIds)
+		})
+	}
+}
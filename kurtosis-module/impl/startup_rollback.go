@@ -0,0 +1,107 @@
+package impl
+
+import (
+	"github.com/kurtosis-tech/kurtosis-sdk/api/golang/core/lib/enclaves"
+	"github.com/kurtosis-tech/kurtosis-sdk/api/golang/core/lib/services"
+	"github.com/kurtosis-tech/stacktrace"
+	"github.com/sirupsen/logrus"
+)
+
+// An enclave that records the services added through it as they get added, and drops the ones removed through it, so that a
+// failed execution can remove exactly the services it added, and nothing that someone else added to the enclave meanwhile
+type serviceRecordingEnclave struct {
+	moduleEnclave
+
+	// The services added through the enclave that are still in place, in the order they were added
+	addedServiceIds []services.ServiceID
+}
+
+func (enclave *serviceRecordingEnclave) AddService(serviceId services.ServiceID, containerConfig *services.ContainerConfig) (*services.ServiceContext, error) {
+	serviceCtx, err := enclave.moduleEnclave.AddService(serviceId, containerConfig)
+	if err != nil {
+		return nil, err
+	}
+	enclave.addedServiceIds = append(enclave.addedServiceIds, serviceId)
+	return serviceCtx, nil
+}
+
+func (enclave *serviceRecordingEnclave) RemoveService(serviceId services.ServiceID, containerStopTimeoutSeconds uint64) error {
+	if err := enclave.moduleEnclave.RemoveService(serviceId, containerStopTimeoutSeconds); err != nil {
+		return err
+	}
+	for i, addedServiceId := range enclave.addedServiceIds {
+		if addedServiceId == serviceId {
+			enclave.addedServiceIds = append(enclave.addedServiceIds[:i], enclave.addedServiceIds[i+1:]...)
+			break
+		}
+	}
+	return nil
+}
+
+// Removes every service that this instance of the module added to the enclave and hasn't removed since, from the last
+// added to the first, returning the IDs of the removed services even when removing some other service failed
+func (e *EthereumKurtosisModule) RemoveAddedServices(enclaveCtx *enclaves.EnclaveContext) ([]services.ServiceID, error) {
+	return e.removeAddedServices(enclaveCtx)
+}
+
+func (e *EthereumKurtosisModule) removeAddedServices(enclaveCtx moduleEnclave) ([]services.ServiceID, error) {
+	moduleEnclaveCtx := e.newServiceRecordingEnclave(enclaveCtx)
+	defer e.saveAddedServiceIds(moduleEnclaveCtx)
+	return e.removeServices(moduleEnclaveCtx, getReversedServiceIds(moduleEnclaveCtx.addedServiceIds))
+}
+
+// Returns an enclave that records the services added and removed through it in the services added by this instance of the
+// module, once they get saved
+func (e *EthereumKurtosisModule) newServiceRecordingEnclave(enclaveCtx moduleEnclave) *serviceRecordingEnclave {
+	return &serviceRecordingEnclave{
+		moduleEnclave:   enclaveCtx,
+		addedServiceIds: append([]services.ServiceID{}, e.addedServiceIds...),
+	}
+}
+
+func (e *EthereumKurtosisModule) saveAddedServiceIds(moduleEnclaveCtx *serviceRecordingEnclave) {
+	e.addedServiceIds = moduleEnclaveCtx.addedServiceIds
+}
+
+// Removes the given services, dropping the module state of those that are nodes, and of the network if its bootnode is among them
+// Returns the IDs of the services that were removed, even when removing some other service failed
+func (e *EthereumKurtosisModule) removeServices(enclaveCtx moduleEnclave, serviceIds []services.ServiceID) ([]services.ServiceID, error) {
+	removedServiceIds := []services.ServiceID{}
+	failedServiceIds := []services.ServiceID{}
+	for _, serviceId := range serviceIds {
+		if err := enclaveCtx.RemoveService(serviceId, containerStopTimeoutSeconds); err != nil {
+			logrus.Errorf("An error occurred removing created service '%v':\n%v", serviceId, err)
+			failedServiceIds = append(failedServiceIds, serviceId)
+			continue
+		}
+		removedServiceIds = append(removedServiceIds, serviceId)
+		delete(e.stoppedNodeServiceIds, serviceId)
+		delete(e.nodeFilesArtifactUuids, serviceId)
+		if serviceId == bootnodeServiceID {
+			e.forgetNetwork()
+		}
+	}
+	if len(failedServiceIds) > 0 {
+		return removedServiceIds, stacktrace.NewError("Couldn't remove created services %v", failedServiceIds)
+	}
+	return removedServiceIds, nil
+}
+
+// Returns the given service IDs from the last to the first, so that services get removed before the ones they were started
+// against, like the children before their bootnode
+func getReversedServiceIds(serviceIds []services.ServiceID) []services.ServiceID {
+	result := make([]services.ServiceID, 0, len(serviceIds))
+	for i := len(serviceIds) - 1; i >= 0; i-- {
+		result = append(result, serviceIds[i])
+	}
+	return result
+}
+
+// Drops the module state of a network whose bootnode is gone, so that a new network can be started in its place
+func (e *EthereumKurtosisModule) forgetNetwork() {
+	if e.engineApiBlockDriver != nil {
+		e.engineApiBlockDriver.stop()
+		e.engineApiBlockDriver = nil
+	}
+	e.networkLaunchConfig = nil
+}
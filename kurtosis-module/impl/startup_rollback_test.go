@@ -0,0 +1,62 @@
+package impl
+
+import (
+	"testing"
+
+	"github.com/kurtosis-tech/kurtosis-sdk/api/golang/core/lib/services"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	testOtherServiceId services.ServiceID = "other-service"
+)
+
+func TestRemoveAddedServices(t *testing.T) {
+	tests := []struct {
+		name         string
+		laterActions []*ModuleAPIExecuteArgs
+	}{
+		{
+			name: "created network",
+		},
+		{
+			name:         "network with an added node",
+			laterActions: []*ModuleAPIExecuteArgs{{Action: AddNodesAction}},
+		},
+		{
+			name:         "network with a removed node",
+			laterActions: []*ModuleAPIExecuteArgs{{Action: RemoveNodeAction, NodeServiceID: getChildEthNodeServiceId(1)}},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			enclave := newFakeEnclave()
+			module := newFakeEnclaveModule(enclave)
+			createParams := &ModuleAPIExecuteArgs{}
+			require.NoError(t, applyDefaultsAndValidateParams(createParams))
+			_, err := module.executeValidatedParams(enclave, createParams)
+			require.NoError(t, err)
+
+			// A service that someone else adds to the enclave once the network is up must survive its removal
+			_, err = enclave.AddService(testOtherServiceId, services.NewContainerConfigBuilder(ethereumDockerImageName).Build())
+			require.NoError(t, err)
+			for _, params := range test.laterActions {
+				require.NoError(t, applyDefaultsAndValidateParams(params))
+				_, err := module.executeValidatedParams(enclave, params)
+				require.NoError(t, err)
+			}
+
+			removedServiceIds, err := module.removeAddedServices(enclave)
+			require.NoError(t, err)
+			require.NotContains(t, removedServiceIds, testOtherServiceId)
+			serviceIds, err := enclave.GetServices()
+			require.NoError(t, err)
+			require.Equal(t, map[services.ServiceID]bool{testOtherServiceId: true}, serviceIds)
+
+			// Everything the module added is gone, so there's nothing left to remove
+			removedServiceIds, err = module.removeAddedServices(enclave)
+			require.NoError(t, err)
+			require.Empty(t, removedServiceIds)
+		})
+	}
+}
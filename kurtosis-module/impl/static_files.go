@@ -0,0 +1,268 @@
+package impl
+
+import (
+	"crypto/ecdsa"
+	"encoding/hex"
+	"encoding/json"
+	"github.com/ethereum/go-ethereum/accounts/keystore"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	static_files "github.com/kurtosis-tech/ethereum-kurtosis-module/kurtosis-module/static-files"
+	static_files_consts "github.com/kurtosis-tech/ethereum-kurtosis-module/kurtosis-module/static-files-consts"
+	"github.com/kurtosis-tech/kurtosis-sdk/api/golang/core/lib/services"
+	"github.com/kurtosis-tech/stacktrace"
+	"io/fs"
+	"io/ioutil"
+	"os"
+	"path"
+	"sort"
+	"strings"
+)
+
+const (
+	staticFilesArtifactUuidEnvVar = "ETHEREUM_KURTOSIS_MODULE_STATIC_FILES_ARTIFACT_UUID"
+	// Only set when the files artifact is the caller's
+	callerStaticFilesEnvVar = "ETHEREUM_KURTOSIS_MODULE_CALLER_STATIC_FILES"
+
+	staticFilesTempDirPattern = "static-files-"
+)
+
+// The static files that the nodes get, either the ones built into the module or those of a files artifact of the caller,
+// along with what the module reads from them
+type staticFiles struct {
+	filesArtifactUuid services.FilesArtifactUUID
+	// True if the files artifact was provided by the caller rather than uploaded from the files built into the module
+	isCallerSupplied bool
+
+	genesis        []byte
+	genesisChainId uint64
+	// Only the networks producing their blocks through the Engine API need the post-merge genesis
+	hasPostMergeGenesis bool
+
+	// The account that the signer seals with, unless accounts were imported in its place
+	signerKeystoreFilename string
+	signerKeystoreContent  string
+	signerAccountPassword  string
+	signerAccountKey       *ecdsa.PrivateKey
+}
+
+// Loads the static files of the given files artifact of the caller, or uploads the ones embedded in the module if no files
+// artifact is given, checking that they have everything the nodes need before any node gets started
+func loadStaticFiles(enclaveCtx moduleEnclave, callerFilesArtifactUuid services.FilesArtifactUUID) (*staticFiles, error) {
+	if callerFilesArtifactUuid != "" {
+		fileContents, err := readFilesArtifact(enclaveCtx, callerFilesArtifactUuid)
+		if err != nil {
+			return nil, stacktrace.Propagate(err, "An error occurred reading the static files of files artifact '%v'", callerFilesArtifactUuid)
+		}
+		result, err := newStaticFiles(callerFilesArtifactUuid, fileContents)
+		if err != nil {
+			return nil, stacktrace.Propagate(err, "The static files of files artifact '%v' were invalid", callerFilesArtifactUuid)
+		}
+		result.isCallerSupplied = true
+		return result, nil
+	}
+
+	fileContents, err := getEmbeddedStaticFileContents()
+	if err != nil {
+		return nil, stacktrace.Propagate(err, "An error occurred reading the static files built into the module")
+	}
+	// The enclave only takes files from disk, so the embedded ones get written to a temporary directory first
+	tempDirpath, err := ioutil.TempDir("", staticFilesTempDirPattern)
+	if err != nil {
+		return nil, stacktrace.Propagate(err, "An error occurred creating a temporary directory for the static files")
+	}
+	defer os.RemoveAll(tempDirpath)
+	for filename, contents := range fileContents {
+		filepath := path.Join(tempDirpath, filename)
+		if err := ioutil.WriteFile(filepath, []byte(contents), nodeFilePerms); err != nil {
+			return nil, stacktrace.Propagate(err, "An error occurred writing static file '%v' to '%v'", filename, filepath)
+		}
+	}
+	filesArtifactUuid, err := enclaveCtx.UploadFiles(tempDirpath)
+	if err != nil {
+		return nil, stacktrace.Propagate(err, "An error occurred uploading the static files")
+	}
+	result, err := newStaticFiles(filesArtifactUuid, fileContents)
+	if err != nil {
+		return nil, stacktrace.Propagate(err, "The static files built into the module were invalid; this is a bug with this module")
+	}
+	return result, nil
+}
+
+// Returns the contents of the static files built into the module by their name
+func getEmbeddedStaticFileContents() (map[string]string, error) {
+	fileEntries, err := fs.ReadDir(static_files.Files, ".")
+	if err != nil {
+		return nil, stacktrace.Propagate(err, "An error occurred listing the static files built into the module")
+	}
+	result := map[string]string{}
+	for _, fileEntry := range fileEntries {
+		contents, err := fs.ReadFile(static_files.Files, fileEntry.Name())
+		if err != nil {
+			return nil, stacktrace.Propagate(err, "An error occurred reading static file '%v'", fileEntry.Name())
+		}
+		result[fileEntry.Name()] = string(contents)
+	}
+	return result, nil
+}
+
+// Checks the given static files by their name: the genesis must parse, with a chain ID and Clique signers in its extradata,
+// and exactly one of the other files must be a keystore, which the password must decrypt
+// The files that are neither of these, like a README, are ignored
+func newStaticFiles(filesArtifactUuid services.FilesArtifactUUID, fileContents map[string]string) (*staticFiles, error) {
+	genesis, found := fileContents[static_files_consts.GenesisStaticFileName]
+	if !found {
+		return nil, stacktrace.NewError("No genesis '%v' was found", static_files_consts.GenesisStaticFileName)
+	}
+	genesisChainId, _, err := parseGenesis([]byte(genesis))
+	if err != nil {
+		return nil, stacktrace.Propagate(err, "An error occurred parsing genesis '%v'", static_files_consts.GenesisStaticFileName)
+	}
+	postMergeGenesis, hasPostMergeGenesis := fileContents[static_files_consts.PostMergeGenesisStaticFileName]
+	if hasPostMergeGenesis {
+		if err := json.Unmarshal([]byte(postMergeGenesis), &map[string]interface{}{}); err != nil {
+			return nil, stacktrace.Propagate(err, "An error occurred parsing post-merge genesis '%v'", static_files_consts.PostMergeGenesisStaticFileName)
+		}
+	}
+	signerAccountPassword, found := fileContents[static_files_consts.SignerAccountPasswordStaticFileName]
+	if !found {
+		return nil, stacktrace.NewError("No signer account password '%v' was found", static_files_consts.SignerAccountPasswordStaticFileName)
+	}
+	registerSecret(signerAccountPassword)
+
+	keystoreFilenames := []string{}
+	for filename, contents := range fileContents {
+		if filename == static_files_consts.GenesisStaticFileName || filename == static_files_consts.PostMergeGenesisStaticFileName || filename == static_files_consts.SignerAccountPasswordStaticFileName {
+			continue
+		}
+		if isKeystore(contents) {
+			keystoreFilenames = append(keystoreFilenames, filename)
+		}
+	}
+	sort.Strings(keystoreFilenames)
+	if len(keystoreFilenames) != 1 {
+		return nil, stacktrace.NewError("Exactly one signer keystore must be found besides the geneses and the password, but found %v: %v", len(keystoreFilenames), keystoreFilenames)
+	}
+	signerKeystoreFilename := keystoreFilenames[0]
+	signerKeystoreContent := fileContents[signerKeystoreFilename]
+	registerSecret(signerKeystoreContent)
+	signerKey, err := keystore.DecryptKey([]byte(signerKeystoreContent), getPasswordFromFile(signerAccountPassword))
+	if err != nil {
+		return nil, stacktrace.Propagate(err, "An error occurred decrypting signer keystore '%v' with the signer account password", signerKeystoreFilename)
+	}
+	registerSecret(hex.EncodeToString(crypto.FromECDSA(signerKey.PrivateKey)))
+
+	return &staticFiles{
+		filesArtifactUuid:      filesArtifactUuid,
+		genesis:                []byte(genesis),
+		genesisChainId:         genesisChainId,
+		hasPostMergeGenesis:    hasPostMergeGenesis,
+		signerKeystoreFilename: signerKeystoreFilename,
+		signerKeystoreContent:  signerKeystoreContent,
+		signerAccountPassword:  signerAccountPassword,
+		signerAccountKey:       signerKey.PrivateKey,
+	}, nil
+}
+
+// Tells whether the given file contents look like a Geth keystore, which is a JSON object with the address of its account and
+// the crypto params that encrypt its key; whether it can be decrypted is checked apart
+func isKeystore(contents string) bool {
+	keystoreFields := &struct {
+		Address string          `json:"address"`
+		Crypto  json.RawMessage `json:"crypto"`
+	}{}
+	if err := json.Unmarshal([]byte(contents), keystoreFields); err != nil {
+		return false
+	}
+	return keystoreFields.Address != "" && len(keystoreFields.Crypto) != 0
+}
+
+// Returns the chain ID of the given genesis, along with the Clique signers in its extradata
+func parseGenesis(genesis []byte) (uint64, []common.Address, error) {
+	parsedGenesis := &struct {
+		Config *struct {
+			ChainId *uint64 `json:"chainId"`
+		} `json:"config"`
+		Extradata string `json:"extradata"`
+	}{}
+	if err := json.Unmarshal(genesis, parsedGenesis); err != nil {
+		return 0, nil, stacktrace.Propagate(err, "An error occurred deserializing the genesis")
+	}
+	if parsedGenesis.Config == nil || parsedGenesis.Config.ChainId == nil {
+		return 0, nil, stacktrace.NewError("The genesis has no chain ID in its config")
+	}
+	extradata, err := hex.DecodeString(strings.TrimPrefix(parsedGenesis.Extradata, "0x"))
+	if err != nil {
+		return 0, nil, stacktrace.Propagate(err, "An error occurred decoding extradata '%v' of the genesis", parsedGenesis.Extradata)
+	}
+	signersLength := len(extradata) - cliqueExtradataVanityLength - cliqueExtradataSealLength
+	if signersLength < 0 || signersLength%common.AddressLength != 0 {
+		return 0, nil, stacktrace.NewError(
+			"The %v-byte extradata of the genesis isn't %v bytes of vanity, Clique signer addresses and a %v-byte seal",
+			len(extradata),
+			cliqueExtradataVanityLength,
+			cliqueExtradataSealLength,
+		)
+	}
+	signerAddrs := []common.Address{}
+	for i := cliqueExtradataVanityLength; i < cliqueExtradataVanityLength+signersLength; i += common.AddressLength {
+		signerAddrs = append(signerAddrs, common.BytesToAddress(extradata[i:i+common.AddressLength]))
+	}
+	return *parsedGenesis.Config.ChainId, signerAddrs, nil
+}
+
+// Checks that the account of the static files is a Clique signer of their genesis, which the network is started with
+// as it is when the bootnode seals with that account
+func verifyStaticSignerInGenesis(staticFiles *staticFiles) error {
+	_, signerAddrs, err := parseGenesis(staticFiles.genesis)
+	if err != nil {
+		return stacktrace.Propagate(err, "An error occurred parsing the genesis of the static files")
+	}
+	signerAddr := crypto.PubkeyToAddress(staticFiles.signerAccountKey.PublicKey)
+	for _, genesisSignerAddr := range signerAddrs {
+		if genesisSignerAddr == signerAddr {
+			return nil
+		}
+	}
+	return stacktrace.NewError(
+		"The account '%v' of signer keystore '%v' isn't one of the Clique signers %v in the extradata of the genesis",
+		getAccountAddress(staticFiles.signerAccountKey),
+		staticFiles.signerKeystoreFilename,
+		signerAddrs,
+	)
+}
+
+// Returns the static files that the network was started with, which are those of the files artifact recorded on the given
+// bootnode
+func getExistingStaticFiles(enclaveCtx moduleEnclave, bootnodeServiceCtx *services.ServiceContext) (*staticFiles, error) {
+	bootnodeServiceId := bootnodeServiceCtx.GetServiceID()
+	exitCode, filesArtifactUuid, err := bootnodeServiceCtx.ExecCommand([]string{"printenv", staticFilesArtifactUuidEnvVar})
+	if err != nil {
+		return nil, stacktrace.Propagate(err, "An error occurred reading the static files artifact of bootnode '%v'", bootnodeServiceId)
+	}
+	if exitCode != execCommandSuccessExitCode {
+		return nil, stacktrace.NewError("No static files artifact is recorded on bootnode '%v'", bootnodeServiceId)
+	}
+	exitCode, _, err = bootnodeServiceCtx.ExecCommand([]string{"printenv", callerStaticFilesEnvVar})
+	if err != nil {
+		return nil, stacktrace.Propagate(err, "An error occurred reading whether the static files of bootnode '%v' are the caller's", bootnodeServiceId)
+	}
+	isCallerSupplied := exitCode == execCommandSuccessExitCode
+
+	existingFilesArtifactUuid := services.FilesArtifactUUID(strings.TrimSpace(filesArtifactUuid))
+	fileContents, err := readFilesArtifact(enclaveCtx, existingFilesArtifactUuid)
+	if err != nil {
+		return nil, stacktrace.Propagate(err, "An error occurred reading the static files of files artifact '%v'", existingFilesArtifactUuid)
+	}
+	result, err := newStaticFiles(existingFilesArtifactUuid, fileContents)
+	if err != nil {
+		return nil, stacktrace.Propagate(err, "The static files of files artifact '%v' were invalid", existingFilesArtifactUuid)
+	}
+	result.isCallerSupplied = isCallerSupplied
+	return result, nil
+}
+
+// Returns the password in the given password file, which is its first line, as it's the only one that Geth reads
+func getPasswordFromFile(passwordFileContent string) string {
+	return strings.TrimRight(strings.Split(passwordFileContent, "\n")[0], "\r")
+}
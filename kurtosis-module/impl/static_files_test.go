@@ -0,0 +1,145 @@
+package impl
+
+import (
+	"io/ioutil"
+	"path"
+	"testing"
+
+	static_files_consts "github.com/kurtosis-tech/ethereum-kurtosis-module/kurtosis-module/static-files-consts"
+	"github.com/kurtosis-tech/kurtosis-sdk/api/golang/core/lib/services"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	testStaticFilesArtifactUuid = "static-files"
+	testReadmeFilename          = "README.md"
+)
+
+func TestNewStaticFiles_ExtraFiles(t *testing.T) {
+	embeddedFileContents, err := getEmbeddedStaticFileContents()
+	require.NoError(t, err)
+
+	tests := []struct {
+		name          string
+		modify        func(fileContents map[string]string)
+		isErrExpected bool
+	}{
+		{
+			name:   "embedded files",
+			modify: func(fileContents map[string]string) {},
+		},
+		{
+			name: "with a README",
+			modify: func(fileContents map[string]string) {
+				fileContents[testReadmeFilename] = "# Static files\nThe genesis and the signer keystore of the network\n"
+			},
+		},
+		{
+			name: "with a JSON file that isn't a keystore",
+			modify: func(fileContents map[string]string) {
+				fileContents["notes.json"] = `{"address": "not a keystore"}`
+			},
+		},
+		{
+			name: "with a second keystore",
+			modify: func(fileContents map[string]string) {
+				for filename, contents := range embeddedFileContents {
+					if isKeystore(contents) {
+						fileContents["copy-of-"+filename] = contents
+					}
+				}
+			},
+			isErrExpected: true,
+		},
+		{
+			name: "without a keystore",
+			modify: func(fileContents map[string]string) {
+				for filename, contents := range embeddedFileContents {
+					if isKeystore(contents) {
+						delete(fileContents, filename)
+					}
+				}
+			},
+			isErrExpected: true,
+		},
+		{
+			name: "without a password",
+			modify: func(fileContents map[string]string) {
+				delete(fileContents, static_files_consts.SignerAccountPasswordStaticFileName)
+			},
+			isErrExpected: true,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			fileContents := map[string]string{}
+			for filename, contents := range embeddedFileContents {
+				fileContents[filename] = contents
+			}
+			test.modify(fileContents)
+
+			result, err := newStaticFiles(testStaticFilesArtifactUuid, fileContents)
+			if test.isErrExpected {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.NotEqual(t, testReadmeFilename, result.signerKeystoreFilename)
+			require.True(t, isKeystore(fileContents[result.signerKeystoreFilename]))
+		})
+	}
+}
+
+func TestGetExistingStaticFiles(t *testing.T) {
+	tests := []struct {
+		name             string
+		isCallerSupplied bool
+	}{
+		{
+			name: "built into the module",
+		},
+		{
+			name:             "of the caller",
+			isCallerSupplied: true,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			enclave := newFakeEnclave()
+			params := &ModuleAPIExecuteArgs{}
+			if test.isCallerSupplied {
+				params.StaticFilesArtifactUUID = uploadEmbeddedStaticFiles(t, enclave)
+			}
+			require.NoError(t, applyDefaultsAndValidateParams(params))
+			module := newFakeEnclaveModule(enclave)
+			_, err := module.executeValidatedParams(enclave, params)
+			require.NoError(t, err)
+			startedStaticFiles := module.networkLaunchConfig.staticFiles
+
+			bootnodeServiceCtx, err := enclave.GetServiceContext(bootnodeServiceID)
+			require.NoError(t, err)
+			filesArtifactCount := enclave.getFilesArtifactCount()
+			existingStaticFiles, err := getExistingStaticFiles(enclave, bootnodeServiceCtx)
+			require.NoError(t, err)
+
+			// The files artifact that the network was started with is read again rather than uploaded again
+			require.Equal(t, filesArtifactCount, enclave.getFilesArtifactCount())
+			require.Equal(t, startedStaticFiles.filesArtifactUuid, existingStaticFiles.filesArtifactUuid)
+			require.Equal(t, test.isCallerSupplied, existingStaticFiles.isCallerSupplied)
+			require.Equal(t, startedStaticFiles.signerAccountKey, existingStaticFiles.signerAccountKey)
+		})
+	}
+}
+
+// Uploads the static files built into the module to the given enclave, the way a caller uploads their own
+func uploadEmbeddedStaticFiles(t *testing.T, enclave *fakeEnclave) services.FilesArtifactUUID {
+	fileContents, err := getEmbeddedStaticFileContents()
+	require.NoError(t, err)
+	dirpath := t.TempDir()
+	for filename, contents := range fileContents {
+		require.NoError(t, ioutil.WriteFile(path.Join(dirpath, filename), []byte(contents), nodeFilePerms))
+	}
+	filesArtifactUuid, err := enclave.UploadFiles(dirpath)
+	require.NoError(t, err)
+	return filesArtifactUuid
+}
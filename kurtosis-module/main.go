@@ -20,7 +20,7 @@ func main() {
 	executor := execution.NewKurtosisModuleExecutor(configurator)
 	if err := executor.Run(); err != nil {
 		logrus.Errorf("An error occurred running the Kurtosis module executor:")
-		fmt.Fprintln(logrus.StandardLogger().Out, err)
+		fmt.Fprintln(logrus.StandardLogger().Out, impl.RedactSecrets(err.Error()))
 		os.Exit(failureExitCode)
 	}
 	os.Exit(successExitCode)
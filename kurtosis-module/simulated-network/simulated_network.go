@@ -0,0 +1,260 @@
+// The go-ethereum v1.10.8 that this package runs the nodes with depends on fjl/memsize, which reaches into the runtime
+// through go:linkname; the module builds with Go 1.16, and Go 1.23 and later only link the package with:
+//
+//	go test -ldflags=-checklinkname=0 ./kurtosis-module/simulated-network/
+package simulated_network
+
+import (
+	"crypto/ecdsa"
+	"encoding/json"
+	"github.com/ethereum/go-ethereum/accounts/keystore"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/eth"
+	"github.com/ethereum/go-ethereum/eth/downloader"
+	"github.com/ethereum/go-ethereum/eth/ethconfig"
+	"github.com/ethereum/go-ethereum/node"
+	"github.com/ethereum/go-ethereum/p2p"
+	"github.com/ethereum/go-ethereum/p2p/discover"
+	"github.com/ethereum/go-ethereum/p2p/enode"
+	"github.com/kurtosis-tech/ethereum-kurtosis-module/kurtosis-module/impl"
+	"github.com/kurtosis-tech/kurtosis-sdk/api/golang/core/lib/services"
+	"github.com/kurtosis-tech/stacktrace"
+	"net"
+	"time"
+)
+
+const (
+	// What the nodes call themselves, as Geth does, so that their client version reads the same
+	gethNodeName = "geth"
+
+	loopbackIpAddr = "127.0.0.1"
+	// Port 0 lets the OS pick a free port, so that any number of networks can run side by side
+	loopbackListenAddr = loopbackIpAddr + ":0"
+
+	gethMaxPeers = 50
+
+	miningThreads = 1
+
+	// The keystore of a node only lives as long as the node, so its account is imported without a password
+	signerAccountPassword = ""
+
+	// Clique delays the blocks of out-of-turn signers by up to half a second for every two signers, on top of the block time
+	maxSealDelayPastBlockTime = 3 * time.Second
+	timeBetweenSealChecks     = 50 * time.Millisecond
+)
+
+// Starts the network described by the given execute params in the current process, as go-ethereum nodes listening on
+// loopback ports, returning it along with the result that executing the module with the same params returns
+// Nothing runs in Kurtosis or Docker, so tests can start a network in a second and switch to the module in an enclave later
+func StartNetwork(params *impl.ModuleAPIExecuteArgs) (*impl.SimulatedNetwork, *impl.ModuleAPIExecuteResult, error) {
+	network, resultObj, err := impl.StartSimulatedNetwork(&gethNodeLauncher{}, params)
+	if err != nil {
+		return nil, nil, stacktrace.Propagate(err, "An error occurred starting the simulated Ethereum network")
+	}
+	return network, resultObj, nil
+}
+
+type gethNodeLauncher struct{}
+
+// A go-ethereum node, which keeps its chain in memory
+type gethNode struct {
+	stack     *node.Node
+	ethereum  *eth.Ethereum
+	isSealing bool
+}
+
+// A node that only runs discovery, like Geth's bootnode binary
+type discoveryBootnode struct {
+	discovery *discover.UDPv4
+	nodeDb    *enode.DB
+}
+
+func (launcher *gethNodeLauncher) StartDiscoveryBootnode(serviceId services.ServiceID, nodeKey *ecdsa.PrivateKey) (impl.SimulatedNode, error) {
+	listenAddr, err := net.ResolveUDPAddr("udp", loopbackListenAddr)
+	if err != nil {
+		return nil, stacktrace.Propagate(err, "An error occurred resolving discovery address '%v'", loopbackListenAddr)
+	}
+	conn, err := net.ListenUDP("udp", listenAddr)
+	if err != nil {
+		return nil, stacktrace.Propagate(err, "An error occurred listening for discovery on '%v'", loopbackListenAddr)
+	}
+	// An empty path keeps the node database in memory
+	nodeDb, err := enode.OpenDB("")
+	if err != nil {
+		conn.Close()
+		return nil, stacktrace.Propagate(err, "An error occurred opening the node database of bootnode '%v'", serviceId)
+	}
+	localNode := enode.NewLocalNode(nodeDb, nodeKey)
+	localNode.SetStaticIP(net.ParseIP(loopbackIpAddr))
+	localNode.SetFallbackUDP(conn.LocalAddr().(*net.UDPAddr).Port)
+	discovery, err := discover.ListenV4(conn, localNode, discover.Config{PrivateKey: nodeKey})
+	if err != nil {
+		conn.Close()
+		nodeDb.Close()
+		return nil, stacktrace.Propagate(err, "An error occurred starting discovery on bootnode '%v'", serviceId)
+	}
+	return &discoveryBootnode{
+		discovery: discovery,
+		nodeDb:    nodeDb,
+	}, nil
+}
+
+func (launcher *gethNodeLauncher) StartGethNode(config *impl.SimulatedGethNodeConfig) (impl.SimulatedNode, error) {
+	// An empty datadir keeps the chain in memory and the keystore in a temporary directory that's removed on close, and
+	// the non-nil peer lists keep the node from looking for them in the datadir
+	stack, err := node.New(&node.Config{
+		Name:              gethNodeName,
+		UseLightweightKDF: true,
+		HTTPHost:          loopbackIpAddr,
+		HTTPCors:          []string{"*"},
+		HTTPVirtualHosts:  []string{"*"},
+		HTTPModules:       config.HttpModules,
+		P2P: p2p.Config{
+			PrivateKey:     config.NodeKey,
+			MaxPeers:       gethMaxPeers,
+			ListenAddr:     loopbackListenAddr,
+			BootstrapNodes: config.BootnodeRecords,
+			StaticNodes:    config.StaticNodes,
+			TrustedNodes:   config.TrustedNodes,
+		},
+	})
+	if err != nil {
+		return nil, stacktrace.Propagate(err, "An error occurred creating node '%v'", config.ServiceID)
+	}
+	ethConfig, err := getEthConfig(config)
+	if err != nil {
+		stack.Close()
+		return nil, stacktrace.Propagate(err, "An error occurred getting the Ethereum config of node '%v'", config.ServiceID)
+	}
+	ethereum, err := eth.New(stack, ethConfig)
+	if err != nil {
+		stack.Close()
+		return nil, stacktrace.Propagate(err, "An error occurred creating the Ethereum service of node '%v'", config.ServiceID)
+	}
+	if err := stack.Start(); err != nil {
+		stack.Close()
+		return nil, stacktrace.Propagate(err, "An error occurred starting node '%v'", config.ServiceID)
+	}
+
+	if config.SignerAccountKey != nil {
+		if err := startSealing(stack, ethereum, config.SignerAccountKey); err != nil {
+			stack.Close()
+			return nil, stacktrace.Propagate(err, "An error occurred making signer '%v' seal blocks", config.ServiceID)
+		}
+	}
+	return &gethNode{
+		stack:     stack,
+		ethereum:  ethereum,
+		isSealing: config.SignerAccountKey != nil,
+	}, nil
+}
+
+// Returns Geth's defaults with the genesis, the network ID and the sync and GC modes of the given config, sealing on demand
+func getEthConfig(config *impl.SimulatedGethNodeConfig) (*ethconfig.Config, error) {
+	genesis := &core.Genesis{}
+	if err := json.Unmarshal(config.Genesis, genesis); err != nil {
+		return nil, stacktrace.Propagate(err, "An error occurred deserializing the genesis")
+	}
+	// A period of zero makes Clique seal as soon as there are transactions, so that tests don't wait for the block time
+	if genesis.Config != nil && genesis.Config.Clique != nil {
+		genesis.Config.Clique.Period = 0
+	}
+	ethConfig := ethconfig.Defaults
+	ethConfig.Genesis = genesis
+	ethConfig.NetworkId = config.NetworkId
+	ethConfig.NoPruning = config.NoPruning
+	if config.SyncMode != "" {
+		if err := ethConfig.SyncMode.UnmarshalText([]byte(config.SyncMode)); err != nil {
+			return nil, stacktrace.Propagate(err, "An error occurred parsing sync mode '%v'", config.SyncMode)
+		}
+	}
+	// A light node would need a server to sync from, which none of the nodes are
+	if ethConfig.SyncMode == downloader.LightSync {
+		return nil, stacktrace.NewError("Sync mode '%v' isn't supported", config.SyncMode)
+	}
+	return &ethConfig, nil
+}
+
+// Imports the account of the given key into the keystore of the node, where Clique finds it, and seals blocks with it
+func startSealing(stack *node.Node, ethereum *eth.Ethereum, signerAccountKey *ecdsa.PrivateKey) error {
+	keystoreBackends := stack.AccountManager().Backends(keystore.KeyStoreType)
+	if len(keystoreBackends) == 0 {
+		return stacktrace.NewError("The node has no keystore to import the account of the signer into")
+	}
+	signerKeystore := keystoreBackends[0].(*keystore.KeyStore)
+	account, err := signerKeystore.ImportECDSA(signerAccountKey, signerAccountPassword)
+	if err != nil {
+		return stacktrace.Propagate(err, "An error occurred importing the account of the signer")
+	}
+	if err := signerKeystore.Unlock(account, signerAccountPassword); err != nil {
+		return stacktrace.Propagate(err, "An error occurred unlocking account '%v'", account.Address.Hex())
+	}
+	ethereum.SetEtherbase(account.Address)
+	if err := ethereum.StartMining(miningThreads); err != nil {
+		return stacktrace.Propagate(err, "An error occurred starting to seal blocks with account '%v'", account.Address.Hex())
+	}
+	return nil
+}
+
+// Stops sealing blocks, waiting for the block whose seal is underway (if any) to be written
+// The miner writes that block even once it's stopped, which panics if the node has closed its chain database by then, as
+// a closed in-memory database can't be written to
+func stopSealing(ethereum *eth.Ethereum) {
+	ethereum.StopMining()
+	// Clique never seals an empty block when it seals on demand
+	pendingBlock := ethereum.Miner().PendingBlock()
+	if pendingBlock == nil || len(pendingBlock.Transactions()) == 0 {
+		return
+	}
+	// The pending block never gets written if the signer signed too recently to seal it, so the wait is bounded
+	deadline := time.Unix(int64(pendingBlock.Time()), 0).Add(maxSealDelayPastBlockTime)
+	for ethereum.BlockChain().CurrentBlock().NumberU64() < pendingBlock.NumberU64() && time.Now().Before(deadline) {
+		time.Sleep(timeBetweenSealChecks)
+	}
+}
+
+func (gethNode *gethNode) GetRecord() *enode.Node {
+	return gethNode.stack.Server().Self()
+}
+
+func (gethNode *gethNode) GetRpcUrl() string {
+	return gethNode.stack.HTTPEndpoint()
+}
+
+func (gethNode *gethNode) GetPeerCount() int {
+	return gethNode.stack.Server().PeerCount()
+}
+
+func (gethNode *gethNode) AddPeer(record *enode.Node) {
+	gethNode.stack.Server().AddPeer(record)
+}
+
+func (gethNode *gethNode) Stop() error {
+	if gethNode.isSealing {
+		stopSealing(gethNode.ethereum)
+	}
+	if err := gethNode.stack.Close(); err != nil {
+		return stacktrace.Propagate(err, "An error occurred closing the node")
+	}
+	return nil
+}
+
+func (bootnode *discoveryBootnode) GetRecord() *enode.Node {
+	return bootnode.discovery.Self()
+}
+
+func (bootnode *discoveryBootnode) GetRpcUrl() string {
+	return ""
+}
+
+func (bootnode *discoveryBootnode) GetPeerCount() int {
+	return 0
+}
+
+func (bootnode *discoveryBootnode) AddPeer(record *enode.Node) {}
+
+func (bootnode *discoveryBootnode) Stop() error {
+	bootnode.discovery.Close()
+	bootnode.nodeDb.Close()
+	return nil
+}
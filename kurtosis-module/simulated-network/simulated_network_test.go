@@ -0,0 +1,108 @@
+package simulated_network
+
+import (
+	"context"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/kurtosis-tech/ethereum-kurtosis-module/kurtosis-module/impl"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	// Hex-encoded key of the account that the test sends a transaction from, which the network funds at genesis
+	testSenderPrivateKey = "4c0883a69102937d6231471b5dbb6204fe512961708279f9d7d1b3e1c4d9f1a1"
+	testRecipientAddr    = "0x00000000000000000000000000000000000000aa"
+	testTransferWei      = 1000
+
+	transferGasLimit = 21000
+	transferGasPrice = 1000000000
+
+	// The signers seal as soon as the transaction arrives, so this only leaves room for the block to propagate
+	receiptTimeout             = 5 * time.Second
+	timeBetweenReceiptAttempts = 20 * time.Millisecond
+	rpcCallTimeout             = 5 * time.Second
+)
+
+func TestStartNetwork_TransactionIsSealed(t *testing.T) {
+	params := &impl.ModuleAPIExecuteArgs{
+		ImportedAccounts: &impl.ModuleAPIImportedAccountsArgs{
+			PrivateKeys: []string{testSenderPrivateKey},
+		},
+	}
+	network, resultObj, err := StartNetwork(params)
+	require.NoError(t, err)
+	defer func() {
+		require.NoError(t, network.Stop())
+	}()
+
+	// Every node of the network serves RPC, so the transaction is sent through one and read back through another
+	require.Len(t, resultObj.BootnodeServiceIDs, 1)
+	bootnodeServiceId := resultObj.BootnodeServiceIDs[0]
+	var childRpcUrl string
+	for serviceId, nodeInfo := range resultObj.NodeInfo {
+		require.NotEmpty(t, nodeInfo.RpcUrl, "Node '%v' has no RPC URL", serviceId)
+		if serviceId != bootnodeServiceId && childRpcUrl == "" {
+			childRpcUrl = nodeInfo.RpcUrl
+		}
+	}
+	require.NotEmpty(t, childRpcUrl)
+	senderClient, err := ethclient.Dial(childRpcUrl)
+	require.NoError(t, err)
+	defer senderClient.Close()
+	readerClient, err := ethclient.Dial(resultObj.NodeInfo[bootnodeServiceId].RpcUrl)
+	require.NoError(t, err)
+	defer readerClient.Close()
+
+	senderKey, err := crypto.HexToECDSA(testSenderPrivateKey)
+	require.NoError(t, err)
+	ctx, cancelFunc := context.WithTimeout(context.Background(), rpcCallTimeout)
+	defer cancelFunc()
+	chainId, err := senderClient.ChainID(ctx)
+	require.NoError(t, err)
+	nonce, err := senderClient.PendingNonceAt(ctx, crypto.PubkeyToAddress(senderKey.PublicKey))
+	require.NoError(t, err)
+	recipientAddr := common.HexToAddress(testRecipientAddr)
+	tx, err := types.SignTx(
+		types.NewTransaction(nonce, recipientAddr, big.NewInt(testTransferWei), transferGasLimit, big.NewInt(transferGasPrice), nil),
+		types.NewEIP155Signer(chainId),
+		senderKey,
+	)
+	require.NoError(t, err)
+	require.NoError(t, senderClient.SendTransaction(ctx, tx))
+
+	receipt := waitForReceipt(t, readerClient, tx.Hash())
+	require.Equal(t, types.ReceiptStatusSuccessful, receipt.Status)
+	balanceCtx, balanceCancelFunc := context.WithTimeout(context.Background(), rpcCallTimeout)
+	defer balanceCancelFunc()
+	recipientBalance, err := readerClient.BalanceAt(balanceCtx, recipientAddr, receipt.BlockNumber)
+	require.NoError(t, err)
+	require.Equal(t, big.NewInt(testTransferWei), recipientBalance)
+}
+
+func TestStartNetwork_UnsupportedParams(t *testing.T) {
+	_, _, err := StartNetwork(&impl.ModuleAPIExecuteArgs{StaticFilesArtifactUUID: "static-files"})
+	require.Error(t, err)
+}
+
+// Waits until the given node has the receipt of the given transaction, which it only has once the transaction is in a block
+func waitForReceipt(t *testing.T, client *ethclient.Client, txHash common.Hash) *types.Receipt {
+	deadline := time.Now().Add(receiptTimeout)
+	for {
+		ctx, cancelFunc := context.WithTimeout(context.Background(), rpcCallTimeout)
+		receipt, err := client.TransactionReceipt(ctx, txHash)
+		cancelFunc()
+		if err == nil {
+			return receipt
+		}
+		require.ErrorIs(t, err, ethereum.NotFound)
+		require.True(t, time.Now().Before(deadline), "Transaction '%v' wasn't sealed into a block within %v", txHash.Hex(), receiptTimeout)
+		time.Sleep(timeBetweenReceiptAttempts)
+	}
+}
@@ -0,0 +1,8 @@
+package static_files
+
+import "embed"
+
+// The default static files, built into the module so that it doesn't depend on them being copied into its image
+//
+//go:embed *.json *.txt UTC--*
+var Files embed.FS